@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/filter"
+)
+
+// stable, machine-readable error codes, clients should match on these rather than on messages
+const (
+	codeInvalidRequestBody   = "INVALID_REQUEST_BODY"
+	codeRequestTooLarge      = "REQUEST_TOO_LARGE"
+	codeMissingValue         = "MISSING_VALUE"
+	codeNumericString        = "NUMERIC_STRING"
+	codeStringAlreadyExists  = "STRING_ALREADY_EXISTS"
+	codeStringNotFound       = "STRING_NOT_FOUND"
+	codeHashNotFound         = "HASH_NOT_FOUND"
+	codeUnsupportedAlgorithm = "UNSUPPORTED_HASH_ALGORITHM"
+	codeInvalidParameter     = "INVALID_PARAMETER"
+	codeInvalidFilter        = "INVALID_FILTER"
+	codeInvalidCursor        = "INVALID_CURSOR"
+	codeMissingQuery         = "MISSING_QUERY"
+	codeUnsupportedLocale    = "UNSUPPORTED_LOCALE"
+	codeUnparseableQuery     = "UNPARSEABLE_QUERY"
+	codeConflictingFilters   = "CONFLICTING_CONSTRAINTS"
+	codeInvalidSavedSearch   = "INVALID_SAVED_SEARCH"
+	codeSavedSearchExists    = "SAVED_SEARCH_ALREADY_EXISTS"
+	codeSavedSearchNotFound  = "SAVED_SEARCH_NOT_FOUND"
+	codeMissingAPIKey        = "MISSING_API_KEY"
+	codeInvalidAPIKey        = "INVALID_API_KEY"
+	codeInsufficientScope    = "INSUFFICIENT_SCOPE"
+	codeInvalidAPIKeyRequest = "INVALID_API_KEY_REQUEST"
+	codeAPIKeyNotFound       = "API_KEY_NOT_FOUND"
+	codeRateLimited          = "RATE_LIMITED"
+	codeQuotaExceeded        = "QUOTA_EXCEEDED"
+	codeInternalError        = "INTERNAL_ERROR"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	// problem types are relative URIs derived from the code, e.g. /problems/string-already-exists
+	problemTypePrefix = "/problems/"
+)
+
+// apiError is an error response with a stable code, written as RFC 7807 problem details.
+// Fields point at the offending parameter or body field, Extensions add members to the problem object
+type apiError struct {
+	Status     int
+	Code       string
+	Detail     string
+	Fields     []fieldError
+	Extensions map[string]any
+}
+
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func newAPIError(status int, code, detail string) *apiError {
+	return &apiError{Status: status, Code: code, Detail: detail}
+}
+
+// internalError hides the cause from the client, callers log it first
+func internalError(detail string) *apiError {
+	return newAPIError(http.StatusInternalServerError, codeInternalError, detail)
+}
+
+// invalidBody reports a request body that couldn't be decoded, or that was cut off at the size limit
+func invalidBody(description string, err error) *apiError {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return requestTooLarge(tooLarge.Limit)
+	}
+	return newAPIError(http.StatusBadRequest, codeInvalidRequestBody, fmt.Sprintf("Invalid %s: %v", description, err))
+}
+
+func requestTooLarge(maxBytes int64) *apiError {
+	return newAPIError(http.StatusRequestEntityTooLarge, codeRequestTooLarge, fmt.Sprintf("Request body is larger than %d bytes", maxBytes)).
+		with("max_bytes", maxBytes)
+}
+
+// unauthorized reports a missing or unusable API key, respondWithError adds the WWW-Authenticate challenge
+func unauthorized(code, detail string) *apiError {
+	return newAPIError(http.StatusUnauthorized, code, detail)
+}
+
+// invalidParameter reports a bad query parameter
+func invalidParameter(code, parameter, detail string) *apiError {
+	return newAPIError(http.StatusBadRequest, code, detail).withField(parameter, detail)
+}
+
+// invalidFilter reports a filter tree rejected by the schema, pointing at the path of the bad node
+func invalidFilter(err error) *apiError {
+	var validation *filter.ValidationError
+	if errors.As(err, &validation) {
+		return newAPIError(http.StatusBadRequest, codeInvalidFilter, "Invalid filter: "+err.Error()).withField(validation.Path, validation.Message)
+	}
+	return newAPIError(http.StatusBadRequest, codeInvalidFilter, "Invalid filter: "+err.Error())
+}
+
+// conflictingConstraints reports every pair of conditions that can't hold at once
+func conflictingConstraints(conflicts []filter.Conflict) *apiError {
+	apiErr := newAPIError(http.StatusUnprocessableEntity, codeConflictingFilters, "Conflicting constraints: "+conflicts[0].Message)
+	for _, conflict := range conflicts {
+		apiErr.withField(conflict.Field, conflict.Message)
+	}
+	return apiErr
+}
+
+func (e *apiError) Error() string {
+	return e.Detail
+}
+
+func (e *apiError) withField(field, message string) *apiError {
+	e.Fields = append(e.Fields, fieldError{Field: field, Message: message})
+	return e
+}
+
+func (e *apiError) with(member string, value any) *apiError {
+	if e.Extensions == nil {
+		e.Extensions = make(map[string]any)
+	}
+	e.Extensions[member] = value
+	return e
+}
+
+// problem renders the error as an RFC 7807 object for the request it answers
+func (e *apiError) problem(r *http.Request) map[string]any {
+	problem := map[string]any{}
+	for member, value := range e.Extensions {
+		problem[member] = value
+	}
+	problem["type"] = problemTypePrefix + strings.ToLower(strings.ReplaceAll(e.Code, "_", "-"))
+	problem["title"] = http.StatusText(e.Status)
+	problem["status"] = e.Status
+	problem["detail"] = e.Detail
+	problem["code"] = e.Code
+	if r != nil {
+		problem["instance"] = r.URL.Path
+		if requestID := requestIDFrom(r.Context()); requestID != "" {
+			problem["request_id"] = requestID
+		}
+	}
+	if len(e.Fields) > 0 {
+		problem["errors"] = e.Fields
+	}
+	return problem
+}
+
+// respondWithError writes err as problem details, anything that isn't an apiError is an internal error
+func respondWithError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := slog.Default()
+	if r != nil {
+		logger = requestLogger(r.Context())
+	}
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		logger.Error("unexpected error", "err", err)
+		apiErr = internalError("unexpected error")
+	}
+	body, marshalErr := json.Marshal(apiErr.problem(r))
+	if marshalErr != nil {
+		logger.Error("error encoding problem details", "err", marshalErr)
+		body = []byte(`{"title":"Internal Server Error","status":500,"code":"` + codeInternalError + `"}`)
+		apiErr = internalError("unable to encode error")
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	if apiErr.Status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="text-analyzer-api"`)
+	}
+	w.WriteHeader(apiErr.Status)
+	w.Write(body)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"github.com/google/uuid"
+)
+
+// scopes an API key can carry, admin grants every other scope too
+const (
+	scopeRead   = "read"
+	scopeWrite  = "write"
+	scopeDelete = "delete"
+	scopeAdmin  = "admin"
+)
+
+var apiKeyScopes = []string{scopeRead, scopeWrite, scopeDelete, scopeAdmin}
+
+const (
+	apiKeyHeader = "X-API-Key"
+	// keys are "tak_" followed by 32 random bytes, the prefix identifies a key in listings and logs
+	apiKeyMarker       = "tak_"
+	apiKeyPrefixLength = len(apiKeyMarker) + 8
+	maxAPIKeyNameLen   = 100
+	// apiKeyTouchInterval matches the interval TouchAPIKey updates last_used_at at
+	apiKeyTouchInterval = time.Minute
+)
+
+// apiKeyIdentity is the key a request was authenticated with
+type apiKeyIdentity struct {
+	// ID is empty for the admin key from the config, which isn't stored
+	ID     uuid.NullUUID
+	Name   string
+	Prefix string
+	Scopes []string
+}
+
+func (k *apiKeyIdentity) hasScope(scope string) bool {
+	for _, granted := range k.Scopes {
+		if granted == scope || granted == scopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeyFrom returns the key the request was authenticated with, if any
+func apiKeyFrom(ctx context.Context) (*apiKeyIdentity, bool) {
+	identity, ok := ctx.Value(apiKeyKey).(*apiKeyIdentity)
+	return identity, ok
+}
+
+// generateAPIKey returns a new key, its prefix and the hash stored in its place.
+// Keys are random, so a fast hash is enough to keep them unusable if the table leaks
+func generateAPIKey() (key, prefix, hash string) {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	key = apiKeyMarker + base64.RawURLEncoding.EncodeToString(secret)
+	return key, key[:apiKeyPrefixLength], hashAPIKey(key)
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// presentedAPIKey reads the key from "Authorization: Bearer <key>" or X-API-Key
+func presentedAPIKey(r *http.Request) (string, bool) {
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		scheme, key, found := strings.Cut(authorization, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(key), true
+		}
+		return "", false
+	}
+	key := strings.TrimSpace(r.Header.Get(apiKeyHeader))
+	return key, key != ""
+}
+
+// authenticate identifies the caller by its API key. Requests without a key continue anonymously and
+// are turned away by requireScope, requests with an unknown or revoked key are rejected here. Every
+// rejected key takes a token from its IP address' auth failure bucket, and once that is empty keys
+// from the address are refused before they are looked up
+func (cfg *apiConfig) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cfg.AuthEnabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") == "" && r.Header.Get(apiKeyHeader) == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if cfg.RateLimiter != nil {
+			if decision := cfg.RateLimiter.peek(classAuthFailure, cfg.authFailureClient(r)); !decision.allowed {
+				cfg.refuseRateLimited(w, r, classAuthFailure, decision, "Too many invalid API keys")
+				return
+			}
+		}
+		key, ok := presentedAPIKey(r)
+		if !ok {
+			cfg.rejectAPIKey(w, r, unauthorized(codeInvalidAPIKey, `Authorization must be "Bearer <key>"`))
+			return
+		}
+		identity, err := cfg.lookupAPIKey(r.Context(), key)
+		if err != nil {
+			cfg.rejectAPIKey(w, r, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), apiKeyKey, identity)
+		ctx = context.WithValue(ctx, loggerKey, requestLogger(ctx).With("api_key", identity.Prefix))
+		authenticated := r.WithContext(ctx)
+		next.ServeHTTP(w, authenticated)
+		// the mux records the route on the request it was given, outer middleware label by it
+		r.Pattern = authenticated.Pattern
+	})
+}
+
+// rejectAPIKey responds with err, counting it against the IP address when the key itself was refused
+func (cfg *apiConfig) rejectAPIKey(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *apiError
+	if cfg.RateLimiter != nil && errors.As(err, &apiErr) && apiErr.Status == http.StatusUnauthorized {
+		cfg.RateLimiter.take(classAuthFailure, cfg.authFailureClient(r))
+	}
+	respondWithError(w, r, err)
+}
+
+func (cfg *apiConfig) lookupAPIKey(ctx context.Context, key string) (*apiKeyIdentity, error) {
+	hash := hashAPIKey(key)
+	if cfg.AdminKeyHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(cfg.AdminKeyHash)) == 1 {
+		return &apiKeyIdentity{Name: "config admin key", Prefix: "config", Scopes: []string{scopeAdmin}}, nil
+	}
+
+	stored, err := cfg.DB.GetActiveAPIKeyByHash(ctx, hash)
+	if err == sql.ErrNoRows {
+		return nil, unauthorized(codeInvalidAPIKey, "API key is invalid or has been revoked")
+	}
+	if err != nil {
+		requestLogger(ctx).Error("error looking up API key", "err", err)
+		return nil, internalError("Unable to check API key")
+	}
+	// last use is only recorded to the minute, so busy keys don't write on every request
+	if !stored.LastUsedAt.Valid || time.Since(stored.LastUsedAt.Time) >= apiKeyTouchInterval {
+		if err := cfg.DB.TouchAPIKey(ctx, stored.ID); err != nil {
+			// last use is informational, it isn't worth failing the request over
+			requestLogger(ctx).Warn("error recording API key use", "api_key", stored.Prefix, "err", err)
+		}
+	}
+	return &apiKeyIdentity{
+		ID:     uuid.NullUUID{UUID: stored.ID, Valid: true},
+		Name:   stored.Name,
+		Prefix: stored.Prefix,
+		Scopes: stored.Scopes,
+	}, nil
+}
+
+// requireScope serves handler only to callers whose key carries scope
+func (cfg *apiConfig) requireScope(scope string, handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cfg.AuthEnabled {
+			handler(w, r)
+			return
+		}
+		identity, ok := apiKeyFrom(r.Context())
+		if !ok {
+			respondWithError(w, r, unauthorized(codeMissingAPIKey, "An API key is required, send it as \"Authorization: Bearer <key>\" or in "+apiKeyHeader))
+			return
+		}
+		if !identity.hasScope(scope) {
+			respondWithError(w, r, newAPIError(http.StatusForbidden, codeInsufficientScope, fmt.Sprintf("API key lacks the %q scope", scope)).
+				with("required_scope", scope))
+			return
+		}
+		handler(w, r)
+	})
+}
+
+// apiKeyParams validates a request to issue a key, the issuing key is recorded as its creator
+func apiKeyParams(r *http.Request, reqBody APIKeyRequest) (database.CreateAPIKeyParams, string, error) {
+	name := strings.TrimSpace(reqBody.Name)
+	if name == "" || len(name) > maxAPIKeyNameLen {
+		return database.CreateAPIKeyParams{}, "", newAPIError(http.StatusBadRequest, codeInvalidAPIKeyRequest, fmt.Sprintf(`"name" must be between 1 and %d characters`, maxAPIKeyNameLen)).
+			withField("name", "must not be empty")
+	}
+	if len(reqBody.Scopes) == 0 {
+		return database.CreateAPIKeyParams{}, "", newAPIError(http.StatusBadRequest, codeInvalidAPIKeyRequest, `"scopes" must list at least one scope`).
+			withField("scopes", "must be one or more of "+strings.Join(apiKeyScopes, ", "))
+	}
+	var scopes []string
+	seen := make(map[string]bool)
+	for i, scope := range reqBody.Scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !isAPIKeyScope(scope) {
+			return database.CreateAPIKeyParams{}, "", newAPIError(http.StatusBadRequest, codeInvalidAPIKeyRequest, fmt.Sprintf("Unknown scope %q", reqBody.Scopes[i])).
+				withField(fmt.Sprintf("scopes[%d]", i), "must be one of "+strings.Join(apiKeyScopes, ", "))
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	key, prefix, hash := generateAPIKey()
+	params := database.CreateAPIKeyParams{
+		Name:    name,
+		Prefix:  prefix,
+		KeyHash: hash,
+		Scopes:  scopes,
+	}
+	if issuer, ok := apiKeyFrom(r.Context()); ok {
+		params.CreatedBy = issuer.ID
+	}
+	return params, key, nil
+}
+
+func isAPIKeyScope(scope string) bool {
+	for _, known := range apiKeyScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
+
+func buildAPIKeyResponse(key database.ApiKey) APIKeyResponse {
+	response := APIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+	}
+	if key.CreatedBy.Valid {
+		response.CreatedBy = &key.CreatedBy.UUID
+	}
+	if key.LastUsedAt.Valid {
+		response.LastUsedAt = &key.LastUsedAt.Time
+	}
+	if key.RevokedAt.Valid {
+		response.RevokedAt = &key.RevokedAt.Time
+	}
+	return response
+}
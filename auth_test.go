@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"github.com/google/uuid"
+)
+
+const testAdminKey = "tak_config-admin-key-for-the-tests"
+
+// storedKey is a row of the api_keys table kept by apiKeyStore
+type storedKey struct {
+	id         uuid.UUID
+	scopes     string
+	lastUsedAt any
+	revoked    bool
+}
+
+// apiKeyStore is a database/sql driver answering the two queries authenticate sends,
+// GetActiveAPIKeyByHash and TouchAPIKey, from memory
+type apiKeyStore struct {
+	mu      sync.Mutex
+	keys    map[string]storedKey
+	touched []uuid.UUID
+}
+
+func (s *apiKeyStore) Connect(context.Context) (driver.Conn, error) { return s, nil }
+func (s *apiKeyStore) Driver() driver.Driver                        { return s }
+func (s *apiKeyStore) Open(string) (driver.Conn, error)             { return s, nil }
+func (s *apiKeyStore) Close() error                                 { return nil }
+func (s *apiKeyStore) Begin() (driver.Tx, error)                    { return nil, errors.New("not supported") }
+func (s *apiKeyStore) Prepare(string) (driver.Stmt, error)          { return nil, errors.New("not supported") }
+
+func (s *apiKeyStore) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !strings.Contains(query, "GetActiveAPIKeyByHash") {
+		return nil, errors.New("unexpected query: " + query)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[args[0].Value.(string)]
+	if !ok || key.revoked {
+		return &keyRows{}, nil
+	}
+	row := []driver.Value{key.id.String(), "test key", "tak_test", args[0].Value, key.scopes, nil, time.Now(), key.lastUsedAt, nil}
+	return &keyRows{rows: [][]driver.Value{row}}, nil
+}
+
+func (s *apiKeyStore) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if !strings.Contains(query, "TouchAPIKey") {
+		return nil, errors.New("unexpected query: " + query)
+	}
+	id, err := uuid.Parse(args[0].Value.(string))
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.touched = append(s.touched, id)
+	return driver.RowsAffected(1), nil
+}
+
+type keyRows struct {
+	rows [][]driver.Value
+}
+
+func (r *keyRows) Columns() []string {
+	return []string{"id", "name", "prefix", "key_hash", "scopes", "created_by", "created_at", "last_used_at", "revoked_at"}
+}
+
+func (r *keyRows) Close() error { return nil }
+
+func (r *keyRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// newAuthConfig enables auth with testAdminKey and the stored keys, each given as key => row
+func newAuthConfig(t *testing.T, keys map[string]storedKey) (*apiConfig, *apiKeyStore) {
+	t.Helper()
+	store := &apiKeyStore{keys: make(map[string]storedKey)}
+	for key, stored := range keys {
+		store.keys[hashAPIKey(key)] = stored
+	}
+	db := sql.OpenDB(store)
+	t.Cleanup(func() { db.Close() })
+
+	cfg := newTestConfig()
+	cfg.DB = database.New(db)
+	cfg.AuthEnabled = true
+	cfg.AdminKeyHash = hashAPIKey(testAdminKey)
+	return cfg, store
+}
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestPresentedAPIKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantKey string
+		wantOK  bool
+	}{
+		{"bearer", map[string]string{"Authorization": "Bearer tak_abc"}, "tak_abc", true},
+		{"scheme is case insensitive", map[string]string{"Authorization": "bearer  tak_abc "}, "tak_abc", true},
+		{"api key header", map[string]string{apiKeyHeader: " tak_abc "}, "tak_abc", true},
+		{"authorization wins", map[string]string{"Authorization": "Bearer tak_abc", apiKeyHeader: "tak_other"}, "tak_abc", true},
+		{"other scheme", map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}, "", false},
+		{"bearer without a key", map[string]string{"Authorization": "Bearer"}, "", false},
+		{"no key", map[string]string{}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/strings", nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			key, ok := presentedAPIKey(r)
+			if key != tt.wantKey || ok != tt.wantOK {
+				t.Errorf("presentedAPIKey = %q, %v, want %q, %v", key, ok, tt.wantKey, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestAuthenticateChecksScopes(t *testing.T) {
+	const (
+		readKey    = "tak_read-only-key"
+		writeKey   = "tak_read-write-key"
+		revokedKey = "tak_revoked-key"
+	)
+	cfg, _ := newAuthConfig(t, map[string]storedKey{
+		readKey:    {id: uuid.New(), scopes: "{read}"},
+		writeKey:   {id: uuid.New(), scopes: "{read,write}"},
+		revokedKey: {id: uuid.New(), scopes: "{admin}", revoked: true},
+	})
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		scope      string
+		wantStatus int
+		wantCode   string
+	}{
+		{"no key", "", "", scopeRead, http.StatusUnauthorized, codeMissingAPIKey},
+		{"stored key with the scope", "Authorization", "Bearer " + readKey, scopeRead, http.StatusOK, ""},
+		{"stored key in the api key header", apiKeyHeader, writeKey, scopeWrite, http.StatusOK, ""},
+		{"stored key without the scope", "Authorization", "Bearer " + readKey, scopeWrite, http.StatusForbidden, codeInsufficientScope},
+		{"admin key grants every scope", "Authorization", "Bearer " + testAdminKey, scopeDelete, http.StatusOK, ""},
+		{"revoked key", "Authorization", "Bearer " + revokedKey, scopeRead, http.StatusUnauthorized, codeInvalidAPIKey},
+		{"unknown key", apiKeyHeader, "tak_unknown", scopeRead, http.StatusUnauthorized, codeInvalidAPIKey},
+		{"malformed authorization", "Authorization", "Basic " + readKey, scopeRead, http.StatusUnauthorized, codeInvalidAPIKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := cfg.authenticate(cfg.requireScope(tt.scope, okHandler))
+			r := httptest.NewRequest(http.MethodGet, "/strings", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			res := w.Result()
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+			problem := decodeProblem(t, res)
+			if problem["code"] != tt.wantCode {
+				t.Errorf("code = %v, want %s", problem["code"], tt.wantCode)
+			}
+			if tt.wantStatus == http.StatusForbidden && problem["required_scope"] != tt.scope {
+				t.Errorf("required_scope = %v, want %s", problem["required_scope"], tt.scope)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && res.Header.Get("WWW-Authenticate") == "" {
+				t.Error("401 without a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestAuthDisabledServesAnonymousRequests(t *testing.T) {
+	cfg := newTestConfig()
+	handler := cfg.authenticate(cfg.requireScope(scopeAdmin, okHandler))
+	r := httptest.NewRequest(http.MethodGet, "/strings", nil)
+	r.Header.Set("Authorization", "Bearer tak_anything")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestAPIKeyUseIsRecordedOncePerInterval(t *testing.T) {
+	recent := uuid.New()
+	stale := uuid.New()
+	unused := uuid.New()
+	cfg, store := newAuthConfig(t, map[string]storedKey{
+		"tak_recent": {id: recent, scopes: "{read}", lastUsedAt: time.Now().Add(-apiKeyTouchInterval / 2)},
+		"tak_stale":  {id: stale, scopes: "{read}", lastUsedAt: time.Now().Add(-2 * apiKeyTouchInterval)},
+		"tak_unused": {id: unused, scopes: "{read}"},
+	})
+
+	for _, key := range []string{"tak_recent", "tak_stale", "tak_unused"} {
+		if _, err := cfg.lookupAPIKey(context.Background(), key); err != nil {
+			t.Fatalf("lookupAPIKey(%s): %v", key, err)
+		}
+	}
+	if len(store.touched) != 2 || store.touched[0] != stale || store.touched[1] != unused {
+		t.Errorf("touched = %v, want the stale %s and unused %s keys", store.touched, stale, unused)
+	}
+}
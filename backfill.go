@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/config"
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// backfillBatchSize is how many texts each backfill transaction recomputes
+const backfillBatchSize = 500
+
+// backfill recomputes the analyzer output of every stored text, for texts stored before an analyzer was
+// added or enabled: language, sentiment, profanity, the fuzzy key length, n-grams and hashes.
+// Texts are walked in ID order and each batch is committed on its own, rerunning it after an interruption is safe
+func (cfg *apiConfig) backfill(ctx context.Context) (int, error) {
+	var after uuid.UUID
+	done := 0
+	for {
+		texts, err := cfg.DB.GetTextsAfterID(ctx, database.GetTextsAfterIDParams{ID: after, Limit: backfillBatchSize})
+		if err != nil {
+			return done, fmt.Errorf("listing texts: %w", err)
+		}
+		if len(texts) == 0 {
+			return done, nil
+		}
+		// each batch is the root of its own trace, a single trace of a whole backfill would be too large to view
+		batchCtx, span := cfg.Tracer.start(ctx, "backfill batch", trace.SpanKindInternal, attribute.Int("texts", len(texts)))
+		err = cfg.inTx(batchCtx, func(q *database.Queries) error {
+			for _, text := range texts {
+				if err := cfg.backfillText(batchCtx, q, text); err != nil {
+					return fmt.Errorf("text %s: %w", text.ID, err)
+				}
+			}
+			return nil
+		})
+		endSpan(span, err)
+		if err != nil {
+			return done, err
+		}
+		done += len(texts)
+		after = texts[len(texts)-1].ID
+		slog.Info("backfilled texts", "texts", done)
+	}
+}
+
+// backfillText recomputes one text, the output of disabled analyzers is left as it is
+func (cfg *apiConfig) backfillText(ctx context.Context, q *database.Queries, text database.Text) error {
+	params := database.UpdateTextAnalysisParams{
+		ID:                 text.ID,
+		Language:           text.Language,
+		LanguageConfidence: text.LanguageConfidence,
+		Script:             text.Script,
+		SentimentScore:     text.SentimentScore,
+		HasProfanity:       text.HasProfanity,
+		FuzzyKeyLength:     fuzzyKeyLength(text.Value),
+	}
+	if cfg.Analyzers.IsEnabled(config.AnalyzerLanguage) {
+		_, span := cfg.Tracer.startStep(ctx, config.AnalyzerLanguage)
+		params.Language, params.LanguageConfidence, params.Script = detectLanguage(text.Value)
+		span.End()
+	}
+	if cfg.Analyzers.IsEnabled(config.AnalyzerSentiment) {
+		_, span := cfg.Tracer.startStep(ctx, config.AnalyzerSentiment)
+		params.SentimentScore = analyzeSentiment(text.Value)
+		span.End()
+	}
+	if cfg.Analyzers.IsEnabled(config.AnalyzerProfanity) {
+		_, span := cfg.Tracer.startStep(ctx, config.AnalyzerProfanity)
+		params.HasProfanity = len(cfg.Blocklist.matches(text.Value)) > 0
+		span.End()
+	}
+	if err := q.UpdateTextAnalysis(ctx, params); err != nil {
+		return fmt.Errorf("updating analysis: %w", err)
+	}
+	// both inserts skip rows that already exist
+	if cfg.storesNgrams(text.Value) {
+		stepCtx, span := cfg.Tracer.startStep(ctx, config.AnalyzerNgrams)
+		err := storeNgrams(stepCtx, q, text.ID, text.Value)
+		endSpan(span, err)
+		if err != nil {
+			return fmt.Errorf("saving n-gram counts: %w", err)
+		}
+	}
+	stepCtx, span := cfg.Tracer.startStep(ctx, "hashes")
+	err := storeHashes(stepCtx, q, text.ID, computeHashes(text.Value, cfg.HashAlgorithms))
+	endSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("saving text hashes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"github.com/google/uuid"
+)
+
+// storedTexts answers GetTextsAfterID from texts, which are sorted by ID
+func storedTexts(texts []database.Text) fakeQuery {
+	return func(args []driver.Value) ([][]driver.Value, error) {
+		after, limit := args[0].(string), int(args[1].(int64))
+		start := sort.Search(len(texts), func(i int) bool { return texts[i].ID.String() > after })
+		var rows [][]driver.Value
+		for _, text := range texts[start:min(start+limit, len(texts))] {
+			rows = append(rows, textRow(text))
+		}
+		return rows, nil
+	}
+}
+
+// newBackfillTexts stores n texts as they were before any analyzer ran, cycling through the values
+func newBackfillTexts(n int, values ...string) []database.Text {
+	texts := make([]database.Text, n)
+	for i := range texts {
+		texts[i] = database.Text{
+			ID:             uuid.MustParse(fmt.Sprintf("00000000-0000-0000-0000-%012d", i+1)),
+			Value:          values[i%len(values)],
+			CreatedAt:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Language:       undeterminedLanguage,
+			Script:         unknownScript,
+			FuzzyKeyLength: -1,
+		}
+	}
+	return texts
+}
+
+func newBackfillConfig(t *testing.T, texts []database.Text) (*apiConfig, *fakeDB) {
+	t.Helper()
+	cfg, fake := newFakeDBConfig(t, map[string]fakeQuery{
+		"GetTextsAfterID":    storedTexts(texts),
+		"UpdateTextAnalysis": answer(),
+		"CreateNgramCounts":  answer(),
+		"CreateTextHashes":   answer(),
+	})
+	words, err := loadBlocklist("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Blocklist = words
+	cfg.HashAlgorithms = selectHashAlgorithms(nil)
+	return cfg, fake
+}
+
+func TestBackfillRecomputesEveryText(t *testing.T) {
+	long := strings.Repeat("a", newTestConfig().Analyzers.NgramMaxLength+1)
+	texts := newBackfillTexts(backfillBatchSize+2, "Nous avons passé une belle journée au bord de la rivière", "what an awful ass!", long)
+	cfg, fake := newBackfillConfig(t, texts)
+
+	done, err := cfg.backfill(context.Background())
+	if err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	if done != len(texts) {
+		t.Errorf("backfilled %d texts, want %d", done, len(texts))
+	}
+	if fake.commits != 2 || fake.rollbacks != 0 {
+		t.Errorf("%d commits and %d rollbacks, want a commit per batch of %d", fake.commits, fake.rollbacks, backfillBatchSize)
+	}
+
+	updates := fake.calledWith("UpdateTextAnalysis")
+	if len(updates) != len(texts) {
+		t.Fatalf("%d texts updated, want %d", len(updates), len(texts))
+	}
+	for i, update := range updates[:3] {
+		text := texts[i]
+		language, confidence, script := detectLanguage(text.Value)
+		want := []driver.Value{text.ID.String(), language, confidence, script, analyzeSentiment(text.Value),
+			len(cfg.Blocklist.matches(text.Value)) > 0, int64(fuzzyKeyLength(text.Value))}
+		if !reflect.DeepEqual(update, want) {
+			t.Errorf("text %d updated with %v, want %v", i, update, want)
+		}
+	}
+	if updates[0][1] != "fr" || updates[1][5] != true || updates[1][4].(float64) >= 0 {
+		t.Errorf("updates = %v, %v, want french and a negative, profane second text", updates[0], updates[1])
+	}
+
+	// texts past the n-gram cutoff have theirs computed when asked for
+	if ngrams, want := len(fake.calledWith("CreateNgramCounts")), len(texts)-len(texts)/3; ngrams != want {
+		t.Errorf("n-grams stored for %d texts, want %d", ngrams, want)
+	}
+	if hashes := len(fake.calledWith("CreateTextHashes")); hashes != len(texts) {
+		t.Errorf("hashes stored for %d texts, want %d", hashes, len(texts))
+	}
+}
+
+func TestBackfillKeepsTheOutputOfDisabledAnalyzers(t *testing.T) {
+	texts := newBackfillTexts(1, "what an ass!")
+	texts[0].Language, texts[0].LanguageConfidence, texts[0].Script = "en", 0.5, "Latin"
+	texts[0].SentimentScore = 0.25
+	cfg, fake := newBackfillConfig(t, texts)
+	cfg.Analyzers.Enabled = nil
+
+	if _, err := cfg.backfill(context.Background()); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	updates := fake.calledWith("UpdateTextAnalysis")
+	// the fuzzy key length isn't an analyzer, dedup needs it for every text
+	want := []driver.Value{texts[0].ID.String(), "en", 0.5, "Latin", 0.25, false, int64(fuzzyKeyLength(texts[0].Value))}
+	if len(updates) != 1 || !reflect.DeepEqual(updates[0], want) {
+		t.Errorf("updates = %v, want %v", updates, want)
+	}
+	if ngrams := fake.calledWith("CreateNgramCounts"); len(ngrams) != 0 {
+		t.Errorf("n-grams stored with the analyzer disabled: %v", ngrams)
+	}
+}
+
+func TestBackfillRollsBackAFailedBatch(t *testing.T) {
+	texts := newBackfillTexts(3, "abc")
+	cfg, fake := newBackfillConfig(t, texts)
+	fake.queries["UpdateTextAnalysis"] = func(args []driver.Value) ([][]driver.Value, error) {
+		if args[0] == texts[1].ID.String() {
+			return nil, errors.New("connection reset")
+		}
+		return nil, nil
+	}
+
+	done, err := cfg.backfill(context.Background())
+	if err == nil || !strings.Contains(err.Error(), texts[1].ID.String()) || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("error = %v, want the failed text and its cause", err)
+	}
+	if done != 0 || fake.commits != 0 || fake.rollbacks != 1 {
+		t.Errorf("done = %d with %d commits and %d rollbacks, want nothing done and the batch rolled back", done, fake.commits, fake.rollbacks)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+)
+
+const (
+	dedupExact      = "exact"
+	dedupNormalized = "normalized"
+	dedupFuzzy      = "fuzzy"
+
+	defaultSimilarityThreshold = 0.9
+)
+
+// normalizeValue casefolds a string and collapses its whitespace, it is stored as texts.normalized_value
+func normalizeValue(str string) string {
+	return strings.ToLower(strings.Join(strings.Fields(str), " "))
+}
+
+// fuzzyKey is the normalized value with punctuation and symbols removed, used for similarity scoring
+func fuzzyKey(str string) string {
+	stripped := strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			return -1
+		}
+		return r
+	}, str)
+	return normalizeValue(stripped)
+}
+
+// fuzzyKeyLength is the length of the fuzzy key in runes, stored as texts.fuzzy_key_length
+func fuzzyKeyLength(str string) int32 {
+	return int32(utf8.RuneCountInString(fuzzyKey(str)))
+}
+
+// fuzzyKeyLengthBounds returns the shortest and longest fuzzy key that can be threshold similar to a key of
+// the given length, since the edit distance is at least the difference in length. similarity rounds to three
+// decimals, so the bounds are taken half a rounding step below the threshold
+func fuzzyKeyLengthBounds(length int32, threshold float64) (int32, int32) {
+	threshold -= 0.0005
+	if threshold <= 0 {
+		return 0, math.MaxInt32
+	}
+	return int32(math.Floor(float64(length) * threshold)), int32(min(math.Ceil(float64(length)/threshold), math.MaxInt32))
+}
+
+// similarity returns 1 minus the normalized Levenshtein distance between the fuzzy keys of a and b
+func similarity(a, b string) float64 {
+	keyA, keyB := []rune(fuzzyKey(a)), []rune(fuzzyKey(b))
+	longest := max(len(keyA), len(keyB))
+	if longest == 0 {
+		return 1
+	}
+	score := 1 - float64(levenshtein(keyA, keyB))/float64(longest)
+	return math.Round(score*1000) / 1000
+}
+
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// validateDedupPolicy fills in the default dedup policy on a create request and returns the similarity
+// threshold of the fuzzy policy. A threshold of 0 would make every stored text a duplicate, so it is rejected
+func validateDedupPolicy(reqBody *RequestBody) (float64, error) {
+	if reqBody.Dedup == "" {
+		reqBody.Dedup = dedupExact
+	}
+	switch reqBody.Dedup {
+	case dedupExact, dedupNormalized:
+		return 0, nil
+	case dedupFuzzy:
+		if reqBody.SimilarityThreshold == nil {
+			return defaultSimilarityThreshold, nil
+		}
+		threshold := *reqBody.SimilarityThreshold
+		if threshold <= 0 || threshold > 1 {
+			return 0, newAPIError(http.StatusBadRequest, codeInvalidRequestBody, `"similarity_threshold" must be greater than 0 and at most 1`).
+				withField("similarity_threshold", "must be greater than 0 and at most 1")
+		}
+		return threshold, nil
+	}
+	return 0, newAPIError(http.StatusBadRequest, codeInvalidRequestBody, fmt.Sprintf(`invalid "dedup" policy %q: must be exact, normalized or fuzzy`, reqBody.Dedup)).
+		withField("dedup", "must be exact, normalized or fuzzy")
+}
+
+// findDuplicate looks for a stored text that conflicts with value under the given policy.
+// It returns the best match and its similarity, or ok=false when there is no conflict
+func (cfg *apiConfig) findDuplicate(ctx context.Context, value, policy string, threshold float64) (database.Text, float64, bool, error) {
+	// an exact match conflicts under every policy
+	text, err := cfg.DB.GetText(ctx, value)
+	if err == nil {
+		return text, 1, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.Text{}, 0, false, err
+	}
+
+	switch policy {
+	case dedupNormalized:
+		text, err := cfg.DB.GetTextByNormalizedValue(ctx, normalizeValue(value))
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.Text{}, 0, false, nil
+		}
+		if err != nil {
+			return database.Text{}, 0, false, err
+		}
+		return text, similarity(value, text.Value), true, nil
+
+	case dedupFuzzy:
+		minLength, maxLength := fuzzyKeyLengthBounds(fuzzyKeyLength(value), threshold)
+		candidates, err := cfg.DB.GetTextsByFuzzyKeyLength(ctx, database.GetTextsByFuzzyKeyLengthParams{
+			MinLength: minLength,
+			MaxLength: maxLength,
+		})
+		if err != nil {
+			return database.Text{}, 0, false, err
+		}
+
+		var best database.Text
+		bestScore := -1.0
+		for _, candidate := range candidates {
+			score := similarity(value, candidate.Value)
+			if score > bestScore {
+				best, bestScore = candidate, score
+			}
+		}
+		if bestScore >= threshold {
+			return best, bestScore, true, nil
+		}
+	}
+
+	return database.Text{}, 0, false, nil
+}
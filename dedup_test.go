@@ -0,0 +1,157 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizeValue(t *testing.T) {
+	tests := []struct {
+		str  string
+		want string
+	}{
+		{"Hello World", "hello world"},
+		{"  Hello \t\n World  ", "hello world"},
+		{"ÇA VA", "ça va"},
+		{"Hello, World!", "hello, world!"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeValue(tt.str); got != tt.want {
+			t.Errorf("normalizeValue(%q) = %q, want %q", tt.str, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzyKey(t *testing.T) {
+	tests := []struct {
+		str  string
+		want string
+	}{
+		{"Hello, World!", "hello world"},
+		{"it's a - test", "its a test"},
+		{"price: $5 + 10%", "price 5 10"},
+		{"«Ça va ?»", "ça va"},
+		{"👍 great 👍", "great"},
+		{"!!!", ""},
+	}
+	for _, tt := range tests {
+		if got := fuzzyKey(tt.str); got != tt.want {
+			t.Errorf("fuzzyKey(%q) = %q, want %q", tt.str, got, tt.want)
+		}
+		if got, want := fuzzyKeyLength(tt.str), int32(len([]rune(tt.want))); got != want {
+			t.Errorf("fuzzyKeyLength(%q) = %d, want %d", tt.str, got, want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"same", "same", 0},
+		{"héllo", "hello", 1},
+		{"日本語", "日本", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := levenshtein([]rune(tt.b), []rune(tt.a)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"Hello, World!", "hello world", 1},
+		{"kitten", "sitting", 0.571},
+		{"abc", "xyz", 0},
+		{"!!!", "???", 1},
+	}
+	for _, tt := range tests {
+		if got := similarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// the prefilter must never drop a stored text that similarity would score at or above the threshold
+func TestFuzzyKeyLengthBoundsKeepEverySimilarKey(t *testing.T) {
+	thresholds := []float64{0.001, 0.1, 0.5, 0.75, 0.9, 0.95, 0.999, 1}
+	for _, threshold := range thresholds {
+		for length := 0; length <= 2100; length++ {
+			minLength, maxLength := fuzzyKeyLengthBounds(int32(length), threshold)
+			// two keys are at least their difference in length apart, so this is the best similarity they can
+			// score, rounded as similarity rounds it
+			for _, other := range []int{int(minLength) - 1, int(maxLength) + 1} {
+				if other < 0 {
+					continue
+				}
+				best := 1 - math.Abs(float64(length-other))/float64(max(length, other))
+				if best = math.Round(best*1000) / 1000; best >= threshold {
+					t.Fatalf("threshold %v, length %d: bounds [%d, %d] drop a key of length %d that can score %v",
+						threshold, length, minLength, maxLength, other, best)
+				}
+			}
+		}
+	}
+}
+
+func TestFuzzyKeyLengthBounds(t *testing.T) {
+	tests := []struct {
+		length           int32
+		threshold        float64
+		wantMin, wantMax int32
+	}{
+		{10, 0.9, 8, 12},
+		{100, 1, 99, 101},
+		{0, 0.5, 0, 0},
+		{10, 0.0001, 0, math.MaxInt32},
+	}
+	for _, tt := range tests {
+		minLength, maxLength := fuzzyKeyLengthBounds(tt.length, tt.threshold)
+		if minLength != tt.wantMin || maxLength != tt.wantMax {
+			t.Errorf("fuzzyKeyLengthBounds(%d, %v) = %d, %d, want %d, %d", tt.length, tt.threshold, minLength, maxLength, tt.wantMin, tt.wantMax)
+		}
+	}
+}
+
+func TestValidateDedupPolicy(t *testing.T) {
+	threshold := func(value float64) *float64 { return &value }
+	tests := []struct {
+		name          string
+		body          RequestBody
+		wantPolicy    string
+		wantThreshold float64
+		wantErr       bool
+	}{
+		{"exact by default", RequestBody{}, dedupExact, 0, false},
+		{"normalized", RequestBody{Dedup: dedupNormalized}, dedupNormalized, 0, false},
+		{"fuzzy default threshold", RequestBody{Dedup: dedupFuzzy}, dedupFuzzy, defaultSimilarityThreshold, false},
+		{"fuzzy threshold", RequestBody{Dedup: dedupFuzzy, SimilarityThreshold: threshold(0.5)}, dedupFuzzy, 0.5, false},
+		{"zero threshold", RequestBody{Dedup: dedupFuzzy, SimilarityThreshold: threshold(0)}, dedupFuzzy, 0, true},
+		{"threshold above 1", RequestBody{Dedup: dedupFuzzy, SimilarityThreshold: threshold(1.5)}, dedupFuzzy, 0, true},
+		{"unknown policy", RequestBody{Dedup: "phonetic"}, "phonetic", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := tt.body
+			got, err := validateDedupPolicy(&body)
+			if (err != nil) != tt.wantErr || got != tt.wantThreshold || body.Dedup != tt.wantPolicy {
+				t.Errorf("validateDedupPolicy = %v, %v with policy %s, want %v with policy %s, error %v",
+					got, err, body.Dedup, tt.wantThreshold, tt.wantPolicy, tt.wantErr)
+			}
+		})
+	}
+}
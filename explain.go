@@ -0,0 +1,201 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/filter"
+)
+
+// scores of the alternative readings, relative to 1 for the parser's own interpretation
+const (
+	disjunctionAlternativeScore = 0.7
+	guessAlternativeScore       = 0.6
+	groupingAlternativeScore    = 0.5
+	inclusiveAlternativeScore   = 0.3
+)
+
+var nlTokenKindNames = map[nlTokenKind]string{
+	tokenWord:   "word",
+	tokenNumber: "number",
+	tokenQuoted: "quoted",
+	tokenSymbol: "symbol",
+}
+
+// explainNaturalLanguageQuery parses a query and reports which tokens produced which conditions,
+// what was ignored, which constraints contradict each other and how else the query could be read.
+// When the query can't be parsed the explanation covers what was read before the error
+func explainNaturalLanguageQuery(query string, lex *nlLexicon) (ExplainResponse, error) {
+	p := newNLParser(query, lex)
+	parsed, err := p.parse(query)
+	if err == nil {
+		err = parsed.Validate()
+	}
+
+	explanation := ExplainResponse{
+		Original:       query,
+		Locale:         lex.locale,
+		Tokens:         []ExplainToken{},
+		Spans:          []ExplainSpan{},
+		Ignored:        []string{},
+		Interpretation: parsed,
+		Alternatives:   []ExplainAlternative{},
+	}
+
+	roles := make([]string, len(p.tokens))
+	for i, token := range p.tokens {
+		word := token.word
+		switch {
+		case lex.andWords[word] || lex.orWords[word] || word == "," || word == "(" || word == ")":
+			roles[i] = "operator"
+		case lex.negations[word]:
+			roles[i] = "negation"
+		case lex.fillers[word]:
+			roles[i] = "filler"
+		}
+	}
+	for _, span := range p.spans {
+		role := "order"
+		if span.node != nil {
+			role = "filter"
+		}
+		for i := span.start; i < span.end; i++ {
+			roles[i] = role
+		}
+		if span.end > span.start {
+			start, end := p.tokens[span.start].start, p.tokens[span.end-1].end
+			explanation.Spans = append(explanation.Spans, ExplainSpan{
+				Text:   query[start:end],
+				Start:  start,
+				End:    end,
+				Filter: span.node,
+				Sort:   span.sort,
+				Limit:  span.limit,
+			})
+		}
+	}
+	for _, ignored := range p.ignored {
+		explanation.Ignored = append(explanation.Ignored, ignored.text)
+		for i, token := range p.tokens {
+			if token.start == ignored.start {
+				roles[i] = "ignored"
+			}
+		}
+	}
+	for i, token := range p.tokens {
+		if roles[i] == "" {
+			roles[i] = "ignored"
+		}
+		explanation.Tokens = append(explanation.Tokens, ExplainToken{
+			Text:  token.text,
+			Kind:  nlTokenKindNames[token.kind],
+			Start: token.start,
+			End:   token.end,
+			Role:  roles[i],
+		})
+	}
+
+	if err != nil {
+		return explanation, err
+	}
+	explanation.Conflicts = filter.Conflicts(parsed.Where)
+	explanation.Alternatives = alternativeReadings(parsed.Where, p.guesses, explanation.Conflicts)
+	return explanation, nil
+}
+
+// alternativeReadings rewrites the parsed tree the ways the words could also have been meant
+func alternativeReadings(node filter.Node, guesses []nlGuess, conflicts []filter.Conflict) []ExplainAlternative {
+	alternatives := []ExplainAlternative{}
+	add := func(score float64, reason string, alternative filter.Node) {
+		if reflect.DeepEqual(alternative, node) {
+			return
+		}
+		for _, existing := range alternatives {
+			if reflect.DeepEqual(existing.Filter, alternative) {
+				return
+			}
+		}
+		alternatives = append(alternatives, ExplainAlternative{Score: score, Reason: reason, Filter: alternative})
+	}
+
+	// "french and german strings" usually means either language
+	if len(conflicts) > 0 && node.And != nil {
+		add(disjunctionAlternativeScore, "'and' read as 'or', since the conditions can't all hold at once", filter.Or(node.And...))
+	}
+	for _, guess := range guesses {
+		add(guessAlternativeScore, guess.reason, replaceNode(node, guess.chosen, guess.other))
+	}
+	if regrouped, ok := regroupAndOr(node); ok {
+		add(groupingAlternativeScore, "'and' grouped before 'or' instead of after it", regrouped)
+	}
+	add(inclusiveAlternativeScore, "'more than' and 'less than' read as inclusive", inclusiveBounds(node))
+
+	sort.SliceStable(alternatives, func(i, j int) bool {
+		return alternatives[i].Score > alternatives[j].Score
+	})
+	for i := range alternatives {
+		alternatives[i].Rank = i + 1
+	}
+	return alternatives
+}
+
+// replaceNode returns a copy of the tree with every node equal to old swapped for replacement
+func replaceNode(node, old, replacement filter.Node) filter.Node {
+	if reflect.DeepEqual(node, old) {
+		return replacement
+	}
+	return mapChildren(node, func(child filter.Node) filter.Node {
+		return replaceNode(child, old, replacement)
+	})
+}
+
+// regroupAndOr reads "a and b or c" as "a and (b or c)" rather than "(a and b) or c"
+func regroupAndOr(node filter.Node) (filter.Node, bool) {
+	if len(node.Or) != 2 {
+		return filter.Node{}, false
+	}
+	left, right := node.Or[0], node.Or[1]
+	if len(left.And) > 1 {
+		last := len(left.And) - 1
+		return filter.And(append(append([]filter.Node{}, left.And[:last]...), filter.Or(left.And[last], right))...), true
+	}
+	if len(right.And) > 1 {
+		return filter.And(append([]filter.Node{filter.Or(left, right.And[0])}, right.And[1:]...)...), true
+	}
+	return filter.Node{}, false
+}
+
+// inclusiveBounds turns every strict comparison into its inclusive form
+func inclusiveBounds(node filter.Node) filter.Node {
+	if node.IsCondition() {
+		switch node.Op {
+		case filter.OpGt:
+			node.Op = filter.OpGte
+		case filter.OpLt:
+			node.Op = filter.OpLte
+		}
+		return node
+	}
+	return mapChildren(node, inclusiveBounds)
+}
+
+// mapChildren rebuilds a boolean node with fn applied to each of its children
+func mapChildren(node filter.Node, fn func(filter.Node) filter.Node) filter.Node {
+	switch {
+	case node.And != nil:
+		children := make([]filter.Node, len(node.And))
+		for i, child := range node.And {
+			children[i] = fn(child)
+		}
+		return filter.And(children...)
+	case node.Or != nil:
+		children := make([]filter.Node, len(node.Or))
+		for i, child := range node.Or {
+			children[i] = fn(child)
+		}
+		return filter.Or(children...)
+	case node.Not != nil:
+		return filter.Not(fn(*node.Not))
+	}
+	return node
+}
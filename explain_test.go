@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func getExplanation(t *testing.T, server *httptest.Server, query, lang string) *http.Response {
+	t.Helper()
+	params := url.Values{"query": {query}}
+	if lang != "" {
+		params.Set("lang", lang)
+	}
+	res, err := http.Get(server.URL + "/strings/filter-by-natural-language/explain?" + params.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+func TestExplainNaturalLanguageQuery(t *testing.T) {
+	server := newTestServer(t)
+
+	res := getExplanation(t, server, "palindromes longer than 5", "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	if contentType := res.Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+	if contentLanguage := res.Header.Get("Content-Language"); contentLanguage != "en" {
+		t.Errorf("Content-Language = %q, want en", contentLanguage)
+	}
+	var explanation ExplainResponse
+	if err := json.NewDecoder(res.Body).Decode(&explanation); err != nil {
+		t.Fatal(err)
+	}
+	if len(explanation.Interpretation.Where.And) != 2 || len(explanation.Spans) != 2 || explanation.Rule != "" {
+		t.Errorf("explanation = %+v, want two conditions read by the grammar", explanation)
+	}
+}
+
+func TestExplainErrorsAreProblemDetails(t *testing.T) {
+	server := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		query      string
+		lang       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"missing query", "", "", http.StatusBadRequest, codeMissingQuery},
+		{"unsupported locale", "palindromes", "xx", http.StatusBadRequest, codeUnsupportedLocale},
+		{"unparseable", "gibberish zzz", "", http.StatusBadRequest, codeUnparseableQuery},
+		{"out of range", "words > 99999999999", "", http.StatusBadRequest, codeUnparseableQuery},
+		{"conflicting constraints", "french and german strings", "", http.StatusUnprocessableEntity, codeConflictingFilters},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := getExplanation(t, server, tt.query, tt.lang)
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			problem := decodeProblem(t, res)
+			if problem["code"] != tt.wantCode {
+				t.Errorf("code = %v, want %s", problem["code"], tt.wantCode)
+			}
+			// once the query is read, the explanation comes with the error
+			explanation, ok := problem["explanation"].(map[string]any)
+			if wantExplanation := tt.wantCode == codeUnparseableQuery || tt.wantCode == codeConflictingFilters; ok != wantExplanation {
+				t.Fatalf("explanation = %v, want one: %v", problem["explanation"], wantExplanation)
+			}
+			if ok && explanation["original"] != tt.query {
+				t.Errorf("explanation of %v, want %q", explanation["original"], tt.query)
+			}
+		})
+	}
+}
+
+func TestExplainUsesTheConfiguredInterpreter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	rules := `{"rules": [
+		{"name": "tweet-sized", "pattern": "tweets?", "filter": {"field": "length", "op": "<=", "value": 280}},
+		{"name": "contradiction", "pattern": "nothing", "filter": {"and": [
+			{"field": "length", "op": ">", "value": 5}, {"field": "length", "op": "<", "value": 2}]}}
+	]}`
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	interpreter, err := newRuleInterpreter(path, grammarInterpreter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := newTestConfig()
+	cfg.Interpreter = instrumentedInterpreter{interpreter: interpreter, metrics: cfg.Metrics}
+	server := httptest.NewServer(newRouter(cfg))
+	defer server.Close()
+
+	res := getExplanation(t, server, " tweets ", "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	var explanation ExplainResponse
+	if err := json.NewDecoder(res.Body).Decode(&explanation); err != nil {
+		t.Fatal(err)
+	}
+	if explanation.Rule != "tweet-sized" || explanation.Interpretation.Where.Field != "length" {
+		t.Errorf("explanation = %+v, want the tweet-sized rule's filter", explanation)
+	}
+	if len(explanation.Spans) != 1 || explanation.Spans[0].Text != "tweets" || explanation.Spans[0].Start != 1 || explanation.Spans[0].End != 7 {
+		t.Errorf("spans = %+v, want the whole trimmed query", explanation.Spans)
+	}
+
+	if res := getExplanation(t, server, "nothing", ""); res.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("conflicting rule: status = %d, want %d", res.StatusCode, http.StatusUnprocessableEntity)
+	}
+
+	// queries no rule matches are explained by the fallback grammar
+	res = getExplanation(t, server, "palindromes", "")
+	explanation = ExplainResponse{}
+	if err := json.NewDecoder(res.Body).Decode(&explanation); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || explanation.Rule != "" || len(explanation.Tokens) != 1 {
+		t.Errorf("status = %d, explanation = %+v, want the grammar's reading", res.StatusCode, explanation)
+	}
+}
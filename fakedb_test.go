@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+)
+
+// fakeQuery answers one query with its rows, an exec reports as many affected rows as it returns
+type fakeQuery func(args []driver.Value) ([][]driver.Value, error)
+
+// fakeCall is a statement fakeDB ran
+type fakeCall struct {
+	name string
+	args []driver.Value
+}
+
+// fakeDB is a database/sql driver answering every query by its sqlc name from the fakeQuery the test
+// registered for it, and recording the calls. Transactions only count commits and rollbacks
+type fakeDB struct {
+	mu        sync.Mutex
+	queries   map[string]fakeQuery
+	calls     []fakeCall
+	commits   int
+	rollbacks int
+	// ping answers db.PingContext, nil means the database is up
+	ping func(ctx context.Context) error
+}
+
+// newFakeDB opens a pool on a fakeDB answering the given queries
+func newFakeDB(t *testing.T, queries map[string]fakeQuery) (*fakeDB, *sql.DB) {
+	t.Helper()
+	fake := &fakeDB{queries: queries}
+	db := sql.OpenDB(fake)
+	t.Cleanup(func() { db.Close() })
+	return fake, db
+}
+
+// newFakeDBConfig is newTestConfig backed by a fakeDB answering the given queries
+func newFakeDBConfig(t *testing.T, queries map[string]fakeQuery) (*apiConfig, *fakeDB) {
+	t.Helper()
+	fake, db := newFakeDB(t, queries)
+	cfg := newTestConfig()
+	cfg.Conn = db
+	cfg.DB = newQueries(db, cfg.Metrics, cfg.Tracer)
+	return cfg, fake
+}
+
+// calledWith returns the arguments of every call to the named query, in order
+func (f *fakeDB) calledWith(name string) [][]driver.Value {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var args [][]driver.Value
+	for _, call := range f.calls {
+		if call.name == name {
+			args = append(args, call.args)
+		}
+	}
+	return args
+}
+
+func (f *fakeDB) run(query string, named []driver.NamedValue) ([][]driver.Value, error) {
+	name := queryName(query)
+	args := make([]driver.Value, len(named))
+	for i, arg := range named {
+		args[i] = arg.Value
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, fakeCall{name: name, args: args})
+	answer, ok := f.queries[name]
+	f.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unexpected query %s", name)
+	}
+	return answer(args)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return f, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return f }
+func (f *fakeDB) Open(string) (driver.Conn, error)             { return f, nil }
+func (f *fakeDB) Close() error                                 { return nil }
+func (f *fakeDB) Prepare(string) (driver.Stmt, error)          { return nil, errors.New("not supported") }
+func (f *fakeDB) Begin() (driver.Tx, error) {
+	return f.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (f *fakeDB) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return fakeTx{f}, nil
+}
+
+func (f *fakeDB) Ping(ctx context.Context) error {
+	if f.ping == nil {
+		return nil
+	}
+	return f.ping(ctx)
+}
+
+func (f *fakeDB) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := f.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{rows: rows}, nil
+}
+
+func (f *fakeDB) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	rows, err := f.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(len(rows)), nil
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (tx fakeTx) Commit() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.commits++
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.rollbacks++
+	return nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// textRow is a texts row in the column order every texts query selects
+func textRow(text database.Text) []driver.Value {
+	var createdBy driver.Value
+	if text.CreatedByKey.Valid {
+		createdBy = text.CreatedByKey.UUID.String()
+	}
+	return []driver.Value{
+		text.ID.String(), text.Value, int64(text.Length), text.IsPalindrome, int64(text.WordCount), text.Sha256Hash,
+		text.CreatedAt, text.Language, text.LanguageConfidence, text.Script, text.NormalizedValue, text.SentimentScore,
+		text.HasProfanity, createdBy, int64(text.FuzzyKeyLength),
+	}
+}
+
+// answer always returns the same rows
+func answer(rows ...[]driver.Value) fakeQuery {
+	return func([]driver.Value) ([][]driver.Value, error) {
+		return rows, nil
+	}
+}
@@ -3,114 +3,167 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
-	"regexp"
+	"net/url"
 	"strconv"
 	"strings"
-	"time"
+	"unicode/utf8"
 
+	"github.com/HamstimusPrime/text-analyzer-api/internal/config"
 	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"github.com/HamstimusPrime/text-analyzer-api/internal/filter"
+	"github.com/google/uuid"
 )
 
 func (cfg *apiConfig) CreateText(w http.ResponseWriter, r *http.Request) {
 	// Parse Request Body
 	reqBody, err := parseReqBody(r, RequestBody{})
 	if err != nil {
-		log.Fatalf("unable to parse request body, err: %v\n", err)
+		respondWithError(w, r, invalidBody("request body", err))
+		return
+	}
 
-		errMsg := "Internal server error!"
-		respondWithError(w, errMsg, 500)
+	if err := validateString(reqBody); err != nil {
+		var apiErr *apiError
+		if errors.As(err, &apiErr) && apiErr.Code == codeNumericString {
+			cfg.Metrics.textsRejected.WithLabelValues("numeric").Inc()
+		}
+		respondWithError(w, r, err)
 		return
 	}
 
-	errorCode, errMsg, err := validateString(reqBody, cfg)
+	threshold, err := validateDedupPolicy(&reqBody)
 	if err != nil {
-		respondWithError(w, errMsg, errorCode)
+		respondWithError(w, r, err)
 		return
 	}
-	//setup inputs for Text-string rows
-	createTextParams := database.CreateTextParams{
-		Value:        reqBody.Value,
-		Length:       charCount(reqBody.Value),
-		IsPalindrome: isPalindrome(reqBody.Value),
-		WordCount:    wordCount(reqBody.Value),
-		Sha256Hash:   generateHash(reqBody.Value),
-	}
-	//on creation of new Text-string, return ID of created string
-	stringID, err := cfg.DB.CreateText(context.Background(), createTextParams)
+
+	//check for an existing text that conflicts under the requested dedup policy
+	ctx, span := cfg.Tracer.startStep(r.Context(), "dedup")
+	existing, score, found, err := cfg.findDuplicate(ctx, reqBody.Value, reqBody.Dedup, threshold)
+	endSpan(span, err)
 	if err != nil {
-		fmt.Printf("error: %v", err)
-		errMsg := "unable to save Text to DB"
-		respondWithError(w, errMsg, http.StatusInternalServerError)
+		requestLogger(r.Context()).Error("unable to check for duplicate strings", "err", err)
+		respondWithError(w, r, internalError("unable to check for duplicate strings"))
+		return
+	}
+	if found {
+		charCounts, err := cfg.DB.GetCharacterCountsByID(r.Context(), existing.ID)
+		if err != nil {
+			charCounts = []database.GetCharacterCountsByIDRow{}
+		}
+		duplicate := newAPIError(http.StatusConflict, codeStringAlreadyExists, "String already exists in the system").
+			with("policy", reqBody.Dedup).
+			with("similarity", score).
+			with("existing", buildTextResponse(existing, charCounts))
+		cfg.Metrics.textsRejected.WithLabelValues("duplicate").Inc()
+		respondWithError(w, r, duplicate)
 		return
 	}
 
-	//setup inputs for character count rows
-	uniqueChars := getUniqueChars(reqBody.Value)
-	for character, charCount := range uniqueChars {
-		createCharCountParams := database.CreateCharCountParams{
-			StringID:        stringID, //use ID of created string as string ID for each character
-			Character:       string(character),
-			UniqueCharCount: charCount,
+	//setup inputs for Text-string rows, disabled analyzers leave their columns at the schema defaults
+	createTextParams := database.CreateTextParams{
+		Value:           reqBody.Value,
+		Length:          charCount(reqBody.Value),
+		IsPalindrome:    isPalindrome(reqBody.Value),
+		WordCount:       wordCount(reqBody.Value),
+		Sha256Hash:      generateHash(reqBody.Value),
+		Language:        undeterminedLanguage,
+		Script:          unknownScript,
+		NormalizedValue: normalizeValue(reqBody.Value),
+		FuzzyKeyLength:  fuzzyKeyLength(reqBody.Value),
+	}
+	if identity, ok := apiKeyFrom(r.Context()); ok {
+		createTextParams.CreatedByKey = identity.ID
+	}
+	if cfg.Analyzers.IsEnabled(config.AnalyzerLanguage) {
+		_, span := cfg.Tracer.startStep(r.Context(), config.AnalyzerLanguage)
+		createTextParams.Language, createTextParams.LanguageConfidence, createTextParams.Script = detectLanguage(reqBody.Value)
+		span.End()
+	}
+	if cfg.Analyzers.IsEnabled(config.AnalyzerSentiment) {
+		_, span := cfg.Tracer.startStep(r.Context(), config.AnalyzerSentiment)
+		createTextParams.SentimentScore = analyzeSentiment(reqBody.Value)
+		span.End()
+	}
+	if cfg.Analyzers.IsEnabled(config.AnalyzerProfanity) {
+		_, span := cfg.Tracer.startStep(r.Context(), config.AnalyzerProfanity)
+		createTextParams.HasProfanity = len(cfg.Blocklist.matches(reqBody.Value)) > 0
+		span.End()
+	}
+	//store the text with its quota use, character counts, n-grams and hashes in one transaction,
+	//so a failure part way leaves nothing behind
+	var stringID uuid.UUID
+	err = cfg.inTx(r.Context(), func(q *database.Queries) error {
+		if err := cfg.consumeTextQuota(w, r, q); err != nil {
+			return err
+		}
+		var err error
+		stringID, err = q.CreateText(r.Context(), createTextParams)
+		if err != nil {
+			return fmt.Errorf("saving text: %w", err)
+		}
+		ctx, span := cfg.Tracer.startStep(r.Context(), "char counts")
+		err = storeCharCounts(ctx, q, stringID, reqBody.Value)
+		endSpan(span, err)
+		if err != nil {
+			return fmt.Errorf("saving character counts: %w", err)
+		}
+		//n-grams are stored so corpus aggregation doesn't have to rescan every value
+		if cfg.storesNgrams(reqBody.Value) {
+			ctx, span := cfg.Tracer.startStep(r.Context(), config.AnalyzerNgrams)
+			err := storeNgrams(ctx, q, stringID, reqBody.Value)
+			endSpan(span, err)
+			if err != nil {
+				return fmt.Errorf("saving n-gram counts: %w", err)
+			}
 		}
-		//create entry into char_count table
-		err := cfg.DB.CreateCharCount(context.Background(), createCharCountParams)
+		//every configured hash is stored so the text can be looked up by any algorithm
+		ctx, span = cfg.Tracer.startStep(r.Context(), "hashes")
+		err = storeHashes(ctx, q, stringID, computeHashes(reqBody.Value, cfg.HashAlgorithms))
+		endSpan(span, err)
 		if err != nil {
-			cfg.DB.DeleteTextWithID(context.Background(), stringID)
+			return fmt.Errorf("saving text hashes: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		var apiErr *apiError
+		if errors.As(err, &apiErr) {
+			respondWithError(w, r, err)
 			return
 		}
+		requestLogger(r.Context()).Error("unable to save Text to DB", "err", err)
+		respondWithError(w, r, internalError("unable to save Text to DB"))
+		return
 	}
 
 	//get character counts for the created text
-	charCounts, err := cfg.DB.GetCharacterCountsByID(context.Background(), stringID)
+	charCounts, err := cfg.DB.GetCharacterCountsByID(r.Context(), stringID)
 	if err != nil {
-		fmt.Printf("error: %v", err)
-		errMsg := "unable to get character counts from DB"
-		respondWithError(w, errMsg, http.StatusInternalServerError)
+		requestLogger(r.Context()).Error("unable to get character counts from DB", "err", err)
+		respondWithError(w, r, internalError("unable to get character counts from DB"))
 		return
 	}
 
 	//get text information by ID
-	textInfo, err := cfg.DB.GetTextByID(context.Background(), stringID)
+	textInfo, err := cfg.DB.GetTextByID(r.Context(), stringID)
 	if err != nil {
-		fmt.Printf("error: %v", err)
-		errMsg := "unable to get text info from DB"
-		respondWithError(w, errMsg, http.StatusInternalServerError)
+		requestLogger(r.Context()).Error("unable to get text info from DB", "err", err)
+		respondWithError(w, r, internalError("unable to get text info from DB"))
 		return
 	}
 
-	//create character frequency map
-	characterFrequencyMap := make(map[string]int)
-	for _, charCount := range charCounts {
-		characterFrequencyMap[charCount.Character] = int(charCount.UniqueCharCount)
-	}
-
 	//create response body with the parsed data
-	responseBody := SuccessResponseBody{
-		ID:    textInfo.ID,
-		Value: textInfo.Value,
-		Properties: struct {
-			Length                int32          `json:"length"`
-			IsPalindrome          string         `json:"is_palindrome"`
-			UniqueCharacters      string         `json:"unique_characters"`
-			WordCount             string         `json:"word_count"`
-			Sha256Hash            string         `json:"sha256_hash"`
-			CharacterFrequencyMap map[string]int `json:"character_frequency_map"`
-		}{
-			Length:                textInfo.Length,
-			IsPalindrome:          fmt.Sprintf("%t", textInfo.IsPalindrome),
-			UniqueCharacters:      fmt.Sprintf("%d", len(characterFrequencyMap)),
-			WordCount:             fmt.Sprintf("%d", textInfo.WordCount),
-			Sha256Hash:            textInfo.Sha256Hash,
-			CharacterFrequencyMap: characterFrequencyMap,
-		},
-		CreatedAt: textInfo.CreatedAt,
-	}
+	responseBody := buildTextResponse(textInfo, charCounts)
+	responseBody.Properties.Hashes = computeHashes(textInfo.Value, cfg.HashAlgorithms)
 
 	//return JSON response
-	fmt.Println("text created!!")
+	requestLogger(r.Context()).Info("text created", "id", stringID)
+	cfg.Metrics.textsCreated.Inc()
 	respondWithJSON(w, responseBody, 200)
 }
 
@@ -118,65 +171,199 @@ func (cfg *apiConfig) GetText(w http.ResponseWriter, r *http.Request) {
 	// Extract the string value from the URL path
 	stringValue := r.PathValue("string_value")
 	if stringValue == "" {
-		errMsg := "String does not exist in the system"
-		respondWithError(w, errMsg, http.StatusBadRequest)
+		respondWithError(w, r, invalidParameter(codeInvalidParameter, "string_value", "Missing string value"))
 		return
 	}
 	// Get text information by value from database
-	textInfo, err := cfg.DB.GetText(context.Background(), stringValue)
+	textInfo, err := cfg.DB.GetText(r.Context(), stringValue)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			errMsg := "string not found"
-			respondWithError(w, errMsg, http.StatusNotFound)
+			respondWithError(w, r, newAPIError(http.StatusNotFound, codeStringNotFound, "String does not exist in the system"))
 			return
 		}
-		fmt.Printf("error: %v", err)
-		errMsg := "unable to get text info from DB"
-		respondWithError(w, errMsg, http.StatusInternalServerError)
+		requestLogger(r.Context()).Error("unable to get text info from DB", "err", err)
+		respondWithError(w, r, internalError("unable to get text info from DB"))
 		return
 	}
 
 	// Get character counts for the text
-	charCounts, err := cfg.DB.GetCharacterCountsByID(context.Background(), textInfo.ID)
+	charCounts, err := cfg.DB.GetCharacterCountsByID(r.Context(), textInfo.ID)
 	if err != nil {
-		fmt.Printf("error: %v", err)
-		errMsg := "unable to get character counts from DB"
-		respondWithError(w, errMsg, http.StatusInternalServerError)
+		requestLogger(r.Context()).Error("unable to get character counts from DB", "err", err)
+		respondWithError(w, r, internalError("unable to get character counts from DB"))
 		return
 	}
 
-	// Create character frequency map
-	characterFrequencyMap := make(map[string]int)
-	for _, charCount := range charCounts {
-		characterFrequencyMap[charCount.Character] = int(charCount.UniqueCharCount)
+	// Get the stored hashes for the text
+	hashes, err := cfg.DB.GetTextHashesByID(r.Context(), textInfo.ID)
+	if err != nil {
+		requestLogger(r.Context()).Error("unable to get text hashes from DB", "err", err)
+		respondWithError(w, r, internalError("unable to get text hashes from DB"))
+		return
 	}
 
 	// Create response body with the parsed data
-	responseBody := SuccessResponseBody{
-		ID:    textInfo.ID,
-		Value: textInfo.Value,
-		Properties: struct {
-			Length                int32          `json:"length"`
-			IsPalindrome          string         `json:"is_palindrome"`
-			UniqueCharacters      string         `json:"unique_characters"`
-			WordCount             string         `json:"word_count"`
-			Sha256Hash            string         `json:"sha256_hash"`
-			CharacterFrequencyMap map[string]int `json:"character_frequency_map"`
-		}{
-			Length:                textInfo.Length,
-			IsPalindrome:          fmt.Sprintf("%t", textInfo.IsPalindrome),
-			UniqueCharacters:      fmt.Sprintf("%d", len(characterFrequencyMap)),
-			WordCount:             fmt.Sprintf("%d", textInfo.WordCount),
-			Sha256Hash:            textInfo.Sha256Hash,
-			CharacterFrequencyMap: characterFrequencyMap,
-		},
-		CreatedAt: textInfo.CreatedAt,
+	responseBody := buildTextResponse(textInfo, charCounts)
+	responseBody.Properties.Hashes = make(map[string]string)
+	for _, hash := range hashes {
+		responseBody.Properties.Hashes[hash.Algorithm] = hash.HashValue
 	}
 
 	// Return JSON response
 	respondWithJSON(w, responseBody, http.StatusOK)
 }
 
+func (cfg *apiConfig) GetTextsByHash(w http.ResponseWriter, r *http.Request) {
+	algorithm := strings.ToLower(r.PathValue("algorithm"))
+	if _, ok := hashAlgorithms[algorithm]; !ok {
+		respondWithError(w, r, invalidParameter(codeUnsupportedAlgorithm, "algorithm", fmt.Sprintf("Unsupported hash algorithm: %s", algorithm)))
+		return
+	}
+	hashValue := strings.ToLower(strings.TrimSpace(r.PathValue("hash_value")))
+	if hashValue == "" {
+		respondWithError(w, r, invalidParameter(codeInvalidParameter, "hash_value", "Missing hash value"))
+		return
+	}
+
+	texts, err := cfg.DB.GetTextsByHash(r.Context(), database.GetTextsByHashParams{
+		Algorithm: algorithm,
+		HashValue: hashValue,
+	})
+	if err != nil {
+		requestLogger(r.Context()).Error("error getting texts by hash", "err", err)
+		respondWithError(w, r, internalError("Unable to retrieve texts from database"))
+		return
+	}
+	if len(texts) == 0 {
+		respondWithError(w, r, newAPIError(http.StatusNotFound, codeHashNotFound, "No string with that hash exists in the system"))
+		return
+	}
+
+	response := HashLookupResponse{
+		Algorithm: algorithm,
+		Hash:      hashValue,
+		Data:      make([]SuccessResponseBody, len(texts)),
+		Count:     len(texts),
+	}
+	for i, text := range texts {
+		charCounts, err := cfg.DB.GetCharacterCountsByID(r.Context(), text.ID)
+		if err != nil {
+			requestLogger(r.Context()).Error("error getting character counts for text", "text_id", text.ID, "err", err)
+			// Continue with empty character frequency map rather than failing completely
+			charCounts = []database.GetCharacterCountsByIDRow{}
+		}
+		response.Data[i] = buildTextResponse(text, charCounts)
+	}
+
+	respondWithJSON(w, response, http.StatusOK)
+}
+
+func (cfg *apiConfig) GetTextNgrams(w http.ResponseWriter, r *http.Request) {
+	stringValue := r.PathValue("string_value")
+	if stringValue == "" {
+		respondWithError(w, r, invalidParameter(codeInvalidParameter, "string_value", "Missing string value"))
+		return
+	}
+
+	n, unit, err := parseNgramParams(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	textInfo, err := cfg.DB.GetText(r.Context(), stringValue)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, r, newAPIError(http.StatusNotFound, codeStringNotFound, "String does not exist in the system"))
+			return
+		}
+		requestLogger(r.Context()).Error("unable to get text info from DB", "err", err)
+		respondWithError(w, r, internalError("unable to get text info from DB"))
+		return
+	}
+
+	// Bigrams and trigrams are stored on create, any other size is computed from the value.
+	// Without the n-gram analyzer nothing is stored, so every size is computed
+	counts := make(map[string]int32)
+	if cfg.storesNgrams(textInfo.Value) && isPersistedNgramSize(n) {
+		rows, err := cfg.DB.GetNgramCountsByID(r.Context(), database.GetNgramCountsByIDParams{
+			StringID: textInfo.ID,
+			Unit:     unit,
+			N:        int32(n),
+		})
+		if err != nil {
+			requestLogger(r.Context()).Error("unable to get n-gram counts from DB", "err", err)
+			respondWithError(w, r, internalError("unable to get n-gram counts from DB"))
+			return
+		}
+		for _, row := range rows {
+			counts[row.Ngram] = row.NgramCount
+		}
+	} else {
+		counts = countNgrams(textInfo.Value, n, unit)
+	}
+
+	ngrams := rankNgrams(counts)
+	responseBody := NgramsResponse{
+		Value:  textInfo.Value,
+		N:      n,
+		Unit:   unit,
+		Ngrams: ngrams,
+		Count:  len(ngrams),
+	}
+	respondWithJSON(w, responseBody, http.StatusOK)
+}
+
+func (cfg *apiConfig) GetTopNgrams(w http.ResponseWriter, r *http.Request) {
+	n, unit, err := parseNgramParams(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	if !isPersistedNgramSize(n) {
+		respondWithError(w, r, invalidParameter(codeInvalidParameter, "n", "Invalid n parameter: corpus n-grams are only available for n=2 or n=3"))
+		return
+	}
+
+	limit := int64(20)
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.ParseInt(value, 10, 32)
+		if err != nil || limit <= 0 {
+			respondWithError(w, r, invalidParameter(codeInvalidParameter, "limit", "Invalid limit parameter: must be a positive integer"))
+			return
+		}
+	}
+
+	rows, err := cfg.DB.GetTopNgrams(r.Context(), database.GetTopNgramsParams{
+		Unit:  unit,
+		N:     int32(n),
+		Limit: int32(limit),
+	})
+	if err != nil {
+		requestLogger(r.Context()).Error("error getting top n-grams", "err", err)
+		respondWithError(w, r, internalError("Unable to retrieve n-grams from database"))
+		return
+	}
+
+	ngrams := make([]NgramCount, len(rows))
+	for i, row := range rows {
+		ngrams[i] = NgramCount{
+			Ngram:     row.Ngram,
+			Count:     int(row.TotalCount),
+			TextCount: int(row.TextCount),
+			Rank:      i + 1,
+		}
+	}
+
+	responseBody := NgramsResponse{
+		N:      n,
+		Unit:   unit,
+		Ngrams: ngrams,
+		Count:  len(ngrams),
+	}
+	respondWithJSON(w, responseBody, http.StatusOK)
+}
+
 func (cfg *apiConfig) GetFilteredTexts(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters
 	clientQueryFilters := r.URL.Query()
@@ -184,25 +371,54 @@ func (cfg *apiConfig) GetFilteredTexts(w http.ResponseWriter, r *http.Request) {
 	//check if keys in  client-query correspond to Querykeys
 	for query := range clientQueryFilters {
 		if _, ok := cfg.QueryFilters[query]; !ok {
-			errMsg := "Invalid query parameter values or types"
-			respondWithError(w, errMsg, http.StatusBadRequest)
+			respondWithError(w, r, invalidParameter(codeInvalidParameter, query, fmt.Sprintf("Unknown query parameter %q", query)))
 			return
 		}
 
 	}
 
-	// Create params struct for the database query
-	filterParams := database.GetFilteredTextsParams{
-		// Set default values that won't filter anything
-		IsPalindrome:      false, // Will need logic to handle optional boolean
-		MinLength:         0,
-		MaxLength:         999999, // Large default
-		WordCount:         0,      // Will need logic to handle optional int
-		ContainsCharacter: sql.NullString{Valid: false},
+	// Every filter becomes a condition of the same filter tree the natural-language endpoint uses
+	conditions, filtersApplied, err := parseFilterParams(clientQueryFilters)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	query := filter.Query{Where: filter.And(conditions...)}
+	page, err := parsePageParams(r, query)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	// Call the database function
+	texts, nextCursor, err := cfg.executeFilteredQuery(r.Context(), query, page)
+	if err != nil {
+		requestLogger(r.Context()).Error("error getting filtered texts", "err", err)
+		respondWithError(w, r, internalError("Unable to retrieve filtered texts from database"))
+		return
+	}
+
+	response := FilteredTextsResponse{
+		Data:           cfg.buildTextResponses(r.Context(), texts),
+		Count:          len(texts),
+		FiltersApplied: filtersApplied,
+		NextCursor:     nextCursor,
+	}
+
+	respondWithJSON(w, response, http.StatusOK)
+}
+
+// parseFilterParams turns the GET /strings query parameters into filter conditions, ignoring the
+// page_size and cursor parameters
+func parseFilterParams(params url.Values) ([]filter.Node, FiltersApplied, error) {
+	var conditions []filter.Node
+	filtersApplied := FiltersApplied{
+		MaxLength: 999999, // Large default
 	}
 
 	// Parse and validate each query parameter
-	for key, values := range clientQueryFilters {
+	for key, values := range params {
 		if len(values) == 0 {
 			continue
 		}
@@ -213,166 +429,423 @@ func (cfg *apiConfig) GetFilteredTexts(w http.ResponseWriter, r *http.Request) {
 			// Parse boolean and set flag to indicate it was provided
 			palindromeVal, err := strconv.ParseBool(value)
 			if err != nil {
-				errMsg := "Invalid is_palindrome parameter: must be 'true' or 'false'"
-				respondWithError(w, errMsg, http.StatusBadRequest)
-				return
+				return nil, filtersApplied, invalidParameter(codeInvalidFilter, key, "Invalid is_palindrome parameter: must be 'true' or 'false'")
 			}
-			filterParams.IsPalindrome = palindromeVal
+			conditions = append(conditions, filter.Cond(filter.FieldIsPalindrome, filter.OpEq, palindromeVal))
+			filtersApplied.IsPalindrome = palindromeVal
 
 		case "min_length":
 			// Parse int32 and validate > 0
 			minLength, err := strconv.ParseInt(value, 10, 32)
 			if err != nil {
-				errMsg := "Invalid min_length parameter: must be a valid integer"
-				respondWithError(w, errMsg, http.StatusBadRequest)
-				return
+				return nil, filtersApplied, invalidParameter(codeInvalidFilter, key, "Invalid min_length parameter: must be a valid integer")
 			}
 			if minLength < 0 {
-				errMsg := "Invalid min_length parameter: must be greater than or equal to 0"
-				respondWithError(w, errMsg, http.StatusBadRequest)
-				return
+				return nil, filtersApplied, invalidParameter(codeInvalidFilter, key, "Invalid min_length parameter: must be greater than or equal to 0")
 			}
-			filterParams.MinLength = int32(minLength)
+			conditions = append(conditions, filter.Cond(filter.FieldLength, filter.OpGte, int(minLength)))
+			filtersApplied.MinLength = int(minLength)
 
 		case "max_length":
 			// Parse int32 and validate > 0
 			maxLength, err := strconv.ParseInt(value, 10, 32)
 			if err != nil {
-				errMsg := "Invalid max_length parameter: must be a valid integer"
-				respondWithError(w, errMsg, http.StatusBadRequest)
-				return
+				return nil, filtersApplied, invalidParameter(codeInvalidFilter, key, "Invalid max_length parameter: must be a valid integer")
 			}
 			if maxLength <= 0 {
-				errMsg := "Invalid max_length parameter: must be greater than 0"
-				respondWithError(w, errMsg, http.StatusBadRequest)
-				return
+				return nil, filtersApplied, invalidParameter(codeInvalidFilter, key, "Invalid max_length parameter: must be greater than 0")
 			}
-			filterParams.MaxLength = int32(maxLength)
+			conditions = append(conditions, filter.Cond(filter.FieldLength, filter.OpLte, int(maxLength)))
+			filtersApplied.MaxLength = int(maxLength)
 
 		case "word_count":
 			// Parse int32 and validate >= 0
 			wordCount, err := strconv.ParseInt(value, 10, 32)
 			if err != nil {
-				errMsg := "Invalid word_count parameter: must be a valid integer"
-				respondWithError(w, errMsg, http.StatusBadRequest)
-				return
+				return nil, filtersApplied, invalidParameter(codeInvalidFilter, key, "Invalid word_count parameter: must be a valid integer")
 			}
 			if wordCount < 0 {
-				errMsg := "Invalid word_count parameter: must be greater than or equal to 0"
-				respondWithError(w, errMsg, http.StatusBadRequest)
-				return
+				return nil, filtersApplied, invalidParameter(codeInvalidFilter, key, "Invalid word_count parameter: must be greater than or equal to 0")
 			}
-			filterParams.WordCount = int32(wordCount)
+			conditions = append(conditions, filter.Cond(filter.FieldWordCount, filter.OpEq, int(wordCount)))
+			filtersApplied.WordCount = int(wordCount)
 
 		case "contains_character":
-			// Validate string and set NullString
+			// Validate string is not empty
 			if strings.TrimSpace(value) == "" {
-				errMsg := "Invalid contains_character parameter: cannot be empty or whitespace only"
-				respondWithError(w, errMsg, http.StatusBadRequest)
-				return
+				return nil, filtersApplied, invalidParameter(codeInvalidFilter, key, "Invalid contains_character parameter: cannot be empty or whitespace only")
 			}
-			filterParams.ContainsCharacter = sql.NullString{
-				String: value,
-				Valid:  true,
+			conditions = append(conditions, filter.Cond(filter.FieldValue, filter.OpContains, value))
+			filtersApplied.ContainsCharacter = value
+
+		case "language":
+			// Accept either a language code ("fr") or its name ("french")
+			language := strings.ToLower(strings.TrimSpace(value))
+			if code, ok := languageNames[language]; ok {
+				language = code
+			}
+			if language == "" {
+				return nil, filtersApplied, invalidParameter(codeInvalidFilter, key, "Invalid language parameter: cannot be empty or whitespace only")
+			}
+			conditions = append(conditions, filter.Cond(filter.FieldLanguage, filter.OpEq, language))
+			filtersApplied.Language = language
+
+		case "sentiment_min", "sentiment_max":
+			// Sentiment scores are compound scores between -1 and 1
+			sentiment, err := strconv.ParseFloat(value, 64)
+			if err != nil || sentiment < -1 || sentiment > 1 {
+				return nil, filtersApplied, invalidParameter(codeInvalidFilter, key, fmt.Sprintf("Invalid %s parameter: must be a number between -1 and 1", key))
+			}
+			if key == "sentiment_min" {
+				conditions = append(conditions, filter.Cond(filter.FieldSentimentScore, filter.OpGte, sentiment))
+				filtersApplied.SentimentMin = &sentiment
+			} else {
+				conditions = append(conditions, filter.Cond(filter.FieldSentimentScore, filter.OpLte, sentiment))
+				filtersApplied.SentimentMax = &sentiment
+			}
+
+		case "has_profanity":
+			hasProfanity, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, filtersApplied, invalidParameter(codeInvalidFilter, key, "Invalid has_profanity parameter: must be 'true' or 'false'")
 			}
+			conditions = append(conditions, filter.Cond(filter.FieldHasProfanity, filter.OpEq, hasProfanity))
+			filtersApplied.HasProfanity = &hasProfanity
+
+		case "starts_with", "ends_with":
+			if value == "" {
+				return nil, filtersApplied, invalidParameter(codeInvalidFilter, key, fmt.Sprintf("Invalid %s parameter: cannot be empty", key))
+			}
+			if key == "starts_with" {
+				conditions = append(conditions, filter.Cond(filter.FieldValue, filter.OpStartsWith, value))
+				filtersApplied.StartsWith = value
+			} else {
+				conditions = append(conditions, filter.Cond(filter.FieldValue, filter.OpEndsWith, value))
+				filtersApplied.EndsWith = value
+			}
+
+		case "char_at":
+			// Format is <index>:<character>, with a zero-based index, e.g. char_at=0:z
+			indexStr, character, found := strings.Cut(value, ":")
+			index, err := strconv.Atoi(indexStr)
+			if !found || err != nil || index < 0 || utf8.RuneCountInString(character) != 1 {
+				return nil, filtersApplied, invalidParameter(codeInvalidFilter, key, "Invalid char_at parameter: must be <index>:<character> with a non-negative index, e.g. 0:z")
+			}
+			charAt := filter.Cond(filter.FieldCharAt, filter.OpEq, character)
+			charAt.Index = &index
+			conditions = append(conditions, charAt)
+			filtersApplied.CharAt = value
 		}
 	}
 
-	// Call the database function
-	texts, err := cfg.DB.GetFilteredTexts(context.Background(), filterParams)
+	return conditions, filtersApplied, nil
+}
+
+func (cfg *apiConfig) SearchStrings(w http.ResponseWriter, r *http.Request) {
+	var query filter.Query
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&query); err != nil {
+		respondWithError(w, r, invalidBody("search body", err))
+		return
+	}
+	if err := query.Validate(); err != nil {
+		respondWithError(w, r, invalidFilter(err))
+		return
+	}
+
+	page, err := parsePageParams(r, query)
 	if err != nil {
-		fmt.Printf("error getting filtered texts: %v", err)
-		errMsg := "Unable to retrieve filtered texts from database"
-		respondWithError(w, errMsg, http.StatusInternalServerError)
+		respondWithError(w, r, err)
 		return
 	}
 
-	// Parse texts into FilteredTextsResponse struct
-	response := FilteredTextsResponse{
-		Data: make([]struct {
-			ID         string `json:"id"`
-			Value      string `json:"value"`
-			Properties struct {
-				Length                int32          `json:"length"`
-				IsPalindrome          string         `json:"is_palindrome"`
-				WordCount             string         `json:"word_count"`
-				Sha256Hash            string         `json:"sha256_hash"`
-				CharacterFrequencyMap map[string]int `json:"character_frequency_map"`
-			} `json:"properties"`
-			CreatedAt time.Time `json:"created_at"`
-		}, len(texts)),
-		Count: len(texts),
-		FiltersApplied: struct {
-			IsPalindrome      bool   `json:"is_palindrome"`
-			MinLength         int    `json:"min_length"`
-			MaxLength         int    `json:"max_length"`
-			WordCount         int    `json:"word_count"`
-			ContainsCharacter string `json:"contains_character"`
-		}{
-			IsPalindrome:      filterParams.IsPalindrome,
-			MinLength:         int(filterParams.MinLength),
-			MaxLength:         int(filterParams.MaxLength),
-			WordCount:         int(filterParams.WordCount),
-			ContainsCharacter: filterParams.ContainsCharacter.String,
-		},
+	texts, nextCursor, err := cfg.executeFilteredQuery(r.Context(), query, page)
+	if err != nil {
+		requestLogger(r.Context()).Error("error searching texts", "err", err)
+		respondWithError(w, r, internalError("Unable to search texts"))
+		return
 	}
 
-	for i, text := range texts {
-		// Get character counts for each text to build frequency map
-		charCounts, err := cfg.DB.GetCharacterCountsByID(context.Background(), text.ID)
-		if err != nil {
-			fmt.Printf("error getting character counts for text %s: %v", text.ID.String(), err)
-			// Continue with empty character frequency map rather than failing completely
-			charCounts = []database.GetCharacterCountsByIDRow{}
+	response := SearchResponse{
+		Data:       cfg.buildTextResponses(r.Context(), texts),
+		Count:      len(texts),
+		NextCursor: nextCursor,
+		Query:      query,
+	}
+	respondWithJSON(w, response, http.StatusOK)
+}
+
+func (cfg *apiConfig) GetSearchSchema(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, filter.Schema(), http.StatusOK)
+}
+
+func (cfg *apiConfig) CreateSavedSearch(w http.ResponseWriter, r *http.Request) {
+	reqBody, ok := decodeSavedSearchRequest(w, r)
+	if !ok {
+		return
+	}
+	params, err := cfg.savedSearchParams(r, reqBody)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	if _, err := cfg.DB.GetSavedSearch(r.Context(), params.Name); err == nil {
+		respondWithError(w, r, newAPIError(http.StatusConflict, codeSavedSearchExists, fmt.Sprintf("Saved search %q already exists", params.Name)))
+		return
+	} else if err != sql.ErrNoRows {
+		requestLogger(r.Context()).Error("error looking up saved search", "err", err)
+		respondWithError(w, r, internalError("Unable to create saved search"))
+		return
+	}
+
+	search, err := cfg.DB.CreateSavedSearch(r.Context(), params)
+	if err != nil {
+		requestLogger(r.Context()).Error("error creating saved search", "err", err)
+		respondWithError(w, r, internalError("Unable to create saved search"))
+		return
+	}
+	respondWithJSON(w, buildSavedSearchResponse(search), http.StatusCreated)
+}
+
+func (cfg *apiConfig) ListSavedSearches(w http.ResponseWriter, r *http.Request) {
+	searches, err := cfg.DB.ListSavedSearches(r.Context())
+	if err != nil {
+		requestLogger(r.Context()).Error("error listing saved searches", "err", err)
+		respondWithError(w, r, internalError("Unable to list saved searches"))
+		return
+	}
+
+	response := SavedSearchListResponse{Data: []SavedSearchResponse{}}
+	for _, search := range searches {
+		response.Data = append(response.Data, buildSavedSearchResponse(search))
+	}
+	response.Count = len(response.Data)
+	respondWithJSON(w, response, http.StatusOK)
+}
+
+func (cfg *apiConfig) GetSavedSearch(w http.ResponseWriter, r *http.Request) {
+	search, ok := cfg.lookupSavedSearch(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, buildSavedSearchResponse(search), http.StatusOK)
+}
+
+func (cfg *apiConfig) UpdateSavedSearch(w http.ResponseWriter, r *http.Request) {
+	reqBody, ok := decodeSavedSearchRequest(w, r)
+	if !ok {
+		return
+	}
+	// the name comes from the path, a body name must agree with it
+	name := r.PathValue("name")
+	if reqBody.Name != "" && reqBody.Name != name {
+		respondWithError(w, r, newAPIError(http.StatusBadRequest, codeInvalidSavedSearch, "Saved searches can't be renamed").withField("name", "must match the name in the path"))
+		return
+	}
+	reqBody.Name = name
+	params, err := cfg.savedSearchParams(r, reqBody)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	search, err := cfg.DB.UpdateSavedSearch(r.Context(), database.UpdateSavedSearchParams(params))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, r, newAPIError(http.StatusNotFound, codeSavedSearchNotFound, "Saved search does not exist"))
+			return
 		}
+		requestLogger(r.Context()).Error("error updating saved search", "err", err)
+		respondWithError(w, r, internalError("Unable to update saved search"))
+		return
+	}
+	respondWithJSON(w, buildSavedSearchResponse(search), http.StatusOK)
+}
+
+func (cfg *apiConfig) DeleteSavedSearch(w http.ResponseWriter, r *http.Request) {
+	deleted, err := cfg.DB.DeleteSavedSearch(r.Context(), r.PathValue("name"))
+	if err != nil {
+		requestLogger(r.Context()).Error("error deleting saved search", "err", err)
+		respondWithError(w, r, internalError("Unable to delete saved search"))
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, r, newAPIError(http.StatusNotFound, codeSavedSearchNotFound, "Saved search does not exist"))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) GetSavedSearchResults(w http.ResponseWriter, r *http.Request) {
+	search, ok := cfg.lookupSavedSearch(w, r)
+	if !ok {
+		return
+	}
+	var query filter.Query
+	if err := json.Unmarshal(search.Query, &query); err != nil {
+		requestLogger(r.Context()).Error("error decoding saved search", "search", search.Name, "err", err)
+		respondWithError(w, r, internalError("Unable to run saved search"))
+		return
+	}
+
+	page, err := parsePageParams(r, query)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	texts, nextCursor, err := cfg.executeFilteredQuery(r.Context(), query, page)
+	if err != nil {
+		requestLogger(r.Context()).Error("error running saved search", "search", search.Name, "err", err)
+		respondWithError(w, r, internalError("Unable to run saved search"))
+		return
+	}
+
+	total, err := cfg.countFilteredQuery(r.Context(), query)
+	if err != nil {
+		requestLogger(r.Context()).Error("error counting saved search results", "search", search.Name, "err", err)
+		respondWithError(w, r, internalError("Unable to run saved search"))
+		return
+	}
 
-		// Create character frequency map
-		characterFrequencyMap := make(map[string]int)
-		for _, charCount := range charCounts {
-			characterFrequencyMap[charCount.Character] = int(charCount.UniqueCharCount)
+	lastRunAt, err := cfg.DB.RecordSavedSearchRun(r.Context(), database.RecordSavedSearchRunParams{
+		Name:            search.Name,
+		LastResultCount: sql.NullInt32{Int32: int32(total), Valid: true},
+	})
+	if err != nil {
+		requestLogger(r.Context()).Error("error recording run of saved search", "search", search.Name, "err", err)
+		respondWithError(w, r, internalError("Unable to run saved search"))
+		return
+	}
+
+	response := SavedSearchResultsResponse{
+		Name:       search.Name,
+		Data:       cfg.buildTextResponses(r.Context(), texts),
+		Count:      len(texts),
+		Total:      total,
+		NextCursor: nextCursor,
+		LastRunAt:  lastRunAt.Time,
+		Search:     query,
+	}
+	respondWithJSON(w, response, http.StatusOK)
+}
+
+// lookupSavedSearch fetches the saved search named in the path, responding with 404 when there is none
+func (cfg *apiConfig) lookupSavedSearch(w http.ResponseWriter, r *http.Request) (database.SavedSearch, bool) {
+	search, err := cfg.DB.GetSavedSearch(r.Context(), r.PathValue("name"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, r, newAPIError(http.StatusNotFound, codeSavedSearchNotFound, "Saved search does not exist"))
+			return search, false
 		}
+		requestLogger(r.Context()).Error("error getting saved search", "err", err)
+		respondWithError(w, r, internalError("Unable to get saved search"))
+		return search, false
+	}
+	return search, true
+}
+
+func decodeSavedSearchRequest(w http.ResponseWriter, r *http.Request) (SavedSearchRequest, bool) {
+	var reqBody SavedSearchRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&reqBody); err != nil {
+		respondWithError(w, r, invalidBody("saved search body", err))
+		return reqBody, false
+	}
+	return reqBody, true
+}
+
+func (cfg *apiConfig) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var reqBody APIKeyRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&reqBody); err != nil {
+		respondWithError(w, r, invalidBody("API key body", err))
+		return
+	}
+	params, key, err := apiKeyParams(r, reqBody)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
 
-		response.Data[i].ID = text.ID.String()
-		response.Data[i].Value = text.Value
-		response.Data[i].CreatedAt = text.CreatedAt
-		response.Data[i].Properties.Length = text.Length
-		response.Data[i].Properties.IsPalindrome = fmt.Sprintf("%t", text.IsPalindrome)
-		response.Data[i].Properties.WordCount = fmt.Sprintf("%d", text.WordCount)
-		response.Data[i].Properties.Sha256Hash = text.Sha256Hash
-		response.Data[i].Properties.CharacterFrequencyMap = characterFrequencyMap
+	stored, err := cfg.DB.CreateAPIKey(r.Context(), params)
+	if err != nil {
+		requestLogger(r.Context()).Error("error creating API key", "err", err)
+		respondWithError(w, r, internalError("Unable to create API key"))
+		return
 	}
+	requestLogger(r.Context()).Info("API key issued", "id", stored.ID, "prefix", stored.Prefix, "scopes", stored.Scopes)
 
+	// the key itself is only ever shown here, just its hash is stored
+	response := buildAPIKeyResponse(stored)
+	response.Key = key
+	respondWithJSON(w, response, http.StatusCreated)
+}
+
+func (cfg *apiConfig) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := cfg.DB.ListAPIKeys(r.Context())
+	if err != nil {
+		requestLogger(r.Context()).Error("error listing API keys", "err", err)
+		respondWithError(w, r, internalError("Unable to list API keys"))
+		return
+	}
+
+	response := APIKeyListResponse{Data: []APIKeyResponse{}}
+	for _, key := range keys {
+		response.Data = append(response.Data, buildAPIKeyResponse(key))
+	}
+	response.Count = len(response.Data)
 	respondWithJSON(w, response, http.StatusOK)
 }
 
+func (cfg *apiConfig) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, r, invalidParameter(codeInvalidParameter, "id", "API key id must be a UUID"))
+		return
+	}
+
+	revoked, err := cfg.DB.RevokeAPIKey(r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, r, newAPIError(http.StatusNotFound, codeAPIKeyNotFound, "API key does not exist or is already revoked"))
+			return
+		}
+		requestLogger(r.Context()).Error("error revoking API key", "err", err)
+		respondWithError(w, r, internalError("Unable to revoke API key"))
+		return
+	}
+	requestLogger(r.Context()).Info("API key revoked", "id", revoked.ID, "prefix", revoked.Prefix)
+	respondWithJSON(w, buildAPIKeyResponse(revoked), http.StatusOK)
+}
+
 func (cfg *apiConfig) DeleteText(w http.ResponseWriter, r *http.Request) {
 	stringValue := r.PathValue("string_value")
 	if stringValue == "" {
-		errMsg := "String does not exist in the system"
-		respondWithError(w, errMsg, http.StatusBadRequest)
+		respondWithError(w, r, invalidParameter(codeInvalidParameter, "string_value", "Missing string value"))
 		return
 	}
 
 	// Get text information by value to get the ID
-	textInfo, err := cfg.DB.GetText(context.Background(), stringValue)
+	textInfo, err := cfg.DB.GetText(r.Context(), stringValue)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			errMsg := "String does not exist in the system"
-			respondWithError(w, errMsg, http.StatusNotFound)
+			respondWithError(w, r, newAPIError(http.StatusNotFound, codeStringNotFound, "String does not exist in the system"))
 			return
 		}
-		fmt.Printf("error: %v", err)
-		errMsg := "unable to get text info from DB"
-		respondWithError(w, errMsg, http.StatusInternalServerError)
+		requestLogger(r.Context()).Error("unable to get text info from DB", "err", err)
+		respondWithError(w, r, internalError("unable to get text info from DB"))
 		return
 	}
 
 	// Delete the text by ID
-	err = cfg.DB.DeleteTextWithID(context.Background(), textInfo.ID)
+	err = cfg.DB.DeleteTextWithID(r.Context(), textInfo.ID)
 	if err != nil {
-		fmt.Printf("error deleting text: %v", err)
-		errMsg := "unable to delete text from DB"
-		respondWithError(w, errMsg, http.StatusInternalServerError)
+		requestLogger(r.Context()).Error("error deleting text", "err", err)
+		respondWithError(w, r, internalError("unable to delete text from DB"))
 		return
 	}
 
@@ -384,31 +857,110 @@ func (cfg *apiConfig) GetTexByNaturalLang(w http.ResponseWriter, r *http.Request
 	// Get the natural language query from query parameter
 	query := r.URL.Query().Get("query")
 	if query == "" {
-		respondWithError(w, "Missing 'query' parameter", http.StatusBadRequest)
+		respondWithError(w, r, invalidParameter(codeMissingQuery, "query", "Missing 'query' parameter"))
+		return
+	}
+	view := r.URL.Query().Get("view")
+	if view == "" {
+		view = viewValues
+	}
+	if view != viewValues && view != viewFull {
+		respondWithError(w, r, invalidParameter(codeInvalidParameter, "view", "Invalid view parameter: must be 'values' or 'full'"))
 		return
 	}
 
-	// Parse natural language query into database filters
-	filters, err := parseNaturalLanguageQuery(query)
+	// Pick the phrase lexicon from lang= or Accept-Language
+	lex, err := negotiateLocale(r)
 	if err != nil {
-		respondWithError(w, fmt.Sprintf("Could not understand query: %s", err.Error()), http.StatusBadRequest)
+		respondWithError(w, r, err)
 		return
 	}
+	w.Header().Set("Content-Language", lex.locale)
 
-	// Build and execute database query based on parsed filters
-	texts, err := cfg.executeFilteredQuery(r.Context(), filters)
+	// Parse natural language query into a filter tree, sort order and limit
+	parsed, err := cfg.Interpreter.Interpret(query, lex)
 	if err != nil {
-		respondWithError(w, "Database query failed", http.StatusInternalServerError)
+		respondWithError(w, r, invalidParameter(codeUnparseableQuery, "query", fmt.Sprintf("Could not understand query: %s", err.Error())))
 		return
 	}
 
-	// Extract just the string values from the results
-	var data []string
-	for _, text := range texts {
-		data = append(data, text.Value)
+	// Contradictory constraints can never match anything
+	if conflicts := filter.Conflicts(parsed.Where); len(conflicts) > 0 {
+		respondWithError(w, r, conflictingConstraints(conflicts))
+		return
+	}
+
+	page, err := parsePageParams(r, parsed)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
 	}
 
-	// Create parsed filters map for response
+	// Compile the filter tree and execute it against the database
+	texts, nextCursor, err := cfg.executeFilteredQuery(r.Context(), parsed, page)
+	if err != nil {
+		requestLogger(r.Context()).Error("error getting texts for natural language query", "query", query, "err", err)
+		respondWithError(w, r, internalError("Database query failed"))
+		return
+	}
+
+	// Format and return response
+	response := NaturalLanguageResponse{
+		Count:      len(texts),
+		NextCursor: nextCursor,
+	}
+	if view == viewFull {
+		response.Data = cfg.buildTextResponses(r.Context(), texts)
+	} else {
+		// Extract just the string values from the results
+		values := []string{}
+		for _, text := range texts {
+			values = append(values, text.Value)
+		}
+		response.Data = values
+	}
+	response.InterpretedQuery.Original = query
+	response.InterpretedQuery.Locale = lex.locale
+	response.InterpretedQuery.Filter = parsed.Where
+	response.InterpretedQuery.Sort = parsed.Sort
+	response.InterpretedQuery.Limit = parsed.Limit
+	if filters, ok := nlpFiltersFromNode(parsed.Where); ok {
+		response.InterpretedQuery.ParsedFilters = filters.toMap()
+	}
+
+	respondWithJSON(w, response, http.StatusOK)
+}
+
+func (cfg *apiConfig) ExplainNaturalLanguageQuery(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("query")
+	if query == "" {
+		respondWithError(w, r, invalidParameter(codeMissingQuery, "query", "Missing 'query' parameter"))
+		return
+	}
+
+	lex, err := negotiateLocale(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Language", lex.locale)
+
+	// the configured interpreter explains its own reading, e.g. the rule that matched
+	explanation, err := cfg.Interpreter.Explain(query, lex)
+	if err != nil {
+		respondWithError(w, r, invalidParameter(codeUnparseableQuery, "query", fmt.Sprintf("Could not understand query: %s", err.Error())).
+			with("explanation", explanation))
+		return
+	}
+	if len(explanation.Conflicts) > 0 {
+		respondWithError(w, r, conflictingConstraints(explanation.Conflicts).with("explanation", explanation))
+		return
+	}
+	respondWithJSON(w, explanation, http.StatusOK)
+}
+
+// toMap lists the filters that were set, keyed by their query parameter names
+func (filters NLPFilters) toMap() map[string]interface{} {
 	parsedFilters := make(map[string]interface{})
 	if filters.IsPalindrome != nil {
 		parsedFilters["is_palindrome"] = *filters.IsPalindrome
@@ -416,6 +968,12 @@ func (cfg *apiConfig) GetTexByNaturalLang(w http.ResponseWriter, r *http.Request
 	if filters.WordCount != nil {
 		parsedFilters["word_count"] = *filters.WordCount
 	}
+	if filters.MinWordCount != nil {
+		parsedFilters["min_word_count"] = *filters.MinWordCount
+	}
+	if filters.MaxWordCount != nil {
+		parsedFilters["max_word_count"] = *filters.MaxWordCount
+	}
 	if filters.MinLength != nil {
 		parsedFilters["min_length"] = *filters.MinLength
 	}
@@ -428,283 +986,109 @@ func (cfg *apiConfig) GetTexByNaturalLang(w http.ResponseWriter, r *http.Request
 	if filters.ContainsText != nil {
 		parsedFilters["contains_text"] = *filters.ContainsText
 	}
-
-	// Format and return response
-	response := NaturalLanguageResponse{
-		Data:  data,
-		Count: len(data),
-		InterpretedQuery: struct {
-			Original      string                 `json:"original"`
-			ParsedFilters map[string]interface{} `json:"parsed_filters"`
-		}{
-			Original:      query,
-			ParsedFilters: parsedFilters,
-		},
+	if filters.Language != nil {
+		parsedFilters["language"] = *filters.Language
 	}
-
-	respondWithJSON(w, response, http.StatusOK)
-}
-
-// parseNaturalLanguageQuery converts natural language to database filters
-func parseNaturalLanguageQuery(query string) (NLPFilters, error) {
-	originalQuery := query
-	query = strings.ToLower(strings.TrimSpace(query))
-	filters := NLPFilters{}
-
-	// Pattern matching for different query types
-
-	// Palindrome detection
-	palindromePatterns := []string{"palindrom", "reads the same", "same forwards and backwards"}
-	for _, pattern := range palindromePatterns {
-		if strings.Contains(query, pattern) {
-			isPalindrome := true
-			if strings.Contains(query, "not") || strings.Contains(query, "non") {
-				isPalindrome = false
-			}
-			filters.IsPalindrome = &isPalindrome
-			break
-		}
+	if filters.SentimentMin != nil {
+		parsedFilters["sentiment_min"] = *filters.SentimentMin
 	}
-
-	// Word count patterns - improved to handle "single word", "one word", etc.
-	wordCountPatterns := []struct {
-		pattern string
-		count   int
-	}{
-		{"single word", 1},
-		{"one word", 1},
-		{"1 word", 1},
-		{"two words", 2},
-		{"2 words", 2},
-		{"three words", 3},
-		{"3 words", 3},
-		{"four words", 4},
-		{"4 words", 4},
-		{"five words", 5},
-		{"5 words", 5},
-	}
-
-	for _, wcp := range wordCountPatterns {
-		if strings.Contains(query, wcp.pattern) {
-			filters.WordCount = &wcp.count
-			break
-		}
+	if filters.SentimentMax != nil {
+		parsedFilters["sentiment_max"] = *filters.SentimentMax
 	}
-
-	// Generic word count patterns with numbers
-	wordPattern := regexp.MustCompile(`(?:words?|word count)\s*(?:(?:is\s*)?(?:greater\s+than|more\s+than|over|>)\s*(\d+)|(?:is\s*)?(?:less\s+than|fewer\s+than|under|<)\s*(\d+)|(?:is\s*)?(?:exactly\s*)?(\d+))`)
-	wordMatches := wordPattern.FindAllStringSubmatch(query, -1)
-	for _, match := range wordMatches {
-		if match[1] != "" { // greater than
-			if val, err := strconv.Atoi(match[1]); err == nil {
-				minWords := val + 1
-				filters.WordCount = &minWords // simplified for exact matching
-			}
-		} else if match[2] != "" { // less than
-			if val, err := strconv.Atoi(match[2]); err == nil {
-				maxWords := val - 1
-				filters.WordCount = &maxWords // simplified for exact matching
-			}
-		} else if match[3] != "" { // exactly
-			if val, err := strconv.Atoi(match[3]); err == nil {
-				filters.WordCount = &val
-			}
-		}
+	if filters.HasProfanity != nil {
+		parsedFilters["has_profanity"] = *filters.HasProfanity
 	}
-
-	// Length filters
-	lengthPattern := regexp.MustCompile(`(?:length|characters?|chars?)\s*(?:(?:is\s*)?(?:greater\s+than|more\s+than|over|>)\s*(\d+)|(?:is\s*)?(?:less\s+than|fewer\s+than|under|<)\s*(\d+)|(?:is\s*)?(?:exactly\s*)?(\d+))`)
-	matches := lengthPattern.FindAllStringSubmatch(query, -1)
-	for _, match := range matches {
-		if match[1] != "" { // greater than
-			if val, err := strconv.Atoi(match[1]); err == nil {
-				filters.MinLength = &val
-			}
-		} else if match[2] != "" { // less than
-			if val, err := strconv.Atoi(match[2]); err == nil {
-				filters.MaxLength = &val
-			}
-		} else if match[3] != "" { // exactly
-			if val, err := strconv.Atoi(match[3]); err == nil {
-				filters.MinLength = &val
-				filters.MaxLength = &val
-			}
-		}
+	if filters.StartsWith != nil {
+		parsedFilters["starts_with"] = *filters.StartsWith
 	}
-
-	// Contains character
-	charPattern := regexp.MustCompile(`contains?\s+(?:the\s+)?(?:character|char|letter)\s+['"]?([a-zA-Z])['"]?`)
-	if charMatch := charPattern.FindStringSubmatch(query); len(charMatch) > 1 {
-		filters.ContainsCharacter = &charMatch[1]
+	if filters.EndsWith != nil {
+		parsedFilters["ends_with"] = *filters.EndsWith
+	}
+	if filters.CharAt != nil {
+		parsedFilters["char_at"] = *filters.CharAt
 	}
+	return parsedFilters
+}
 
-	// Contains text/substring
-	textPattern := regexp.MustCompile(`contains?\s+['"]([^'"]+)['"]`)
-	if textMatch := textPattern.FindStringSubmatch(query); len(textMatch) > 1 {
-		filters.ContainsText = &textMatch[1]
+// executeFilteredQuery compiles a filter tree and its ordering into the texts store query and runs it
+// for one page, returning the cursor of the next page or "" on the last one.
+// It backs both GET /strings and the natural-language endpoint
+func (cfg *apiConfig) executeFilteredQuery(ctx context.Context, query filter.Query, page pageRequest) ([]database.Text, string, error) {
+	where, args, err := filter.CompileAfter(query, page.cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	orderBy, err := filter.CompileOrder(query)
+	if err != nil {
+		return nil, "", err
 	}
 
-	// Handle "all" queries - just means no additional filtering needed beyond what's specified
-	if strings.HasPrefix(query, "all ") {
-		query = strings.TrimPrefix(query, "all ")
-		// Re-run parsing on the remaining query without "all"
-		if newFilters, err := parseNaturalLanguageQuery(query); err == nil {
-			return newFilters, nil
+	// a limit from the query caps the results across all pages
+	seen := 0
+	if page.cursor != nil {
+		seen = page.cursor.Seen
+	}
+	limit := 0
+	if query.Limit > 0 {
+		limit = query.Limit - seen
+		if limit <= 0 {
+			return []database.Text{}, "", nil
 		}
 	}
+	// fetch one extra row to know whether there is a next page
+	paged := page.size > 0 && (limit == 0 || page.size < limit)
+	if paged {
+		limit = page.size + 1
+	}
 
-	// If no patterns matched, return an error
-	if filters.IsPalindrome == nil && filters.MinLength == nil &&
-		filters.MaxLength == nil && filters.WordCount == nil &&
-		filters.ContainsCharacter == nil && filters.ContainsText == nil {
-		return filters, fmt.Errorf("could not parse query: '%s'. Try queries like 'palindromes', 'single word palindromes', 'length > 10', 'contains character a', etc.", originalQuery)
+	texts, err := cfg.DB.SearchTexts(ctx, database.SearchTextsParams{
+		Where:   where,
+		Args:    args,
+		OrderBy: orderBy,
+		Limit:   int32(limit),
+	})
+	if err != nil {
+		return nil, "", err
 	}
 
-	return filters, nil
+	nextCursor := ""
+	if paged && len(texts) > page.size {
+		texts = texts[:page.size]
+		last := texts[len(texts)-1]
+		nextCursor = filter.NewCursor(query, cursorValues(last, query.Keys()), seen+len(texts)).Encode()
+	}
+	return texts, nextCursor, nil
 }
 
-// the convertNLPFiltersToResponse function converts NLPFilters to the expected response format
-func convertNLPFiltersToResponse(filters NLPFilters) struct {
-	IsPalindrome      bool   `json:"is_palindrome"`
-	MinLength         int    `json:"min_length"`
-	MaxLength         int    `json:"max_length"`
-	WordCount         int    `json:"word_count"`
-	ContainsCharacter string `json:"contains_character"`
-} {
-	response := struct {
-		IsPalindrome      bool   `json:"is_palindrome"`
-		MinLength         int    `json:"min_length"`
-		MaxLength         int    `json:"max_length"`
-		WordCount         int    `json:"word_count"`
-		ContainsCharacter string `json:"contains_character"`
-	}{}
-
-	if filters.IsPalindrome != nil {
-		response.IsPalindrome = *filters.IsPalindrome
-	}
-	if filters.MinLength != nil {
-		response.MinLength = *filters.MinLength
-	}
-	if filters.MaxLength != nil {
-		response.MaxLength = *filters.MaxLength
+// countFilteredQuery counts the texts a filter tree matches across all pages, up to the limit of the query
+func (cfg *apiConfig) countFilteredQuery(ctx context.Context, query filter.Query) (int, error) {
+	where, args, err := filter.Compile(query.Where)
+	if err != nil {
+		return 0, err
 	}
-	if filters.WordCount != nil {
-		response.WordCount = *filters.WordCount
+	count, err := cfg.DB.CountTexts(ctx, where, args)
+	if err != nil {
+		return 0, err
 	}
-	if filters.ContainsCharacter != nil {
-		response.ContainsCharacter = *filters.ContainsCharacter
+	if query.Limit > 0 && count > int64(query.Limit) {
+		return query.Limit, nil
 	}
-
-	return response
+	return int(count), nil
 }
 
-// executeFilteredQuery runs the database query based on NLP filters
-func (cfg *apiConfig) executeFilteredQuery(ctx context.Context, filters NLPFilters) ([]struct {
-	ID         string `json:"id"`
-	Value      string `json:"value"`
-	Properties struct {
-		Length                int32          `json:"length"`
-		IsPalindrome          string         `json:"is_palindrome"`
-		UniqueCharacters      string         `json:"unique_characters"`
-		WordCount             string         `json:"word_count"`
-		Sha256Hash            string         `json:"sha256_hash"`
-		CharacterFrequencyMap map[string]int `json:"character_frequency_map"`
-	} `json:"properties"`
-	CreatedAt time.Time `json:"created_at"`
-}, error) {
-	// Get all texts and filter in Go for flexibility with natural language queries
-	allTexts, err := cfg.DB.GetAllTexts(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	var results []struct {
-		ID         string `json:"id"`
-		Value      string `json:"value"`
-		Properties struct {
-			Length                int32          `json:"length"`
-			IsPalindrome          string         `json:"is_palindrome"`
-			UniqueCharacters      string         `json:"unique_characters"`
-			WordCount             string         `json:"word_count"`
-			Sha256Hash            string         `json:"sha256_hash"`
-			CharacterFrequencyMap map[string]int `json:"character_frequency_map"`
-		} `json:"properties"`
-		CreatedAt time.Time `json:"created_at"`
-	}
-
-	for _, text := range allTexts {
-		// Apply filters
-		if filters.IsPalindrome != nil && text.IsPalindrome != *filters.IsPalindrome {
-			continue
-		}
-
-		if filters.MinLength != nil && text.Length < int32(*filters.MinLength) {
-			continue
-		}
-
-		if filters.MaxLength != nil && text.Length > int32(*filters.MaxLength) {
-			continue
-		}
-
-		if filters.WordCount != nil && text.WordCount != int32(*filters.WordCount) {
-			continue
-		}
-
-		if filters.ContainsCharacter != nil && !strings.Contains(text.Value, *filters.ContainsCharacter) {
-			continue
-		}
-
-		if filters.ContainsText != nil && !strings.Contains(text.Value, *filters.ContainsText) {
-			continue
-		}
-
+// buildTextResponses looks up the character counts of each text to build its full response
+func (cfg *apiConfig) buildTextResponses(ctx context.Context, texts []database.Text) []SuccessResponseBody {
+	results := make([]SuccessResponseBody, 0, len(texts))
+	for _, text := range texts {
 		// Get character counts for each text to build frequency map
 		charCounts, err := cfg.DB.GetCharacterCountsByID(ctx, text.ID)
 		if err != nil {
-			fmt.Printf("error getting character counts for text %s: %v", text.ID.String(), err)
+			requestLogger(ctx).Error("error getting character counts for text", "text_id", text.ID, "err", err)
 			// Continue with empty character frequency map rather than failing completely
 			charCounts = []database.GetCharacterCountsByIDRow{}
 		}
 
-		// Create character frequency map
-		characterFrequencyMap := make(map[string]int)
-		for _, charCount := range charCounts {
-			characterFrequencyMap[charCount.Character] = int(charCount.UniqueCharCount)
-		}
-
-		results = append(results, struct {
-			ID         string `json:"id"`
-			Value      string `json:"value"`
-			Properties struct {
-				Length                int32          `json:"length"`
-				IsPalindrome          string         `json:"is_palindrome"`
-				UniqueCharacters      string         `json:"unique_characters"`
-				WordCount             string         `json:"word_count"`
-				Sha256Hash            string         `json:"sha256_hash"`
-				CharacterFrequencyMap map[string]int `json:"character_frequency_map"`
-			} `json:"properties"`
-			CreatedAt time.Time `json:"created_at"`
-		}{
-			ID:        text.ID.String(),
-			Value:     text.Value,
-			CreatedAt: text.CreatedAt,
-			Properties: struct {
-				Length                int32          `json:"length"`
-				IsPalindrome          string         `json:"is_palindrome"`
-				UniqueCharacters      string         `json:"unique_characters"`
-				WordCount             string         `json:"word_count"`
-				Sha256Hash            string         `json:"sha256_hash"`
-				CharacterFrequencyMap map[string]int `json:"character_frequency_map"`
-			}{
-				Length:                text.Length,
-				IsPalindrome:          fmt.Sprintf("%t", text.IsPalindrome),
-				UniqueCharacters:      fmt.Sprintf("%d", len(charCounts)),
-				WordCount:             fmt.Sprintf("%d", text.WordCount),
-				Sha256Hash:            text.Sha256Hash,
-				CharacterFrequencyMap: characterFrequencyMap,
-			},
-		})
+		results = append(results, buildTextResponse(text, charCounts))
 	}
-
-	return results, nil
+	return results
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha512"
+	"fmt"
+	"hash/crc32"
+	"strings"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/config"
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"github.com/cespare/xxhash/v2"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/blake2b"
+)
+
+// hashAlgorithms maps each supported algorithm name to a function producing its hex digest
+var hashAlgorithms = map[string]func(string) string{
+	"md5": func(str string) string {
+		return fmt.Sprintf("%x", md5.Sum([]byte(str)))
+	},
+	"sha1": func(str string) string {
+		return fmt.Sprintf("%x", sha1.Sum([]byte(str)))
+	},
+	"sha256": generateHash,
+	"sha512": func(str string) string {
+		return fmt.Sprintf("%x", sha512.Sum512([]byte(str)))
+	},
+	"blake2b": func(str string) string {
+		return fmt.Sprintf("%x", blake2b.Sum512([]byte(str)))
+	},
+	"crc32": func(str string) string {
+		return fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(str)))
+	},
+	"xxhash64": func(str string) string {
+		return fmt.Sprintf("%016x", xxhash.Sum64String(str))
+	},
+	"simhash": func(str string) string {
+		return fmt.Sprintf("%016x", simHash(str))
+	},
+}
+
+// selectHashAlgorithms dedupes the configured algorithms, which the config already checked are
+// supported, and picks every supported one when none are configured
+func selectHashAlgorithms(configured []string) []string {
+	if len(configured) == 0 {
+		return config.SupportedHashAlgorithms
+	}
+	var algorithms []string
+	seen := make(map[string]bool)
+	for _, name := range configured {
+		if !seen[name] {
+			seen[name] = true
+			algorithms = append(algorithms, name)
+		}
+	}
+	return algorithms
+}
+
+// computeHashes returns the hex digest of str for every requested algorithm
+func computeHashes(str string, algorithms []string) map[string]string {
+	hashes := make(map[string]string, len(algorithms))
+	for _, algorithm := range algorithms {
+		hashes[algorithm] = hashAlgorithms[algorithm](str)
+	}
+	return hashes
+}
+
+// storeHashes writes every computed hash of a text in a single insert
+func storeHashes(ctx context.Context, q *database.Queries, stringID uuid.UUID, hashes map[string]string) error {
+	params := database.CreateTextHashesParams{StringID: stringID}
+	for algorithm, hashValue := range hashes {
+		params.Algorithms = append(params.Algorithms, algorithm)
+		params.HashValues = append(params.HashValues, hashValue)
+	}
+	if len(params.Algorithms) == 0 {
+		return nil
+	}
+	return q.CreateTextHashes(ctx, params)
+}
+
+// simHash builds a 64-bit fingerprint from character trigram shingles, so strings
+// that differ by a few characters end up a small Hamming distance apart
+func simHash(str string) uint64 {
+	runes := []rune(strings.ToLower(strings.Join(strings.Fields(str), " ")))
+	if len(runes) < 3 {
+		return xxhash.Sum64String(string(runes))
+	}
+
+	var weights [64]int
+	for i := 0; i+3 <= len(runes); i++ {
+		shingle := xxhash.Sum64String(string(runes[i : i+3]))
+		for bit := 0; bit < 64; bit++ {
+			if shingle&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit := 0; bit < 64; bit++ {
+		if weights[bit] > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/config"
+)
+
+// the config validates algorithm names against its own list, it has to match the implementations
+func TestSupportedHashAlgorithmsAreImplemented(t *testing.T) {
+	for _, algorithm := range config.SupportedHashAlgorithms {
+		if _, ok := hashAlgorithms[algorithm]; !ok {
+			t.Errorf("config supports %q, but it has no implementation", algorithm)
+		}
+	}
+	if len(hashAlgorithms) != len(config.SupportedHashAlgorithms) {
+		t.Errorf("%d algorithms are implemented, the config supports %d", len(hashAlgorithms), len(config.SupportedHashAlgorithms))
+	}
+}
+
+func TestSelectHashAlgorithms(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured []string
+		want       []string
+	}{
+		{"every supported one by default", nil, config.SupportedHashAlgorithms},
+		{"configured order", []string{"sha256", "md5"}, []string{"sha256", "md5"}},
+		{"duplicates dropped", []string{"md5", "sha1", "md5"}, []string{"md5", "sha1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectHashAlgorithms(tt.configured); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectHashAlgorithms(%q) = %q, want %q", tt.configured, got, tt.want)
+			}
+		})
+	}
+}
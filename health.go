@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"net/http"
+	"path"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// the migrations are embedded only to know which schema version this build expects
+//
+//go:embed sql/schema/*.sql
+var schemaMigrations embed.FS
+
+var expectedSchemaVersion = latestMigrationVersion(schemaMigrations)
+
+const (
+	healthStatusOK          = "ok"
+	healthStatusUnavailable = "unavailable"
+)
+
+// latestMigrationVersion returns the highest goose version prefix, e.g. 9 for 009_saved_searches.sql
+func latestMigrationVersion(migrations embed.FS) int64 {
+	files, err := migrations.ReadDir("sql/schema")
+	if err != nil {
+		return 0
+	}
+	var latest int64
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(path.Base(file.Name()), "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err == nil && version > latest {
+			latest = version
+		}
+	}
+	return latest
+}
+
+// Healthz reports that the process is up and serving, it never touches dependencies
+func (cfg *apiConfig) Healthz(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, HealthResponse{Status: healthStatusOK}, http.StatusOK)
+}
+
+// Readyz reports whether the service can handle traffic: the database answers and is migrated
+// to at least the schema version this build expects, each check is bounded by the ping timeout
+func (cfg *apiConfig) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), cfg.PingTimeout)
+	defer cancel()
+
+	response := HealthResponse{Status: healthStatusOK, Checks: map[string]HealthCheck{}}
+	fail := func(name string, check HealthCheck) {
+		check.Status = healthStatusUnavailable
+		response.Checks[name] = check
+		response.Status = healthStatusUnavailable
+	}
+
+	if err := cfg.Conn.PingContext(ctx); err != nil {
+		requestLogger(r.Context()).Warn("readiness check failed: database unreachable", "err", err)
+		fail("database", HealthCheck{Error: "database unreachable"})
+	} else {
+		response.Checks["database"] = HealthCheck{Status: healthStatusOK}
+	}
+
+	migrations := HealthCheck{ExpectedVersion: &expectedSchemaVersion}
+	version, err := cfg.DB.GetSchemaVersion(ctx)
+	switch {
+	case err != nil:
+		requestLogger(r.Context()).Warn("readiness check failed: schema version unknown", "err", err)
+		migrations.Error = "unable to read the schema version"
+		fail("migrations", migrations)
+	case version < expectedSchemaVersion:
+		migrations.CurrentVersion = &version
+		migrations.Error = "migrations are pending"
+		fail("migrations", migrations)
+	default:
+		migrations.CurrentVersion = &version
+		migrations.Status = healthStatusOK
+		response.Checks["migrations"] = migrations
+	}
+
+	status := http.StatusOK
+	if response.Status != healthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	respondWithJSON(w, response, status)
+}
+
+// Version reports what is running: the module version and VCS revision stamped into the binary
+// by the Go toolchain, and the schema version of the database it talks to
+func (cfg *apiConfig) Version(w http.ResponseWriter, r *http.Request) {
+	response := VersionResponse{ExpectedSchemaVersion: expectedSchemaVersion}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		response.Module = info.Main.Path
+		response.Version = info.Main.Version
+		response.GoVersion = info.GoVersion
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				response.Revision = setting.Value
+			case "vcs.time":
+				response.RevisionTime = setting.Value
+			case "vcs.modified":
+				response.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), cfg.PingTimeout)
+	defer cancel()
+	version, err := cfg.DB.GetSchemaVersion(ctx)
+	if err != nil {
+		// the build info is still worth reporting when the database is down
+		requestLogger(r.Context()).Warn("unable to read the schema version", "err", err)
+	} else {
+		response.SchemaVersion = &version
+	}
+	respondWithJSON(w, response, http.StatusOK)
+}
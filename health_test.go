@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func getReadiness(t *testing.T, cfg *apiConfig) (int, HealthResponse) {
+	t.Helper()
+	server := httptest.NewServer(newRouter(cfg))
+	defer server.Close()
+	res, err := http.Get(server.URL + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var health HealthResponse
+	if err := json.NewDecoder(res.Body).Decode(&health); err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, health
+}
+
+// schemaVersion answers GetSchemaVersion with the given migration
+func schemaVersion(version int64) fakeQuery {
+	return answer([]driver.Value{version})
+}
+
+func TestExpectedSchemaVersionIsTheLatestMigration(t *testing.T) {
+	files, err := schemaMigrations.ReadDir("sql/schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// migrations are numbered one after the other from 001
+	if want := int64(len(files)); expectedSchemaVersion != want {
+		t.Errorf("expectedSchemaVersion = %d, want %d", expectedSchemaVersion, want)
+	}
+}
+
+func TestReadyzComparesTheSchemaVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     fakeQuery
+		wantStatus  int
+		wantCurrent int64
+		wantError   string
+	}{
+		{"migrated", schemaVersion(expectedSchemaVersion), http.StatusOK, expectedSchemaVersion, ""},
+		{"migrated by a newer build", schemaVersion(expectedSchemaVersion + 1), http.StatusOK, expectedSchemaVersion + 1, ""},
+		{"migrations pending", schemaVersion(expectedSchemaVersion - 1), http.StatusServiceUnavailable, expectedSchemaVersion - 1, "migrations are pending"},
+		{"never migrated", func([]driver.Value) ([][]driver.Value, error) {
+			return nil, errors.New(`relation "goose_db_version" does not exist`)
+		}, http.StatusServiceUnavailable, 0, "unable to read the schema version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _ := newFakeDBConfig(t, map[string]fakeQuery{"GetSchemaVersion": tt.version})
+			status, health := getReadiness(t, cfg)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if database := health.Checks["database"]; database.Status != healthStatusOK {
+				t.Errorf("database check = %+v, want ok", database)
+			}
+
+			migrations := health.Checks["migrations"]
+			if migrations.Error != tt.wantError {
+				t.Errorf("migrations error = %q, want %q", migrations.Error, tt.wantError)
+			}
+			if migrations.ExpectedVersion == nil || *migrations.ExpectedVersion != expectedSchemaVersion {
+				t.Errorf("expected version = %v, want %d", migrations.ExpectedVersion, expectedSchemaVersion)
+			}
+			if tt.wantCurrent == 0 {
+				if migrations.CurrentVersion != nil {
+					t.Errorf("current version = %d, want none", *migrations.CurrentVersion)
+				}
+			} else if migrations.CurrentVersion == nil || *migrations.CurrentVersion != tt.wantCurrent {
+				t.Errorf("current version = %v, want %d", migrations.CurrentVersion, tt.wantCurrent)
+			}
+		})
+	}
+}
+
+func TestReadyzGivesUpOnASlowDatabase(t *testing.T) {
+	cfg, fake := newFakeDBConfig(t, map[string]fakeQuery{"GetSchemaVersion": schemaVersion(expectedSchemaVersion)})
+	cfg.PingTimeout = 20 * time.Millisecond
+	// the database never answers the ping, only the deadline ends it
+	fake.ping = func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	start := time.Now()
+	status, health := getReadiness(t, cfg)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("readiness took %v, want about the ping timeout", elapsed)
+	}
+	if status != http.StatusServiceUnavailable || health.Status != healthStatusUnavailable {
+		t.Errorf("status = %d %q, want %d %q", status, health.Status, http.StatusServiceUnavailable, healthStatusUnavailable)
+	}
+	if database := health.Checks["database"]; database.Status != healthStatusUnavailable || database.Error != "database unreachable" {
+		t.Errorf("database check = %+v, want it unreachable", database)
+	}
+}
+
+func TestReadyzReportsAnUnreachableDatabase(t *testing.T) {
+	cfg, fake := newFakeDBConfig(t, map[string]fakeQuery{"GetSchemaVersion": schemaVersion(expectedSchemaVersion)})
+	fake.ping = func(context.Context) error { return errors.New("connection refused") }
+
+	status, health := getReadiness(t, cfg)
+	if status != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", status, http.StatusServiceUnavailable)
+	}
+	if database := health.Checks["database"]; database.Status != healthStatusUnavailable {
+		t.Errorf("database check = %+v, want it unavailable", database)
+	}
+	// each check is reported on its own
+	if migrations := health.Checks["migrations"]; migrations.Status != healthStatusOK {
+		t.Errorf("migrations check = %+v, want ok", migrations)
+	}
+}
@@ -0,0 +1,547 @@
+// Package config loads the service settings. Each setting is read, in increasing priority, from its
+// default, an optional YAML file, an environment variable and a command-line flag.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Analyzers that can be switched off in Analyzers.Enabled
+const (
+	AnalyzerLanguage  = "language"
+	AnalyzerSentiment = "sentiment"
+	AnalyzerProfanity = "profanity"
+	AnalyzerNgrams    = "ngrams"
+)
+
+// minAdminKeyLength keeps a hand-picked admin key from being guessable
+const minAdminKeyLength = 32
+
+var allAnalyzers = []string{AnalyzerLanguage, AnalyzerSentiment, AnalyzerProfanity, AnalyzerNgrams}
+
+// SupportedHashAlgorithms can be listed in Analyzers.HashAlgorithms, all of them are computed when it is empty
+var SupportedHashAlgorithms = []string{"md5", "sha1", "sha256", "sha512", "blake2b", "crc32", "xxhash64", "simhash"}
+
+type Config struct {
+	Server          Server          `yaml:"server"`
+	Database        Database        `yaml:"database"`
+	Limits          Limits          `yaml:"limits"`
+	Auth            Auth            `yaml:"auth"`
+	RateLimit       RateLimit       `yaml:"rate_limit"`
+	Quotas          Quotas          `yaml:"quotas"`
+	Analyzers       Analyzers       `yaml:"analyzers"`
+	NaturalLanguage NaturalLanguage `yaml:"natural_language"`
+	Logging         Logging         `yaml:"logging"`
+	Tracing         Tracing         `yaml:"tracing"`
+}
+
+type Server struct {
+	Port                int           `yaml:"port"`
+	ReadHeaderTimeout   time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout         time.Duration `yaml:"read_timeout"`
+	WriteTimeout        time.Duration `yaml:"write_timeout"`
+	IdleTimeout         time.Duration `yaml:"idle_timeout"`
+	ShutdownGracePeriod time.Duration `yaml:"shutdown_grace_period"`
+}
+
+type Database struct {
+	// URL holds the password, it is redacted when the config is printed
+	URL             string        `yaml:"url"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	// PingTimeout bounds the startup ping and every readiness check
+	PingTimeout time.Duration `yaml:"ping_timeout"`
+}
+
+type Limits struct {
+	// MaxBodyBytes caps request bodies, larger ones are rejected with 413
+	MaxBodyBytes int64 `yaml:"max_body_bytes"`
+}
+
+type Auth struct {
+	// Enabled requires an API key with the right scope on every route except probes and metrics
+	Enabled bool `yaml:"enabled"`
+	// AdminKey is a key with every scope that isn't stored in the database, used to issue the first keys
+	AdminKey string `yaml:"admin_key"`
+}
+
+// RateLimit sets a token bucket per client, an API key or else an IP address, for each class of route
+type RateLimit struct {
+	Enabled bool `yaml:"enabled"`
+	// TrustForwardedFor takes the client IP from X-Forwarded-For, only safe behind a proxy that sets it
+	TrustForwardedFor bool   `yaml:"trust_forwarded_for"`
+	Read              Bucket `yaml:"read"`
+	Write             Bucket `yaml:"write"`
+	Search            Bucket `yaml:"search"`
+	// AuthFailures limits the invalid keys an IP address may present, each refused key takes a token
+	AuthFailures Bucket `yaml:"auth_failures"`
+}
+
+// Bucket refills PerMinute tokens a minute up to Burst, every request takes one
+type Bucket struct {
+	PerMinute int `yaml:"per_minute"`
+	Burst     int `yaml:"burst"`
+}
+
+type Quotas struct {
+	// DailyTexts caps the texts each client may create per UTC day, 0 for no cap
+	DailyTexts int `yaml:"daily_texts"`
+}
+
+type Analyzers struct {
+	// Enabled lists the optional analyzers run on every new text
+	Enabled []string `yaml:"enabled"`
+	// HashAlgorithms are computed for every new text, every supported one when empty
+	HashAlgorithms     []string `yaml:"hash_algorithms"`
+	ProfanityBlocklist string   `yaml:"profanity_blocklist"`
+	// NgramMaxLength is the longest text, in characters, whose n-grams are stored on create
+	NgramMaxLength int `yaml:"ngram_max_length"`
+}
+
+// IsEnabled reports whether analyzer runs on new texts
+func (a Analyzers) IsEnabled(analyzer string) bool {
+	for _, enabled := range a.Enabled {
+		if enabled == analyzer {
+			return true
+		}
+	}
+	return false
+}
+
+type NaturalLanguage struct {
+	Interpreter string `yaml:"interpreter"`
+	RulesFile   string `yaml:"rules_file"`
+}
+
+type Logging struct {
+	Format string `yaml:"format"`
+	Level  string `yaml:"level"`
+}
+
+type Tracing struct {
+	Exporter     string `yaml:"exporter"`
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+	ServiceName  string `yaml:"service_name"`
+}
+
+// Default returns the settings used when nothing overrides them
+func Default() Config {
+	return Config{
+		Server: Server{
+			Port:                8080,
+			ReadHeaderTimeout:   5 * time.Second,
+			ReadTimeout:         15 * time.Second,
+			WriteTimeout:        30 * time.Second,
+			IdleTimeout:         60 * time.Second,
+			ShutdownGracePeriod: 30 * time.Second,
+		},
+		Database: Database{
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			PingTimeout:     2 * time.Second,
+		},
+		Limits: Limits{
+			MaxBodyBytes: 1 << 20,
+		},
+		Auth: Auth{
+			Enabled: true,
+		},
+		RateLimit: RateLimit{
+			Enabled: true,
+			Read:    Bucket{PerMinute: 600, Burst: 100},
+			Write:   Bucket{PerMinute: 60, Burst: 20},
+			Search:  Bucket{PerMinute: 30, Burst: 10},
+			// a client with a valid key never fails, so this only slows down guessing
+			AuthFailures: Bucket{PerMinute: 10, Burst: 20},
+		},
+		Analyzers: Analyzers{
+			Enabled:        append([]string{}, allAnalyzers...),
+			NgramMaxLength: 10000,
+		},
+		NaturalLanguage: NaturalLanguage{
+			Interpreter: "grammar",
+		},
+		Logging: Logging{
+			Format: "text",
+			Level:  "info",
+		},
+		Tracing: Tracing{
+			Exporter:     "none",
+			OTLPEndpoint: "http://localhost:4318",
+			ServiceName:  "text-analyzer-api",
+		},
+	}
+}
+
+// Options are the command-line flags that aren't settings
+type Options struct {
+	// File is the YAML file the settings were read from, if any
+	File        string
+	PrintConfig bool
+	// Backfill recomputes the analyzer output of stored texts instead of serving
+	Backfill bool
+}
+
+// setting ties a flag, named after the setting's path in the YAML file, to its environment variable and field
+type setting struct {
+	key   string
+	env   string
+	usage string
+	value value
+}
+
+// settings lists every setting in the order it is printed
+func (c *Config) settings() []setting {
+	return []setting{
+		{key: "server.port", env: "PORT", usage: "port to listen on", value: intValue{&c.Server.Port}},
+		{key: "server.read_header_timeout", env: "HTTP_READ_HEADER_TIMEOUT", usage: "time allowed to read request headers", value: durationValue{&c.Server.ReadHeaderTimeout}},
+		{key: "server.read_timeout", env: "HTTP_READ_TIMEOUT", usage: "time allowed to read a whole request", value: durationValue{&c.Server.ReadTimeout}},
+		{key: "server.write_timeout", env: "HTTP_WRITE_TIMEOUT", usage: "time allowed to write a response", value: durationValue{&c.Server.WriteTimeout}},
+		{key: "server.idle_timeout", env: "HTTP_IDLE_TIMEOUT", usage: "time a keep-alive connection may sit idle", value: durationValue{&c.Server.IdleTimeout}},
+		{key: "server.shutdown_grace_period", env: "SHUTDOWN_GRACE_PERIOD", usage: "time in-flight requests get to finish on shutdown", value: durationValue{&c.Server.ShutdownGracePeriod}},
+
+		{key: "database.url", env: "DB_URL", usage: "PostgreSQL connection URL", value: stringValue{&c.Database.URL}},
+		{key: "database.max_open_conns", env: "DB_MAX_OPEN_CONNS", usage: "maximum open connections, 0 for no limit", value: intValue{&c.Database.MaxOpenConns}},
+		{key: "database.max_idle_conns", env: "DB_MAX_IDLE_CONNS", usage: "maximum idle connections kept in the pool", value: intValue{&c.Database.MaxIdleConns}},
+		{key: "database.conn_max_lifetime", env: "DB_CONN_MAX_LIFETIME", usage: "maximum age of a connection, 0 for no limit", value: durationValue{&c.Database.ConnMaxLifetime}},
+		{key: "database.conn_max_idle_time", env: "DB_CONN_MAX_IDLE_TIME", usage: "maximum idle time of a connection, 0 for no limit", value: durationValue{&c.Database.ConnMaxIdleTime}},
+		{key: "database.ping_timeout", env: "DB_PING_TIMEOUT", usage: "time allowed for the startup ping and readiness checks", value: durationValue{&c.Database.PingTimeout}},
+
+		{key: "limits.max_body_bytes", env: "MAX_BODY_BYTES", usage: "largest request body accepted, in bytes", value: int64Value{&c.Limits.MaxBodyBytes}},
+
+		{key: "auth.enabled", env: "AUTH_ENABLED", usage: "require API keys", value: boolValue{&c.Auth.Enabled}},
+		{key: "auth.admin_key", env: "ADMIN_API_KEY", usage: "key with every scope, for issuing the first keys", value: stringValue{&c.Auth.AdminKey}},
+
+		{key: "rate_limit.enabled", env: "RATE_LIMIT_ENABLED", usage: "limit the request rate of each client", value: boolValue{&c.RateLimit.Enabled}},
+		{key: "rate_limit.trust_forwarded_for", env: "RATE_LIMIT_TRUST_FORWARDED_FOR", usage: "identify clients without a key by X-Forwarded-For", value: boolValue{&c.RateLimit.TrustForwardedFor}},
+		{key: "rate_limit.read.per_minute", env: "RATE_LIMIT_READ_PER_MINUTE", usage: "read requests allowed per client per minute", value: intValue{&c.RateLimit.Read.PerMinute}},
+		{key: "rate_limit.read.burst", env: "RATE_LIMIT_READ_BURST", usage: "read requests a client may make at once", value: intValue{&c.RateLimit.Read.Burst}},
+		{key: "rate_limit.write.per_minute", env: "RATE_LIMIT_WRITE_PER_MINUTE", usage: "write requests allowed per client per minute", value: intValue{&c.RateLimit.Write.PerMinute}},
+		{key: "rate_limit.write.burst", env: "RATE_LIMIT_WRITE_BURST", usage: "write requests a client may make at once", value: intValue{&c.RateLimit.Write.Burst}},
+		{key: "rate_limit.search.per_minute", env: "RATE_LIMIT_SEARCH_PER_MINUTE", usage: "search requests allowed per client per minute", value: intValue{&c.RateLimit.Search.PerMinute}},
+		{key: "rate_limit.search.burst", env: "RATE_LIMIT_SEARCH_BURST", usage: "search requests a client may make at once", value: intValue{&c.RateLimit.Search.Burst}},
+		{key: "rate_limit.auth_failures.per_minute", env: "RATE_LIMIT_AUTH_FAILURES_PER_MINUTE", usage: "invalid API keys allowed per IP address per minute", value: intValue{&c.RateLimit.AuthFailures.PerMinute}},
+		{key: "rate_limit.auth_failures.burst", env: "RATE_LIMIT_AUTH_FAILURES_BURST", usage: "invalid API keys an IP address may present at once", value: intValue{&c.RateLimit.AuthFailures.Burst}},
+
+		{key: "quotas.daily_texts", env: "QUOTA_DAILY_TEXTS", usage: "texts each client may create per UTC day, 0 for no quota", value: intValue{&c.Quotas.DailyTexts}},
+
+		{key: "analyzers.enabled", env: "ANALYZERS", usage: "analyzers run on new texts: " + strings.Join(allAnalyzers, ", "), value: listValue{&c.Analyzers.Enabled}},
+		{key: "analyzers.hash_algorithms", env: "HASH_ALGORITHMS", usage: "hash algorithms computed for new texts, every supported one when empty", value: listValue{&c.Analyzers.HashAlgorithms}},
+		{key: "analyzers.ngram_max_length", env: "NGRAM_MAX_LENGTH", usage: "longest text, in characters, whose n-grams are stored on create", value: intValue{&c.Analyzers.NgramMaxLength}},
+		{key: "analyzers.profanity_blocklist", env: "PROFANITY_BLOCKLIST", usage: "file with extra blocklisted words or phrases, one per line", value: stringValue{&c.Analyzers.ProfanityBlocklist}},
+
+		{key: "natural_language.interpreter", env: "NL_INTERPRETER", usage: "natural-language interpreter: grammar or rules", value: stringValue{&c.NaturalLanguage.Interpreter}},
+		{key: "natural_language.rules_file", env: "NL_RULES_FILE", usage: "rule file for the rules interpreter", value: stringValue{&c.NaturalLanguage.RulesFile}},
+
+		{key: "logging.format", env: "LOG_FORMAT", usage: "log format: text or json", value: stringValue{&c.Logging.Format}},
+		{key: "logging.level", env: "LOG_LEVEL", usage: "log level: debug, info, warn or error", value: stringValue{&c.Logging.Level}},
+
+		{key: "tracing.exporter", env: "OTEL_TRACES_EXPORTER", usage: "traces exporter: otlp, stdout or none", value: stringValue{&c.Tracing.Exporter}},
+		{key: "tracing.otlp_endpoint", env: "OTEL_EXPORTER_OTLP_ENDPOINT", usage: "OTLP/HTTP collector endpoint", value: stringValue{&c.Tracing.OTLPEndpoint}},
+		{key: "tracing.service_name", env: "OTEL_SERVICE_NAME", usage: "service name reported with spans", value: stringValue{&c.Tracing.ServiceName}},
+	}
+}
+
+// Load builds the config from args (without the program name) and the environment. The YAML file is
+// named by --config or CONFIG_FILE. The config is validated, every problem is reported at once,
+// alongside the config and options as far as they could be resolved so --print-config still works
+func Load(args []string, lookupEnv func(string) (string, bool)) (Config, Options, error) {
+	config := Default()
+	settings := config.settings()
+
+	// flags are parsed first to find the config file, but applied last
+	var options Options
+	flags := flag.NewFlagSet("text-analyzer-api", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(&options.File, "config", "", "YAML config file")
+	flags.BoolVar(&options.PrintConfig, "print-config", false, "print the effective config, secrets redacted, and exit")
+	flags.BoolVar(&options.Backfill, "backfill", false, "recompute the analyzer output of stored texts and exit")
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		flags.Func(s.key, s.usage, func(raw string) error {
+			flagValues[s.key] = raw
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, Options{}, err
+	}
+	if flags.NArg() > 0 {
+		return Config{}, Options{}, fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+	if options.File == "" {
+		options.File, _ = lookupEnv("CONFIG_FILE")
+	}
+
+	var errs []error
+	if options.File != "" {
+		if err := readYAMLFile(options.File, &config); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, apply(settings, func(s setting) (string, string, bool) {
+		raw, ok := lookupEnv(s.env)
+		return s.env, raw, ok
+	})...)
+	errs = append(errs, apply(settings, func(s setting) (string, string, bool) {
+		raw, ok := flagValues[s.key]
+		return "--" + s.key, raw, ok
+	})...)
+
+	errs = append(errs, config.validate()...)
+	return config, options, errors.Join(errs...)
+}
+
+// apply sets every setting source has a value for, naming the source in errors
+func apply(settings []setting, source func(setting) (string, string, bool)) []error {
+	var errs []error
+	for _, s := range settings {
+		name, raw, ok := source(s)
+		if !ok {
+			continue
+		}
+		if err := s.value.set(raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+func (c *Config) validate() []error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port: must be between 1 and 65535, got %d", c.Server.Port)
+	for _, timeout := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"server.read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"database.ping_timeout", c.Database.PingTimeout},
+	} {
+		check(timeout.value > 0, "%s: must be positive, got %s", timeout.key, timeout.value)
+	}
+	check(c.Server.ShutdownGracePeriod >= 0, "server.shutdown_grace_period: must not be negative")
+
+	if c.Database.URL == "" {
+		errs = append(errs, errors.New("database.url: must be set, e.g. with DB_URL"))
+	} else if u, err := url.Parse(c.Database.URL); err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		errs = append(errs, errors.New("database.url: must be a postgres:// URL"))
+	}
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns: must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns: must not be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns: must not exceed database.max_open_conns (%d)", c.Database.MaxOpenConns)
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime: must not be negative")
+	check(c.Database.ConnMaxIdleTime >= 0, "database.conn_max_idle_time: must not be negative")
+
+	check(c.Limits.MaxBodyBytes > 0, "limits.max_body_bytes: must be positive, got %d", c.Limits.MaxBodyBytes)
+
+	// without the admin key no key could ever be issued, so every protected endpoint would be unreachable
+	check(!c.Auth.Enabled || c.Auth.AdminKey != "", "auth.admin_key: must be set when auth is enabled, e.g. with ADMIN_API_KEY")
+	check(c.Auth.AdminKey == "" || len(c.Auth.AdminKey) >= minAdminKeyLength, "auth.admin_key: must be at least %d characters", minAdminKeyLength)
+
+	if c.RateLimit.Enabled {
+		for _, bucket := range []struct {
+			class string
+			value Bucket
+		}{
+			{"read", c.RateLimit.Read},
+			{"write", c.RateLimit.Write},
+			{"search", c.RateLimit.Search},
+			{"auth_failures", c.RateLimit.AuthFailures},
+		} {
+			check(bucket.value.PerMinute > 0, "rate_limit.%s.per_minute: must be positive, got %d", bucket.class, bucket.value.PerMinute)
+			check(bucket.value.Burst > 0, "rate_limit.%s.burst: must be positive, got %d", bucket.class, bucket.value.Burst)
+		}
+	}
+	check(c.Quotas.DailyTexts >= 0, "quotas.daily_texts: must not be negative")
+
+	for _, analyzer := range c.Analyzers.Enabled {
+		check(oneOf(analyzer, allAnalyzers...), "analyzers.enabled: unknown analyzer %q, must be one of %s", analyzer, strings.Join(allAnalyzers, ", "))
+	}
+	for _, algorithm := range c.Analyzers.HashAlgorithms {
+		check(oneOf(algorithm, SupportedHashAlgorithms...), "analyzers.hash_algorithms: unsupported hash algorithm %q, must be one of %s", algorithm, strings.Join(SupportedHashAlgorithms, ", "))
+	}
+	check(c.Analyzers.NgramMaxLength > 0, "analyzers.ngram_max_length: must be positive, got %d", c.Analyzers.NgramMaxLength)
+	if c.Analyzers.ProfanityBlocklist != "" {
+		check(readable(c.Analyzers.ProfanityBlocklist), "analyzers.profanity_blocklist: can't read %s", c.Analyzers.ProfanityBlocklist)
+	}
+
+	check(oneOf(c.NaturalLanguage.Interpreter, "grammar", "rules"), "natural_language.interpreter: must be grammar or rules, got %q", c.NaturalLanguage.Interpreter)
+	if c.NaturalLanguage.Interpreter == "rules" {
+		check(c.NaturalLanguage.RulesFile != "", "natural_language.rules_file: must be set for the rules interpreter")
+	}
+	if c.NaturalLanguage.RulesFile != "" {
+		check(readable(c.NaturalLanguage.RulesFile), "natural_language.rules_file: can't read %s", c.NaturalLanguage.RulesFile)
+	}
+
+	check(oneOf(strings.ToLower(c.Logging.Format), "text", "json"), "logging.format: must be text or json, got %q", c.Logging.Format)
+	check(oneOf(strings.ToLower(c.Logging.Level), "debug", "info", "warn", "error"), "logging.level: must be debug, info, warn or error, got %q", c.Logging.Level)
+
+	check(oneOf(strings.ToLower(c.Tracing.Exporter), "otlp", "stdout", "console", "none"), "tracing.exporter: must be otlp, stdout or none, got %q", c.Tracing.Exporter)
+	if strings.ToLower(c.Tracing.Exporter) == "otlp" {
+		u, err := url.Parse(c.Tracing.OTLPEndpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "tracing.otlp_endpoint: must be an http(s) URL, got %q", c.Tracing.OTLPEndpoint)
+	}
+	return errs
+}
+
+// readable reports whether path is a file that can be opened, its contents are checked when it is loaded
+func readable(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	return err == nil && !info.IsDir()
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes the config as YAML that Load accepts, with secrets redacted
+func (c Config) Print(w io.Writer) error {
+	c.Database.URL = redact(c.Database.URL)
+	c.Auth.AdminKey = redact(c.Auth.AdminKey)
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// redact hides the password of a URL with credentials, or the whole value otherwise
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if u, err := url.Parse(secret); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return "REDACTED"
+}
+
+// value is a typed setting that can be set from the text of a variable or flag
+type value interface {
+	set(raw string) error
+	String() string
+}
+
+type stringValue struct{ p *string }
+
+func (v stringValue) set(raw string) error {
+	*v.p = strings.TrimSpace(raw)
+	return nil
+}
+func (v stringValue) String() string { return *v.p }
+
+type intValue struct{ p *int }
+
+func (v intValue) set(raw string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", raw)
+	}
+	*v.p = n
+	return nil
+}
+func (v intValue) String() string { return strconv.Itoa(*v.p) }
+
+type int64Value struct{ p *int64 }
+
+func (v int64Value) set(raw string) error {
+	n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", raw)
+	}
+	*v.p = n
+	return nil
+}
+func (v int64Value) String() string { return strconv.FormatInt(*v.p, 10) }
+
+type boolValue struct{ p *bool }
+
+func (v boolValue) set(raw string) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("%q is not a boolean", raw)
+	}
+	*v.p = b
+	return nil
+}
+func (v boolValue) String() string { return strconv.FormatBool(*v.p) }
+
+type durationValue struct{ p *time.Duration }
+
+func (v durationValue) set(raw string) error {
+	d, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("%q is not a duration such as 30s or 5m", raw)
+	}
+	*v.p = d
+	return nil
+}
+func (v durationValue) String() string { return v.p.String() }
+
+// listValue is comma separated in variables and flags
+type listValue struct{ p *[]string }
+
+func (v listValue) set(raw string) error {
+	*v.p = normalizeList(strings.Split(raw, ","))
+	return nil
+}
+func (v listValue) String() string { return strings.Join(*v.p, ",") }
+
+// normalizeList lowercases the items of a list setting and drops empty ones
+func normalizeList(items []string) []string {
+	var normalized []string
+	for _, item := range items {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			normalized = append(normalized, item)
+		}
+	}
+	return normalized
+}
+
+// Usage writes every flag with its environment variable
+func Usage(w io.Writer) {
+	config := Default()
+	fmt.Fprintln(w, "Usage: text-analyzer-api [--config file.yaml] [--print-config] [--backfill] [--<setting>=<value> ...]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Settings are read from their default, the YAML file (--config or CONFIG_FILE),")
+	fmt.Fprintln(w, "the environment and flags, each overriding the one before:")
+	fmt.Fprintln(w)
+	for _, s := range config.settings() {
+		fmt.Fprintf(w, "  --%s (%s)\n        %s, default %q\n", s.key, s.env, s.usage, s.value.String())
+	}
+}
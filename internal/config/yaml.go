@@ -0,0 +1,30 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// readYAMLFile decodes a config file over config, the settings it leaves out keep their values.
+// Unknown keys are errors, so a misspelt setting isn't silently ignored
+func readYAMLFile(path string, config *Config) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	// an empty file sets nothing
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	config.Analyzers.Enabled = normalizeList(config.Analyzers.Enabled)
+	config.Analyzers.HashAlgorithms = normalizeList(config.Analyzers.HashAlgorithms)
+	return nil
+}
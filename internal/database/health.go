@@ -0,0 +1,23 @@
+package database
+
+import (
+	"context"
+)
+
+// getSchemaVersion reads goose's own bookkeeping table, which isn't part of the sqlc schema,
+// so this query lives outside the generated files
+const getSchemaVersion = `-- name: GetSchemaVersion :one
+SELECT version_id
+FROM goose_db_version
+WHERE is_applied
+ORDER BY id DESC
+LIMIT 1
+`
+
+// GetSchemaVersion returns the version of the last goose migration applied to the database
+func (q *Queries) GetSchemaVersion(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getSchemaVersion)
+	var version_id int64
+	err := row.Scan(&version_id)
+	return version_id, err
+}
@@ -5,11 +5,25 @@
 package database
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type ApiKey struct {
+	ID         uuid.UUID
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     []string
+	CreatedBy  uuid.NullUUID
+	CreatedAt  time.Time
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
 type CharacterCount struct {
 	ID              uuid.UUID
 	StringID        uuid.UUID
@@ -17,12 +31,57 @@ type CharacterCount struct {
 	UniqueCharCount int32
 }
 
+type NgramCount struct {
+	ID         uuid.UUID
+	StringID   uuid.UUID
+	Unit       string
+	N          int32
+	Ngram      string
+	NgramCount int32
+}
+
+type SavedSearch struct {
+	ID                   uuid.UUID
+	Name                 string
+	Kind                 string
+	Filters              json.RawMessage
+	NaturalLanguageQuery string
+	Locale               string
+	ParsedFilters        json.RawMessage
+	Query                json.RawMessage
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	LastRunAt            sql.NullTime
+	LastResultCount      sql.NullInt32
+}
+
 type Text struct {
-	ID           uuid.UUID
-	Value        string
-	Length       int32
-	IsPalindrome bool
-	WordCount    int32
-	Sha256Hash   string
-	CreatedAt    time.Time
+	ID                 uuid.UUID
+	Value              string
+	Length             int32
+	IsPalindrome       bool
+	WordCount          int32
+	Sha256Hash         string
+	CreatedAt          time.Time
+	Language           string
+	LanguageConfidence float64
+	Script             string
+	NormalizedValue    string
+	SentimentScore     float64
+	HasProfanity       bool
+	CreatedByKey       uuid.NullUUID
+	FuzzyKeyLength     int32
+}
+
+type TextHash struct {
+	ID        uuid.UUID
+	StringID  uuid.UUID
+	Algorithm string
+	HashValue string
+}
+
+type TextQuotaUsage struct {
+	Client       string
+	Day          time.Time
+	TextsCreated int32
 }
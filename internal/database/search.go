@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// countTexts is completed at runtime like searchTexts, with the same WHERE clause
+const countTexts = `-- name: CountTexts :one
+SELECT COUNT(*)
+FROM texts t
+WHERE `
+
+// CountTexts counts the texts matching where, a parameterized SQL boolean expression over the texts table aliased as "t"
+func (q *Queries) CountTexts(ctx context.Context, where string, args []interface{}) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countTexts+where, args...)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+// searchTexts is completed at runtime with a WHERE clause compiled from a filter tree,
+// which sqlc can't express, so this query lives outside the generated files
+const searchTexts = `-- name: SearchTexts :many
+SELECT
+    t.id,
+    t.value,
+    t.length,
+    t.is_palindrome,
+    t.word_count,
+    t.sha256_hash,
+    t.created_at,
+    t.language,
+    t.language_confidence,
+    t.script,
+    t.normalized_value,
+    t.sentiment_score,
+    t.has_profanity,
+    t.created_by_key,
+    t.fuzzy_key_length
+FROM texts t
+WHERE `
+
+type SearchTextsParams struct {
+	// Where is a parameterized SQL boolean expression over the texts table aliased as "t"
+	Where string
+	Args  []interface{}
+	// OrderBy is a comma separated list of "t" columns with directions, defaulting to newest first
+	OrderBy string
+	// Limit caps the number of rows when greater than zero
+	Limit int32
+}
+
+func (q *Queries) SearchTexts(ctx context.Context, arg SearchTextsParams) ([]Text, error) {
+	orderBy := arg.OrderBy
+	if orderBy == "" {
+		orderBy = "t.created_at DESC"
+	}
+	query := searchTexts + arg.Where + "\nORDER BY " + orderBy
+	if arg.Limit > 0 {
+		query += fmt.Sprintf("\nLIMIT %d", arg.Limit)
+	}
+	rows, err := q.db.QueryContext(ctx, query, arg.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Text
+	for rows.Next() {
+		var i Text
+		if err := rows.Scan(
+			&i.ID,
+			&i.Value,
+			&i.Length,
+			&i.IsPalindrome,
+			&i.WordCount,
+			&i.Sha256Hash,
+			&i.CreatedAt,
+			&i.Language,
+			&i.LanguageConfidence,
+			&i.Script,
+			&i.NormalizedValue,
+			&i.SentimentScore,
+			&i.HasProfanity,
+			&i.CreatedByKey,
+			&i.FuzzyKeyLength,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
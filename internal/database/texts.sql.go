@@ -7,34 +7,30 @@ package database
 
 import (
 	"context"
-	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-const createCharCount = `-- name: CreateCharCount :exec
+const createCharCounts = `-- name: CreateCharCounts :exec
 INSERT INTO character_count (id, string_id, character, unique_char_count)
-VALUES (
-    gen_random_uuid(),
-    $1,
-    $2,
-    $3
-)
+SELECT gen_random_uuid(), $1::uuid, character, unique_char_count
+FROM unnest($2::text[], $3::int[]) AS c(character, unique_char_count)
 `
 
-type CreateCharCountParams struct {
-	StringID        uuid.UUID
-	Character       string
-	UniqueCharCount int32
+type CreateCharCountsParams struct {
+	StringID         uuid.UUID
+	Characters       []string
+	UniqueCharCounts []int32
 }
 
-func (q *Queries) CreateCharCount(ctx context.Context, arg CreateCharCountParams) error {
-	_, err := q.db.ExecContext(ctx, createCharCount, arg.StringID, arg.Character, arg.UniqueCharCount)
+func (q *Queries) CreateCharCounts(ctx context.Context, arg CreateCharCountsParams) error {
+	_, err := q.db.ExecContext(ctx, createCharCounts, arg.StringID, pq.Array(arg.Characters), pq.Array(arg.UniqueCharCounts))
 	return err
 }
 
 const createText = `-- name: CreateText :one
-INSERT INTO texts (id, value, length, is_palindrome, word_count, sha256_hash, created_at)
+INSERT INTO texts (id, value, length, is_palindrome, word_count, sha256_hash, created_at, language, language_confidence, script, normalized_value, sentiment_score, has_profanity, created_by_key, fuzzy_key_length)
 VALUES (
     gen_random_uuid(),
     $1,
@@ -42,17 +38,33 @@ VALUES (
     $3,
     $4,
     $5,
-    NOW()
+    NOW(),
+    $6,
+    $7,
+    $8,
+    $9,
+    $10,
+    $11,
+    $12,
+    $13
 )
 RETURNING id
 `
 
 type CreateTextParams struct {
-	Value        string
-	Length       int32
-	IsPalindrome bool
-	WordCount    int32
-	Sha256Hash   string
+	Value              string
+	Length             int32
+	IsPalindrome       bool
+	WordCount          int32
+	Sha256Hash         string
+	Language           string
+	LanguageConfidence float64
+	Script             string
+	NormalizedValue    string
+	SentimentScore     float64
+	HasProfanity       bool
+	CreatedByKey       uuid.NullUUID
+	FuzzyKeyLength     int32
 }
 
 func (q *Queries) CreateText(ctx context.Context, arg CreateTextParams) (uuid.UUID, error) {
@@ -62,6 +74,14 @@ func (q *Queries) CreateText(ctx context.Context, arg CreateTextParams) (uuid.UU
 		arg.IsPalindrome,
 		arg.WordCount,
 		arg.Sha256Hash,
+		arg.Language,
+		arg.LanguageConfidence,
+		arg.Script,
+		arg.NormalizedValue,
+		arg.SentimentScore,
+		arg.HasProfanity,
+		arg.CreatedByKey,
+		arg.FuzzyKeyLength,
 	)
 	var id uuid.UUID
 	err := row.Scan(&id)
@@ -89,7 +109,7 @@ func (q *Queries) DeleteTextWithValue(ctx context.Context, value string) error {
 }
 
 const getAllTexts = `-- name: GetAllTexts :many
-SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at 
+SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at, language, language_confidence, script, normalized_value, sentiment_score, has_profanity, created_by_key, fuzzy_key_length
 FROM texts 
 ORDER BY created_at DESC
 `
@@ -111,6 +131,14 @@ func (q *Queries) GetAllTexts(ctx context.Context) ([]Text, error) {
 			&i.WordCount,
 			&i.Sha256Hash,
 			&i.CreatedAt,
+			&i.Language,
+			&i.LanguageConfidence,
+			&i.Script,
+			&i.NormalizedValue,
+			&i.SentimentScore,
+			&i.HasProfanity,
+			&i.CreatedByKey,
+			&i.FuzzyKeyLength,
 		); err != nil {
 			return nil, err
 		}
@@ -127,8 +155,8 @@ func (q *Queries) GetAllTexts(ctx context.Context) ([]Text, error) {
 
 const getCharacterCountsByID = `-- name: GetCharacterCountsByID :many
 SELECT character, unique_char_count
-FROM texts
-JOIN character_count ON $1 = character_count.string_id
+FROM character_count
+WHERE string_id = $1
 ORDER BY character
 `
 
@@ -160,41 +188,107 @@ func (q *Queries) GetCharacterCountsByID(ctx context.Context, stringID uuid.UUID
 	return items, nil
 }
 
-const getFilteredTexts = `-- name: GetFilteredTexts :many
-SELECT DISTINCT
-    t.id,
-    t.value,
-    t.length,
-    t.is_palindrome,
-    t.word_count,
-    t.sha256_hash,
-    t.created_at
-FROM texts t
-WHERE 
-    t.is_palindrome = $1 AND
-    t.length >= $2 AND
-    t.length <= $3 AND
-    t.word_count = $4 AND
-    t.value LIKE '%' || $5 || '%'
-ORDER BY t.created_at DESC
+const getText = `-- name: GetText :one
+SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at, language, language_confidence, script, normalized_value, sentiment_score, has_profanity, created_by_key, fuzzy_key_length
+FROM texts WHERE value = $1
 `
 
-type GetFilteredTextsParams struct {
-	IsPalindrome      bool
-	MinLength         int32
-	MaxLength         int32
-	WordCount         int32
-	ContainsCharacter sql.NullString
+func (q *Queries) GetText(ctx context.Context, value string) (Text, error) {
+	row := q.db.QueryRowContext(ctx, getText, value)
+	var i Text
+	err := row.Scan(
+		&i.ID,
+		&i.Value,
+		&i.Length,
+		&i.IsPalindrome,
+		&i.WordCount,
+		&i.Sha256Hash,
+		&i.CreatedAt,
+		&i.Language,
+		&i.LanguageConfidence,
+		&i.Script,
+		&i.NormalizedValue,
+		&i.SentimentScore,
+		&i.HasProfanity,
+		&i.CreatedByKey,
+		&i.FuzzyKeyLength,
+	)
+	return i, err
 }
 
-func (q *Queries) GetFilteredTexts(ctx context.Context, arg GetFilteredTextsParams) ([]Text, error) {
-	rows, err := q.db.QueryContext(ctx, getFilteredTexts,
-		arg.IsPalindrome,
-		arg.MinLength,
-		arg.MaxLength,
-		arg.WordCount,
-		arg.ContainsCharacter,
+const getTextByID = `-- name: GetTextByID :one
+SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at, language, language_confidence, script, normalized_value, sentiment_score, has_profanity, created_by_key, fuzzy_key_length
+FROM texts WHERE id = $1
+`
+
+func (q *Queries) GetTextByID(ctx context.Context, id uuid.UUID) (Text, error) {
+	row := q.db.QueryRowContext(ctx, getTextByID, id)
+	var i Text
+	err := row.Scan(
+		&i.ID,
+		&i.Value,
+		&i.Length,
+		&i.IsPalindrome,
+		&i.WordCount,
+		&i.Sha256Hash,
+		&i.CreatedAt,
+		&i.Language,
+		&i.LanguageConfidence,
+		&i.Script,
+		&i.NormalizedValue,
+		&i.SentimentScore,
+		&i.HasProfanity,
+		&i.CreatedByKey,
+		&i.FuzzyKeyLength,
 	)
+	return i, err
+}
+
+const getTextByNormalizedValue = `-- name: GetTextByNormalizedValue :one
+SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at, language, language_confidence, script, normalized_value, sentiment_score, has_profanity, created_by_key, fuzzy_key_length
+FROM texts WHERE normalized_value = $1
+ORDER BY created_at
+LIMIT 1
+`
+
+func (q *Queries) GetTextByNormalizedValue(ctx context.Context, normalizedValue string) (Text, error) {
+	row := q.db.QueryRowContext(ctx, getTextByNormalizedValue, normalizedValue)
+	var i Text
+	err := row.Scan(
+		&i.ID,
+		&i.Value,
+		&i.Length,
+		&i.IsPalindrome,
+		&i.WordCount,
+		&i.Sha256Hash,
+		&i.CreatedAt,
+		&i.Language,
+		&i.LanguageConfidence,
+		&i.Script,
+		&i.NormalizedValue,
+		&i.SentimentScore,
+		&i.HasProfanity,
+		&i.CreatedByKey,
+		&i.FuzzyKeyLength,
+	)
+	return i, err
+}
+
+const getTextsAfterID = `-- name: GetTextsAfterID :many
+SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at, language, language_confidence, script, normalized_value, sentiment_score, has_profanity, created_by_key, fuzzy_key_length
+FROM texts
+WHERE id > $1
+ORDER BY id
+LIMIT $2
+`
+
+type GetTextsAfterIDParams struct {
+	ID    uuid.UUID
+	Limit int32
+}
+
+func (q *Queries) GetTextsAfterID(ctx context.Context, arg GetTextsAfterIDParams) ([]Text, error) {
+	rows, err := q.db.QueryContext(ctx, getTextsAfterID, arg.ID, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -210,6 +304,14 @@ func (q *Queries) GetFilteredTexts(ctx context.Context, arg GetFilteredTextsPara
 			&i.WordCount,
 			&i.Sha256Hash,
 			&i.CreatedAt,
+			&i.Language,
+			&i.LanguageConfidence,
+			&i.Script,
+			&i.NormalizedValue,
+			&i.SentimentScore,
+			&i.HasProfanity,
+			&i.CreatedByKey,
+			&i.FuzzyKeyLength,
 		); err != nil {
 			return nil, err
 		}
@@ -224,42 +326,87 @@ func (q *Queries) GetFilteredTexts(ctx context.Context, arg GetFilteredTextsPara
 	return items, nil
 }
 
-const getText = `-- name: GetText :one
-SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at 
-FROM texts WHERE value = $1
+const getTextsByFuzzyKeyLength = `-- name: GetTextsByFuzzyKeyLength :many
+SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at, language, language_confidence, script, normalized_value, sentiment_score, has_profanity, created_by_key, fuzzy_key_length
+FROM texts
+WHERE (fuzzy_key_length >= $1 AND fuzzy_key_length <= $2) OR fuzzy_key_length < 0
+ORDER BY created_at
 `
 
-func (q *Queries) GetText(ctx context.Context, value string) (Text, error) {
-	row := q.db.QueryRowContext(ctx, getText, value)
-	var i Text
-	err := row.Scan(
-		&i.ID,
-		&i.Value,
-		&i.Length,
-		&i.IsPalindrome,
-		&i.WordCount,
-		&i.Sha256Hash,
-		&i.CreatedAt,
-	)
-	return i, err
+type GetTextsByFuzzyKeyLengthParams struct {
+	MinLength int32
+	MaxLength int32
 }
 
-const getTextByID = `-- name: GetTextByID :one
-SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at 
-FROM texts WHERE id = $1
+func (q *Queries) GetTextsByFuzzyKeyLength(ctx context.Context, arg GetTextsByFuzzyKeyLengthParams) ([]Text, error) {
+	rows, err := q.db.QueryContext(ctx, getTextsByFuzzyKeyLength, arg.MinLength, arg.MaxLength)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Text
+	for rows.Next() {
+		var i Text
+		if err := rows.Scan(
+			&i.ID,
+			&i.Value,
+			&i.Length,
+			&i.IsPalindrome,
+			&i.WordCount,
+			&i.Sha256Hash,
+			&i.CreatedAt,
+			&i.Language,
+			&i.LanguageConfidence,
+			&i.Script,
+			&i.NormalizedValue,
+			&i.SentimentScore,
+			&i.HasProfanity,
+			&i.CreatedByKey,
+			&i.FuzzyKeyLength,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateTextAnalysis = `-- name: UpdateTextAnalysis :exec
+UPDATE texts
+SET language = $2,
+    language_confidence = $3,
+    script = $4,
+    sentiment_score = $5,
+    has_profanity = $6,
+    fuzzy_key_length = $7
+WHERE id = $1
 `
 
-func (q *Queries) GetTextByID(ctx context.Context, id uuid.UUID) (Text, error) {
-	row := q.db.QueryRowContext(ctx, getTextByID, id)
-	var i Text
-	err := row.Scan(
-		&i.ID,
-		&i.Value,
-		&i.Length,
-		&i.IsPalindrome,
-		&i.WordCount,
-		&i.Sha256Hash,
-		&i.CreatedAt,
+type UpdateTextAnalysisParams struct {
+	ID                 uuid.UUID
+	Language           string
+	LanguageConfidence float64
+	Script             string
+	SentimentScore     float64
+	HasProfanity       bool
+	FuzzyKeyLength     int32
+}
+
+func (q *Queries) UpdateTextAnalysis(ctx context.Context, arg UpdateTextAnalysisParams) error {
+	_, err := q.db.ExecContext(ctx, updateTextAnalysis,
+		arg.ID,
+		arg.Language,
+		arg.LanguageConfidence,
+		arg.Script,
+		arg.SentimentScore,
+		arg.HasProfanity,
+		arg.FuzzyKeyLength,
 	)
-	return i, err
+	return err
 }
@@ -0,0 +1,147 @@
+package filter
+
+import (
+	"fmt"
+)
+
+// Conflict is a set of and-ed conditions that can never all hold at once
+type Conflict struct {
+	Field      string `json:"field"`
+	Message    string `json:"message"`
+	Conditions []Node `json:"conditions"`
+}
+
+// Conflicts finds contradictory conditions that are and-ed together anywhere in the tree,
+// such as a minimum length above the maximum length or two different languages
+func Conflicts(node Node) []Conflict {
+	var conflicts []Conflict
+	var walk func(Node)
+	walk = func(n Node) {
+		switch {
+		case n.And != nil:
+			conflicts = append(conflicts, groupConflicts(n.And)...)
+			for _, child := range n.And {
+				walk(child)
+			}
+		case n.Or != nil:
+			for _, child := range n.Or {
+				walk(child)
+			}
+		case n.Not != nil:
+			walk(*n.Not)
+		}
+	}
+	walk(node)
+	return conflicts
+}
+
+// conditionKey identifies conditions that constrain the same quantity
+type conditionKey struct {
+	field     string
+	character string
+	index     int
+}
+
+func (k conditionKey) String() string {
+	switch k.field {
+	case FieldCharacterCount:
+		return fmt.Sprintf("%s of %q", k.field, k.character)
+	case FieldCharAt:
+		return fmt.Sprintf("%s index %d", k.field, k.index)
+	}
+	return k.field
+}
+
+// bound is the tightest lower or upper limit seen so far and the condition that set it
+type bound struct {
+	set    bool
+	value  float64
+	strict bool
+	node   Node
+}
+
+func groupConflicts(nodes []Node) []Conflict {
+	var keys []conditionKey
+	groups := map[conditionKey][]Node{}
+	for _, node := range nodes {
+		if !node.IsCondition() {
+			continue
+		}
+		key := conditionKey{field: node.Field, character: node.Character, index: -1}
+		if node.Index != nil {
+			key.index = *node.Index
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], node)
+	}
+
+	var conflicts []Conflict
+	for _, key := range keys {
+		if conflict, ok := findConflict(key, groups[key]); ok {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	return conflicts
+}
+
+// findConflict reports the first contradiction between conditions on a single quantity
+func findConflict(key conditionKey, nodes []Node) (Conflict, bool) {
+	conflict := func(message string, conditions ...Node) (Conflict, bool) {
+		return Conflict{Field: key.field, Message: key.String() + " " + message, Conditions: conditions}, true
+	}
+
+	var equal *Node
+	var lower, upper bound
+	for i := range nodes {
+		node := nodes[i]
+		switch node.Op {
+		case OpEq:
+			if equal != nil && !sameValue(equal.Value, node.Value) {
+				return conflict(fmt.Sprintf("cannot equal both %v and %v", equal.Value, node.Value), *equal, node)
+			}
+			equal = &node
+		case OpGt, OpGte:
+			value, ok := toFloat(node.Value)
+			strict := node.Op == OpGt
+			if ok && (!lower.set || value > lower.value || value == lower.value && strict) {
+				lower = bound{set: true, value: value, strict: strict, node: node}
+			}
+		case OpLt, OpLte:
+			value, ok := toFloat(node.Value)
+			strict := node.Op == OpLt
+			if ok && (!upper.set || value < upper.value || value == upper.value && strict) {
+				upper = bound{set: true, value: value, strict: strict, node: node}
+			}
+		}
+	}
+
+	for _, node := range nodes {
+		if node.Op == OpNeq && equal != nil && sameValue(equal.Value, node.Value) {
+			return conflict(fmt.Sprintf("cannot both equal and not equal %v", node.Value), *equal, node)
+		}
+	}
+	if lower.set && upper.set && (lower.value > upper.value || lower.value == upper.value && (lower.strict || upper.strict)) {
+		return conflict(fmt.Sprintf("cannot be %s %v and %s %v", lower.node.Op, lower.node.Value, upper.node.Op, upper.node.Value), lower.node, upper.node)
+	}
+	if equal != nil {
+		value, ok := toFloat(equal.Value)
+		if ok && lower.set && (value < lower.value || value == lower.value && lower.strict) {
+			return conflict(fmt.Sprintf("cannot equal %v and be %s %v", equal.Value, lower.node.Op, lower.node.Value), *equal, lower.node)
+		}
+		if ok && upper.set && (value > upper.value || value == upper.value && upper.strict) {
+			return conflict(fmt.Sprintf("cannot equal %v and be %s %v", equal.Value, upper.node.Op, upper.node.Value), *equal, upper.node)
+		}
+	}
+	return Conflict{}, false
+}
+
+// sameValue compares condition values, treating numbers of different Go types as equal
+func sameValue(a, b any) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return a == b
+}
@@ -0,0 +1,403 @@
+// Package filter defines the boolean filter tree shared by the REST filters and the
+// natural-language parser, and compiles it into a parameterized SQL condition.
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Fields that can appear in a condition
+const (
+	FieldLength         = "length"
+	FieldWordCount      = "word_count"
+	FieldIsPalindrome   = "is_palindrome"
+	FieldValue          = "value"
+	FieldLanguage       = "language"
+	FieldSentimentScore = "sentiment_score"
+	FieldHasProfanity   = "has_profanity"
+	FieldCreatedAt      = "created_at"
+	// FieldCharacterCount compares how often Character occurs in the value
+	FieldCharacterCount = "character_count"
+	// FieldCharAt compares the character found at the zero-based Index of the value
+	FieldCharAt = "char_at"
+)
+
+// Operators that can appear in a condition
+const (
+	OpEq         = "="
+	OpNeq        = "!="
+	OpLt         = "<"
+	OpLte        = "<="
+	OpGt         = ">"
+	OpGte        = ">="
+	OpContains   = "contains"
+	OpStartsWith = "starts_with"
+	OpEndsWith   = "ends_with"
+)
+
+type fieldKind int
+
+const (
+	kindInt fieldKind = iota
+	kindFloat
+	kindBool
+	kindString
+	kindTime
+)
+
+type fieldSpec struct {
+	column string
+	kind   fieldKind
+	ops    []string
+}
+
+var comparisonOps = []string{OpEq, OpNeq, OpLt, OpLte, OpGt, OpGte}
+
+var fields = map[string]fieldSpec{
+	FieldLength:         {column: "t.length", kind: kindInt, ops: comparisonOps},
+	FieldWordCount:      {column: "t.word_count", kind: kindInt, ops: comparisonOps},
+	FieldIsPalindrome:   {column: "t.is_palindrome", kind: kindBool, ops: []string{OpEq, OpNeq}},
+	FieldValue:          {column: "t.value", kind: kindString, ops: []string{OpEq, OpNeq, OpContains, OpStartsWith, OpEndsWith}},
+	FieldLanguage:       {column: "t.language", kind: kindString, ops: []string{OpEq, OpNeq}},
+	FieldSentimentScore: {column: "t.sentiment_score", kind: kindFloat, ops: comparisonOps},
+	FieldHasProfanity:   {column: "t.has_profanity", kind: kindBool, ops: []string{OpEq, OpNeq}},
+	FieldCreatedAt:      {column: "t.created_at", kind: kindTime, ops: comparisonOps},
+	FieldCharacterCount: {kind: kindInt, ops: comparisonOps},
+	FieldCharAt:         {kind: kindString, ops: []string{OpEq, OpNeq}},
+}
+
+// Node is either a boolean combination (And, Or, Not) or a single condition (Field, Op, Value).
+// Character and Index qualify the character_count and char_at fields
+type Node struct {
+	And       []Node
+	Or        []Node
+	Not       *Node
+	Field     string
+	Op        string
+	Value     any
+	Character string
+	Index     *int
+}
+
+// Cond builds a single condition node
+func Cond(field, op string, value any) Node {
+	return Node{Field: field, Op: op, Value: value}
+}
+
+// And combines nodes so that all of them must match, flattening nested ands
+func And(nodes ...Node) Node {
+	var children []Node
+	for _, node := range nodes {
+		if node.And != nil {
+			children = append(children, node.And...)
+		} else {
+			children = append(children, node)
+		}
+	}
+	if len(children) == 1 {
+		return children[0]
+	}
+	return Node{And: children}
+}
+
+// Or combines nodes so that any of them may match, flattening nested ors
+func Or(nodes ...Node) Node {
+	var children []Node
+	for _, node := range nodes {
+		if node.Or != nil {
+			children = append(children, node.Or...)
+		} else {
+			children = append(children, node)
+		}
+	}
+	if len(children) == 1 {
+		return children[0]
+	}
+	return Node{Or: children}
+}
+
+// Not negates a node, folding the negation into simple conditions where possible
+func Not(node Node) Node {
+	if node.Not != nil {
+		return *node.Not
+	}
+	if node.IsCondition() {
+		if boolean, ok := node.Value.(bool); ok && node.Op == OpEq {
+			node.Value = !boolean
+			return node
+		}
+		if inverse, ok := inverseOps[node.Op]; ok {
+			node.Op = inverse
+			return node
+		}
+	}
+	return Node{Not: &node}
+}
+
+var inverseOps = map[string]string{
+	OpEq:  OpNeq,
+	OpNeq: OpEq,
+	OpLt:  OpGte,
+	OpLte: OpGt,
+	OpGt:  OpLte,
+	OpGte: OpLt,
+}
+
+// IsCondition reports whether the node is a leaf condition
+func (n Node) IsCondition() bool {
+	return n.Field != ""
+}
+
+// IsEmpty reports whether the node matches everything
+func (n Node) IsEmpty() bool {
+	return n.And == nil && n.Or == nil && n.Not == nil && n.Field == ""
+}
+
+// Conditions returns the leaf conditions of an and-only tree, or ok=false if it uses or/not
+func (n Node) Conditions() ([]Node, bool) {
+	switch {
+	case n.IsEmpty():
+		return nil, true
+	case n.IsCondition():
+		return []Node{n}, true
+	case n.And != nil:
+		var conditions []Node
+		for _, child := range n.And {
+			childConditions, ok := child.Conditions()
+			if !ok {
+				return nil, false
+			}
+			conditions = append(conditions, childConditions...)
+		}
+		return conditions, true
+	}
+	return nil, false
+}
+
+func (n Node) MarshalJSON() ([]byte, error) {
+	switch {
+	case n.And != nil:
+		return json.Marshal(map[string][]Node{"and": n.And})
+	case n.Or != nil:
+		return json.Marshal(map[string][]Node{"or": n.Or})
+	case n.Not != nil:
+		return json.Marshal(map[string]*Node{"not": n.Not})
+	case n.IsCondition():
+		return json.Marshal(struct {
+			Field     string `json:"field"`
+			Character string `json:"character,omitempty"`
+			Index     *int   `json:"index,omitempty"`
+			Op        string `json:"op"`
+			Value     any    `json:"value"`
+		}{n.Field, n.Character, n.Index, n.Op, n.Value})
+	}
+	return []byte("{}"), nil
+}
+
+func (n *Node) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		And       []Node `json:"and"`
+		Or        []Node `json:"or"`
+		Not       *Node  `json:"not"`
+		Field     string `json:"field"`
+		Op        string `json:"op"`
+		Value     any    `json:"value"`
+		Character string `json:"character"`
+		Index     *int   `json:"index"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&raw); err != nil {
+		return err
+	}
+	*n = Node{
+		And:       raw.And,
+		Or:        raw.Or,
+		Not:       raw.Not,
+		Field:     raw.Field,
+		Op:        raw.Op,
+		Value:     raw.Value,
+		Character: raw.Character,
+		Index:     raw.Index,
+	}
+	return nil
+}
+
+// Compile turns the tree into a SQL boolean expression over the texts table aliased as "t".
+// Placeholders are numbered from $1 and the matching arguments are returned in order
+func Compile(node Node) (string, []any, error) {
+	c := compiler{}
+	where, err := c.compile(node)
+	if err != nil {
+		return "", nil, err
+	}
+	return where, c.args, nil
+}
+
+type compiler struct {
+	args []any
+}
+
+func (c *compiler) placeholder(value any) string {
+	c.args = append(c.args, value)
+	return fmt.Sprintf("$%d", len(c.args))
+}
+
+func (c *compiler) compile(node Node) (string, error) {
+	switch {
+	case node.IsEmpty():
+		return "TRUE", nil
+	case node.And != nil:
+		return c.compileList(node.And, " AND ")
+	case node.Or != nil:
+		return c.compileList(node.Or, " OR ")
+	case node.Not != nil:
+		inner, err := c.compile(*node.Not)
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + inner + ")", nil
+	}
+	return c.compileCondition(node)
+}
+
+func (c *compiler) compileList(nodes []Node, separator string) (string, error) {
+	if len(nodes) == 0 {
+		return "", errors.New("and/or must contain at least one condition")
+	}
+	parts := make([]string, len(nodes))
+	for i, node := range nodes {
+		part, err := c.compile(node)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = part
+	}
+	return "(" + strings.Join(parts, separator) + ")", nil
+}
+
+func (c *compiler) compileCondition(node Node) (string, error) {
+	spec, ok := fields[node.Field]
+	if !ok {
+		return "", fmt.Errorf("unknown field %q", node.Field)
+	}
+	if !containsOp(spec.ops, node.Op) {
+		return "", fmt.Errorf("operator %q is not supported for field %q", node.Op, node.Field)
+	}
+	value, err := coerce(spec.kind, node.Value)
+	if err != nil {
+		return "", fmt.Errorf("invalid value for field %q: %w", node.Field, err)
+	}
+
+	op := node.Op
+	if op == OpNeq {
+		op = "<>"
+	}
+
+	switch node.Field {
+	case FieldCharacterCount:
+		if utf8.RuneCountInString(node.Character) != 1 {
+			return "", errors.New(`field "character_count" needs a single "character"`)
+		}
+		count := fmt.Sprintf(
+			"(SELECT COALESCE(SUM(cc.unique_char_count), 0) FROM character_count cc WHERE cc.string_id = t.id AND cc.character = %s)",
+			c.placeholder(node.Character),
+		)
+		return fmt.Sprintf("%s %s %s", count, op, c.placeholder(value)), nil
+
+	case FieldCharAt:
+		if node.Index == nil || *node.Index < 0 || *node.Index >= math.MaxInt32 {
+			return "", errors.New(`field "char_at" needs a non-negative "index"`)
+		}
+		if utf8.RuneCountInString(value.(string)) != 1 {
+			return "", errors.New(`field "char_at" must be compared with a single character`)
+		}
+		return fmt.Sprintf("substr(t.value, %s, 1) %s %s", c.placeholder(*node.Index+1), op, c.placeholder(value)), nil
+	}
+
+	switch node.Op {
+	case OpContains:
+		return fmt.Sprintf("strpos(%s, %s) > 0", spec.column, c.placeholder(value)), nil
+	case OpStartsWith:
+		return fmt.Sprintf("starts_with(%s, %s)", spec.column, c.placeholder(value)), nil
+	case OpEndsWith:
+		suffix := c.placeholder(value)
+		return fmt.Sprintf("right(%s, char_length(%s::text)) = %s", spec.column, suffix, suffix), nil
+	}
+	return fmt.Sprintf("%s %s %s", spec.column, op, c.placeholder(value)), nil
+}
+
+func containsOp(ops []string, op string) bool {
+	for _, candidate := range ops {
+		if candidate == op {
+			return true
+		}
+	}
+	return false
+}
+
+// coerce converts a value from the parser or from decoded JSON into the Go type of the field
+func coerce(kind fieldKind, value any) (any, error) {
+	switch kind {
+	case kindInt:
+		number, ok := toFloat(value)
+		if !ok || number != math.Trunc(number) {
+			return nil, errors.New("must be an integer")
+		}
+		// the integer columns are 32-bit, a larger value would wrap around
+		if number < math.MinInt32 || number > math.MaxInt32 {
+			return nil, fmt.Errorf("must be between %d and %d", math.MinInt32, math.MaxInt32)
+		}
+		return int32(number), nil
+	case kindFloat:
+		number, ok := toFloat(value)
+		if !ok {
+			return nil, errors.New("must be a number")
+		}
+		return number, nil
+	case kindBool:
+		boolean, ok := value.(bool)
+		if !ok {
+			return nil, errors.New("must be true or false")
+		}
+		return boolean, nil
+	case kindTime:
+		str, _ := value.(string)
+		for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+			if parsed, err := time.Parse(layout, str); err == nil {
+				return parsed.UTC(), nil
+			}
+		}
+		return nil, errors.New("must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+	default:
+		str, ok := value.(string)
+		if !ok || str == "" {
+			return nil, errors.New("must be a non-empty string")
+		}
+		return str, nil
+	}
+}
+
+func toFloat(value any) (float64, bool) {
+	switch number := value.(type) {
+	case int:
+		return float64(number), true
+	case int32:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case float64:
+		return number, true
+	case json.Number:
+		parsed, err := number.Float64()
+		return parsed, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,212 @@
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func index(i int) *int {
+	return &i
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name      string
+		node      Node
+		wantWhere string
+		wantArgs  []any
+	}{
+		{
+			name:      "empty tree matches everything",
+			node:      Node{},
+			wantWhere: "TRUE",
+		},
+		{
+			name:      "comparison",
+			node:      Cond(FieldLength, OpGt, 10),
+			wantWhere: "t.length > $1",
+			wantArgs:  []any{int32(10)},
+		},
+		{
+			name:      "not equal",
+			node:      Cond(FieldLanguage, OpNeq, "en"),
+			wantWhere: "t.language <> $1",
+			wantArgs:  []any{"en"},
+		},
+		{
+			name:      "float from JSON",
+			node:      Cond(FieldSentimentScore, OpGte, json.Number("0.5")),
+			wantWhere: "t.sentiment_score >= $1",
+			wantArgs:  []any{0.5},
+		},
+		{
+			name:      "not over a boolean flips the value",
+			node:      And(Cond(FieldIsPalindrome, OpEq, true), Or(Cond(FieldWordCount, OpEq, 1), Not(Cond(FieldHasProfanity, OpEq, true)))),
+			wantWhere: "(t.is_palindrome = $1 AND (t.word_count = $2 OR t.has_profanity = $3))",
+			wantArgs:  []any{true, int32(1), false},
+		},
+		{
+			name:      "contains",
+			node:      Cond(FieldValue, OpContains, "ab"),
+			wantWhere: "strpos(t.value, $1) > 0",
+			wantArgs:  []any{"ab"},
+		},
+		{
+			name:      "starts with",
+			node:      Cond(FieldValue, OpStartsWith, "a"),
+			wantWhere: "starts_with(t.value, $1)",
+			wantArgs:  []any{"a"},
+		},
+		{
+			name:      "ends with reuses its placeholder",
+			node:      Cond(FieldValue, OpEndsWith, "z"),
+			wantWhere: "right(t.value, char_length($1::text)) = $1",
+			wantArgs:  []any{"z"},
+		},
+		{
+			name:      "character count",
+			node:      Node{Field: FieldCharacterCount, Op: OpGte, Value: 2, Character: "a"},
+			wantWhere: "(SELECT COALESCE(SUM(cc.unique_char_count), 0) FROM character_count cc WHERE cc.string_id = t.id AND cc.character = $1) >= $2",
+			wantArgs:  []any{"a", int32(2)},
+		},
+		{
+			name:      "char at is one-based in SQL",
+			node:      Node{Field: FieldCharAt, Op: OpEq, Value: "x", Index: index(0)},
+			wantWhere: "substr(t.value, $1, 1) = $2",
+			wantArgs:  []any{1, "x"},
+		},
+		{
+			name:      "date",
+			node:      Cond(FieldCreatedAt, OpLt, "2024-01-02"),
+			wantWhere: "t.created_at < $1",
+			wantArgs:  []any{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args, err := Compile(tt.node)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			if where != tt.wantWhere {
+				t.Errorf("where = %s, want %s", where, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCompileRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		node    Node
+		wantErr string
+	}{
+		{"unknown field", Cond("colour", OpEq, "red"), `unknown field "colour"`},
+		{"unsupported operator", Cond(FieldIsPalindrome, OpGt, true), `operator ">" is not supported for field "is_palindrome"`},
+		{"fractional integer", Cond(FieldLength, OpEq, 1.5), "must be an integer"},
+		{"integer above 32 bits", Cond(FieldLength, OpGt, 3000000000), "must be between -2147483648 and 2147483647"},
+		{"integer below 32 bits", Cond(FieldWordCount, OpLt, -3000000000), "must be between -2147483648 and 2147483647"},
+		{"string for a number", Cond(FieldLength, OpEq, "ten"), "must be an integer"},
+		{"empty string", Cond(FieldValue, OpContains, ""), "must be a non-empty string"},
+		{"bad date", Cond(FieldCreatedAt, OpGt, "yesterday"), "must be an RFC 3339 timestamp"},
+		{"character count without a character", Node{Field: FieldCharacterCount, Op: OpEq, Value: 1}, `needs a single "character"`},
+		{"char at without an index", Node{Field: FieldCharAt, Op: OpEq, Value: "a"}, `needs a non-negative "index"`},
+		{"char at with a negative index", Node{Field: FieldCharAt, Op: OpEq, Value: "a", Index: index(-1)}, `needs a non-negative "index"`},
+		{"char at past 32 bits", Node{Field: FieldCharAt, Op: OpEq, Value: "a", Index: index(math.MaxInt32)}, `needs a non-negative "index"`},
+		{"char at with several characters", Node{Field: FieldCharAt, Op: OpEq, Value: "ab", Index: index(0)}, "single character"},
+		{"empty and", Node{And: []Node{}}, "at least one condition"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Compile(tt.node)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	deep := Cond(FieldLength, OpGt, 1)
+	for range maxDepth + 1 {
+		deep = Node{Not: &deep}
+	}
+	many := make([]Node, maxConditions+1)
+	for i := range many {
+		many[i] = Cond(FieldLength, OpGt, i)
+	}
+
+	tests := []struct {
+		name     string
+		query    Query
+		wantPath string
+	}{
+		{"valid", Query{Where: And(Cond(FieldLength, OpGt, 1), Cond(FieldWordCount, OpLte, 2147483647)), Limit: 10}, ""},
+		{"empty", Query{}, ""},
+		{"integer out of range", Query{Where: And(Cond(FieldLength, OpGt, 1), Cond(FieldLength, OpLt, 1e10))}, "filter.and[1].value"},
+		{"char at index out of range", Query{Where: Node{Field: FieldCharAt, Op: OpEq, Value: "a", Index: index(math.MaxInt32)}}, "filter.index"},
+		{"character on another field", Query{Where: Node{Field: FieldLength, Op: OpEq, Value: 1, Character: "a"}}, "filter.character"},
+		{"index on another field", Query{Where: Node{Field: FieldLength, Op: OpEq, Value: 1, Index: index(1)}}, "filter.index"},
+		{"two kinds in one node", Query{Where: Node{Field: FieldLength, Op: OpEq, Value: 1, Not: &Node{}}}, "filter"},
+		{"too deep", Query{Where: deep}, "filter.not.not.not.not.not.not.not.not.not.not.not.not.not.not.not.not.not"},
+		{"too many conditions", Query{Where: Node{Or: many}}, "filter.or[100]"},
+		{"unknown sort field", Query{Sort: []Sort{{Field: "colour", Direction: Asc}}}, "sort[0].field"},
+		{"bad sort direction", Query{Sort: []Sort{{Field: FieldLength, Direction: "up"}}}, "sort[0].direction"},
+		{"limit too large", Query{Limit: maxLimit + 1}, "limit"},
+		{"negative limit", Query{Limit: -1}, "limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.query.Validate()
+			if tt.wantPath == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("error = %v, want a ValidationError", err)
+			}
+			if validationErr.Path != tt.wantPath {
+				t.Errorf("path = %s, want %s (%v)", validationErr.Path, tt.wantPath, err)
+			}
+		})
+	}
+}
+
+func TestNodeJSONRoundTrip(t *testing.T) {
+	node := And(
+		Cond(FieldLength, OpGt, json.Number("10")),
+		Not(Node{Field: FieldCharAt, Op: OpEq, Value: "a", Index: index(2)}),
+		Or(Cond(FieldLanguage, OpEq, "en"), Cond(FieldLanguage, OpEq, "fr")),
+	)
+	encoded, err := json.Marshal(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Node
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("decoding %s: %v", encoded, err)
+	}
+
+	want, wantArgs, err := Compile(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, gotArgs, err := Compile(decoded)
+	if err != nil {
+		t.Fatalf("compiling the decoded tree: %v", err)
+	}
+	if got != want || !reflect.DeepEqual(gotArgs, wantArgs) {
+		t.Errorf("decoded tree compiles to %s %v, want %s %v", got, gotArgs, want, wantArgs)
+	}
+}
@@ -0,0 +1,202 @@
+package filter
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// fieldID is the final tie-breaker of every ordering, it can't be sorted on explicitly
+const fieldID = "id"
+
+// Sort directions
+const (
+	Asc  = "asc"
+	Desc = "desc"
+)
+
+var sortColumns = map[string]string{
+	FieldLength:         "t.length",
+	FieldWordCount:      "t.word_count",
+	FieldValue:          "t.value",
+	FieldSentimentScore: "t.sentiment_score",
+	FieldCreatedAt:      "t.created_at",
+}
+
+// Sort orders results by a single field
+type Sort struct {
+	Field     string `json:"field"`
+	Direction string `json:"direction"`
+}
+
+// Query is a filter tree together with how its results are ordered and limited
+type Query struct {
+	Where Node   `json:"filter"`
+	Sort  []Sort `json:"sort,omitempty"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+// Keys returns the full ordering of the query: its sorts, then newest first, then by id,
+// so that every row has a unique position a cursor can point at
+func (q Query) Keys() []Sort {
+	keys := append([]Sort{}, q.Sort...)
+	hasCreatedAt := false
+	for _, key := range keys {
+		hasCreatedAt = hasCreatedAt || key.Field == FieldCreatedAt
+	}
+	if !hasCreatedAt {
+		keys = append(keys, Sort{Field: FieldCreatedAt, Direction: Desc})
+	}
+	return append(keys, Sort{Field: fieldID, Direction: Desc})
+}
+
+func sortColumn(field string) (string, bool) {
+	if field == fieldID {
+		return "t.id", true
+	}
+	column, ok := sortColumns[field]
+	return column, ok
+}
+
+// CompileOrder turns the query's keys into an ORDER BY list
+func CompileOrder(q Query) (string, error) {
+	var parts []string
+	for _, key := range q.Keys() {
+		column, ok := sortColumn(key.Field)
+		if !ok {
+			return "", fmt.Errorf("cannot sort by field %q", key.Field)
+		}
+		switch key.Direction {
+		case Asc:
+			parts = append(parts, column+" ASC")
+		case Desc:
+			parts = append(parts, column+" DESC")
+		default:
+			return "", fmt.Errorf("sort direction must be %q or %q", Asc, Desc)
+		}
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+// Cursor is the position of the last row of a page: its values for each of the query's keys,
+// and how many rows have been returned so far so a query limit carries across pages.
+// Query is the signature of the query the cursor was made for
+type Cursor struct {
+	Query  string `json:"q"`
+	Values []any  `json:"v"`
+	Seen   int    `json:"n"`
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// NewCursor records the position after a row whose key values are given in the order of q.Keys()
+func NewCursor(q Query, values []any, seen int) Cursor {
+	return Cursor{Query: querySignature(q), Values: values, Seen: seen}
+}
+
+// querySignature is a digest of the filter, ordering and limit of q, so a cursor can't be replayed
+// against a different query, where its position and count of rows seen would mean something else
+func querySignature(q Query) string {
+	data, _ := json.Marshal(q)
+	digest := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(digest[:12])
+}
+
+// Encode returns the cursor as an opaque URL-safe token
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token from Encode, checking it was made for q and that each value has the type
+// of its key. The values are converted to the Go types of their columns
+func DecodeCursor(q Query, token string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	keys := q.Keys()
+	if cursor.Query != querySignature(q) || len(cursor.Values) != len(keys) || cursor.Seen < 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	for i, key := range keys {
+		value, err := cursorValue(key.Field, cursor.Values[i])
+		if err != nil {
+			return Cursor{}, fmt.Errorf("%w: %s %v", ErrInvalidCursor, key.Field, err)
+		}
+		cursor.Values[i] = value
+	}
+	return cursor, nil
+}
+
+// cursorValue checks a cursor value decoded from JSON against the type of its sort key
+func cursorValue(field string, value any) (any, error) {
+	switch field {
+	case fieldID:
+		str, _ := value.(string)
+		id, err := uuid.Parse(str)
+		if err != nil {
+			return nil, errors.New("must be a UUID")
+		}
+		return id.String(), nil
+	case FieldValue:
+		// unlike a filter value, a stored text can be matched by any string
+		str, ok := value.(string)
+		if !ok {
+			return nil, errors.New("must be a string")
+		}
+		return str, nil
+	}
+	spec, ok := fields[field]
+	if !ok {
+		return nil, errors.New("is not a sort key")
+	}
+	return coerce(spec.kind, value)
+}
+
+// CompileAfter is Compile for the query's filter restricted to rows after the cursor,
+// expanded as (k1 after v1) OR (k1 = v1 AND k2 after v2) OR ...
+func CompileAfter(q Query, cursor *Cursor) (string, []any, error) {
+	c := compiler{}
+	where, err := c.compile(q.Where)
+	if err != nil {
+		return "", nil, err
+	}
+	if cursor == nil {
+		return where, c.args, nil
+	}
+
+	keys := q.Keys()
+	placeholders := make([]string, len(keys))
+	for i, value := range cursor.Values {
+		placeholders[i] = c.placeholder(value)
+	}
+	var alternatives []string
+	for i, key := range keys {
+		var terms []string
+		for j := 0; j < i; j++ {
+			column, _ := sortColumn(keys[j].Field)
+			terms = append(terms, fmt.Sprintf("%s = %s", column, placeholders[j]))
+		}
+		column, ok := sortColumn(key.Field)
+		if !ok {
+			return "", nil, fmt.Errorf("cannot sort by field %q", key.Field)
+		}
+		op := ">"
+		if key.Direction == Desc {
+			op = "<"
+		}
+		terms = append(terms, fmt.Sprintf("%s %s %s", column, op, placeholders[i]))
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+	return fmt.Sprintf("%s AND (%s)", where, strings.Join(alternatives, " OR ")), c.args, nil
+}
@@ -0,0 +1,124 @@
+package filter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const cursorID = "0b9c8f5e-6f0a-4c1e-9a57-2f6f3f8f3a10"
+
+// encodeRaw builds a token around values a client could have put in an edited cursor
+func encodeRaw(t *testing.T, q Query, values []any, seen int) string {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"q": querySignature(q), "v": values, "n": seen})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	q := Query{Where: Cond(FieldIsPalindrome, OpEq, true), Sort: []Sort{{Field: FieldLength, Direction: Desc}, {Field: FieldSentimentScore, Direction: Asc}}}
+	created := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+	token := NewCursor(q, []any{int32(7), 0.25, created.Format(time.RFC3339Nano), cursorID}, 20).Encode()
+
+	cursor, err := DecodeCursor(q, token)
+	if err != nil {
+		t.Fatalf("DecodeCursor: %v", err)
+	}
+	// values come back in the Go types of their columns
+	want := []any{int32(7), 0.25, created, cursorID}
+	if !reflect.DeepEqual(cursor.Values, want) || cursor.Seen != 20 {
+		t.Errorf("cursor = %#v, want values %#v and 20 seen", cursor, want)
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	q := Query{Where: Cond(FieldLength, OpGt, 3), Sort: []Sort{{Field: FieldLength, Direction: Asc}, {Field: FieldValue, Direction: Asc}}}
+	created := "2024-01-02T03:04:05Z"
+	valid := []any{5, "abc", created, cursorID}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"not base64", "not a cursor!"},
+		{"not JSON", base64.RawURLEncoding.EncodeToString([]byte("{"))},
+		{"another filter", NewCursor(Query{Where: Cond(FieldLength, OpGt, 4), Sort: q.Sort}, valid, 1).Encode()},
+		{"another ordering", NewCursor(Query{Where: q.Where, Sort: q.Sort[:1]}, valid[1:], 1).Encode()},
+		{"another limit", NewCursor(Query{Where: q.Where, Sort: q.Sort, Limit: 10}, valid, 1).Encode()},
+		{"missing value", encodeRaw(t, q, valid[:3], 1)},
+		{"negative rows seen", encodeRaw(t, q, valid, -1)},
+		{"object for a length", encodeRaw(t, q, []any{map[string]any{"a": 1}, "abc", created, cursorID}, 1)},
+		{"array for a value", encodeRaw(t, q, []any{5, []any{"abc"}, created, cursorID}, 1)},
+		{"fractional length", encodeRaw(t, q, []any{5.5, "abc", created, cursorID}, 1)},
+		{"length beyond 32 bits", encodeRaw(t, q, []any{1e10, "abc", created, cursorID}, 1)},
+		{"string for a length", encodeRaw(t, q, []any{"5", "abc", created, cursorID}, 1)},
+		{"number for a value", encodeRaw(t, q, []any{5, 5, created, cursorID}, 1)},
+		{"bad timestamp", encodeRaw(t, q, []any{5, "abc", "yesterday", cursorID}, 1)},
+		{"bad id", encodeRaw(t, q, []any{5, "abc", created, "1; DROP TABLE texts"}, 1)},
+		{"null id", encodeRaw(t, q, []any{5, "abc", created, nil}, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(q, tt.token); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+
+	// an empty string is a valid position for the value key
+	if _, err := DecodeCursor(q, encodeRaw(t, q, []any{5, "", created, cursorID}, 1)); err != nil {
+		t.Errorf("empty value: %v", err)
+	}
+}
+
+func TestCompileAfter(t *testing.T) {
+	q := Query{Where: Cond(FieldIsPalindrome, OpEq, true), Sort: []Sort{{Field: FieldLength, Direction: Asc}}}
+
+	where, args, err := CompileAfter(q, nil)
+	if err != nil || where != "t.is_palindrome = $1" || !reflect.DeepEqual(args, []any{true}) {
+		t.Errorf("without a cursor: %s %v %v, want the plain filter", where, args, err)
+	}
+
+	cursor, err := DecodeCursor(q, NewCursor(q, []any{int32(5), "2024-01-02T03:04:05Z", cursorID}, 2).Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	where, args, err = CompileAfter(q, &cursor)
+	if err != nil {
+		t.Fatalf("CompileAfter: %v", err)
+	}
+	wantWhere := "t.is_palindrome = $1 AND ((t.length > $2) OR (t.length = $2 AND t.created_at < $3) OR (t.length = $2 AND t.created_at = $3 AND t.id < $4))"
+	wantArgs := []any{true, int32(5), time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), cursorID}
+	if where != wantWhere {
+		t.Errorf("where = %s, want %s", where, wantWhere)
+	}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %#v, want %#v", args, wantArgs)
+	}
+}
+
+func TestCompileOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		query Query
+		want  string
+	}{
+		{"newest first by default", Query{}, "t.created_at DESC, t.id DESC"},
+		{"sorts before the tie-breakers", Query{Sort: []Sort{{Field: FieldValue, Direction: Asc}}}, "t.value ASC, t.created_at DESC, t.id DESC"},
+		{"explicit created_at", Query{Sort: []Sort{{Field: FieldCreatedAt, Direction: Asc}}}, "t.created_at ASC, t.id DESC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CompileOrder(tt.query)
+			if err != nil || got != tt.want {
+				t.Errorf("CompileOrder = %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
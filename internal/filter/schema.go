@@ -0,0 +1,232 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"unicode/utf8"
+)
+
+// limits on trees submitted by clients, so a single request can't build an enormous query
+const (
+	maxDepth      = 16
+	maxConditions = 100
+	maxLimit      = 10000
+)
+
+// ValidationError reports where in a submitted query a problem was found, as a path like "filter.and[1].value"
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Validate checks a query decoded from JSON against the filter schema
+func (q Query) Validate() error {
+	if err := Validate(q.Where); err != nil {
+		return err
+	}
+	for i, sort := range q.Sort {
+		path := fmt.Sprintf("sort[%d]", i)
+		if _, ok := sortColumns[sort.Field]; !ok {
+			return &ValidationError{path + ".field", fmt.Sprintf("cannot sort by field %q", sort.Field)}
+		}
+		if sort.Direction != Asc && sort.Direction != Desc {
+			return &ValidationError{path + ".direction", fmt.Sprintf("must be %q or %q", Asc, Desc)}
+		}
+	}
+	if q.Limit < 0 || q.Limit > maxLimit {
+		return &ValidationError{"limit", fmt.Sprintf("must be between 0 and %d", maxLimit)}
+	}
+	return nil
+}
+
+// Validate checks a single tree, the root may be empty to match everything
+func Validate(node Node) error {
+	if node.IsEmpty() {
+		return nil
+	}
+	conditions := 0
+	return validateNode(node, "filter", 0, &conditions)
+}
+
+func validateNode(node Node, path string, depth int, conditions *int) error {
+	if depth > maxDepth {
+		return &ValidationError{path, fmt.Sprintf("nested more than %d levels deep", maxDepth)}
+	}
+
+	kinds := 0
+	for _, set := range []bool{node.And != nil, node.Or != nil, node.Not != nil, node.Field != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return &ValidationError{path, `must have exactly one of "and", "or", "not" or "field"`}
+	}
+
+	switch {
+	case node.And != nil:
+		return validateList(node.And, path+".and", depth, conditions)
+	case node.Or != nil:
+		return validateList(node.Or, path+".or", depth, conditions)
+	case node.Not != nil:
+		return validateNode(*node.Not, path+".not", depth+1, conditions)
+	}
+
+	*conditions++
+	if *conditions > maxConditions {
+		return &ValidationError{path, fmt.Sprintf("more than %d conditions", maxConditions)}
+	}
+	spec, ok := fields[node.Field]
+	if !ok {
+		return &ValidationError{path + ".field", fmt.Sprintf("unknown field %q", node.Field)}
+	}
+	if !containsOp(spec.ops, node.Op) {
+		return &ValidationError{path + ".op", fmt.Sprintf("operator %q is not supported for field %q", node.Op, node.Field)}
+	}
+	value, err := coerce(spec.kind, node.Value)
+	if err != nil {
+		return &ValidationError{path + ".value", err.Error()}
+	}
+
+	if node.Field == FieldCharacterCount {
+		if utf8.RuneCountInString(node.Character) != 1 {
+			return &ValidationError{path + ".character", "must be a single character"}
+		}
+	} else if node.Character != "" {
+		return &ValidationError{path + ".character", fmt.Sprintf("only applies to field %q", FieldCharacterCount)}
+	}
+	if node.Field == FieldCharAt {
+		if node.Index == nil || *node.Index < 0 || *node.Index >= math.MaxInt32 {
+			return &ValidationError{path + ".index", fmt.Sprintf("must be an integer between 0 and %d", math.MaxInt32-1)}
+		}
+		if utf8.RuneCountInString(value.(string)) != 1 {
+			return &ValidationError{path + ".value", "must be a single character"}
+		}
+	} else if node.Index != nil {
+		return &ValidationError{path + ".index", fmt.Sprintf("only applies to field %q", FieldCharAt)}
+	}
+	return nil
+}
+
+func validateList(nodes []Node, path string, depth int, conditions *int) error {
+	if len(nodes) == 0 {
+		return &ValidationError{path, "must contain at least one condition"}
+	}
+	for i, child := range nodes {
+		if err := validateNode(child, fmt.Sprintf("%s[%d]", path, i), depth+1, conditions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Schema describes the JSON search body as a JSON Schema document, built from the same field table
+// the compiler uses so the two can't drift apart
+func Schema() map[string]any {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nodes := []any{
+		map[string]any{"$ref": "#/$defs/and"},
+		map[string]any{"$ref": "#/$defs/or"},
+		map[string]any{"$ref": "#/$defs/not"},
+	}
+	for _, name := range names {
+		spec := fields[name]
+		properties := map[string]any{
+			"field": map[string]any{"const": name},
+			"op":    map[string]any{"enum": spec.ops},
+			"value": valueSchema(spec.kind),
+		}
+		required := []string{"field", "op", "value"}
+		switch name {
+		case FieldCharacterCount:
+			properties["character"] = map[string]any{"type": "string", "minLength": 1, "maxLength": 1}
+			required = append(required, "character")
+		case FieldCharAt:
+			properties["index"] = map[string]any{"type": "integer", "minimum": 0}
+			required = append(required, "index")
+		}
+		nodes = append(nodes, map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		})
+	}
+
+	list := func(key string) map[string]any {
+		return map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				key: map[string]any{"type": "array", "minItems": 1, "items": map[string]any{"$ref": "#/$defs/node"}},
+			},
+			"required":             []string{key},
+			"additionalProperties": false,
+		}
+	}
+	sortFields := make([]string, 0, len(sortColumns))
+	for name := range sortColumns {
+		sortFields = append(sortFields, name)
+	}
+	sort.Strings(sortFields)
+
+	return map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "String search",
+		"type":    "object",
+		"properties": map[string]any{
+			"filter": map[string]any{"$ref": "#/$defs/node"},
+			"sort": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"field":     map[string]any{"enum": sortFields},
+						"direction": map[string]any{"enum": []string{Asc, Desc}},
+					},
+					"required":             []string{"field", "direction"},
+					"additionalProperties": false,
+				},
+			},
+			"limit": map[string]any{"type": "integer", "minimum": 0, "maximum": maxLimit},
+		},
+		"additionalProperties": false,
+		"$defs": map[string]any{
+			"node": map[string]any{"oneOf": nodes},
+			"and":  list("and"),
+			"or":   list("or"),
+			"not": map[string]any{
+				"type":                 "object",
+				"properties":           map[string]any{"not": map[string]any{"$ref": "#/$defs/node"}},
+				"required":             []string{"not"},
+				"additionalProperties": false,
+			},
+		},
+	}
+}
+
+func valueSchema(kind fieldKind) map[string]any {
+	switch kind {
+	case kindInt:
+		return map[string]any{"type": "integer"}
+	case kindFloat:
+		return map[string]any{"type": "number"}
+	case kindBool:
+		return map[string]any{"type": "boolean"}
+	case kindTime:
+		return map[string]any{"type": "string", "anyOf": []any{
+			map[string]any{"format": "date-time"},
+			map[string]any{"format": "date"},
+		}}
+	}
+	return map[string]any{"type": "string", "minLength": 1}
+}
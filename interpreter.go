@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/filter"
+)
+
+// QueryInterpreter turns a natural-language query in the lexicon's locale into a filter tree
+// with its sort order and limit. Explain reports how it reads a query, with an error when Interpret would fail
+type QueryInterpreter interface {
+	Interpret(query string, lex *nlLexicon) (filter.Query, error)
+	Explain(query string, lex *nlLexicon) (ExplainResponse, error)
+}
+
+// grammarInterpreter is the built-in recursive-descent parser in nlquery.go
+type grammarInterpreter struct{}
+
+func (grammarInterpreter) Interpret(query string, lex *nlLexicon) (filter.Query, error) {
+	parsed, err := parseNaturalLanguageQuery(query, lex)
+	if err != nil {
+		return filter.Query{}, err
+	}
+	// the grammar reads any number, the schema catches the ones no column can hold
+	if err := parsed.Validate(); err != nil {
+		return filter.Query{}, err
+	}
+	return parsed, nil
+}
+
+func (grammarInterpreter) Explain(query string, lex *nlLexicon) (ExplainResponse, error) {
+	return explainNaturalLanguageQuery(query, lex)
+}
+
+// newQueryInterpreter picks the interpreter named by NL_INTERPRETER, "grammar" by default
+func newQueryInterpreter(name, rulesFile string) (QueryInterpreter, error) {
+	switch name {
+	case "", "grammar":
+		return grammarInterpreter{}, nil
+	case "rules":
+		if rulesFile == "" {
+			return nil, errors.New("NL_RULES_FILE must be set for the rules interpreter")
+		}
+		return newRuleInterpreter(rulesFile, grammarInterpreter{})
+	}
+	return nil, fmt.Errorf("unknown interpreter %q: must be grammar or rules", name)
+}
+
+// nlRuleFile is the JSON document analysts edit. Each rule maps a regular expression over the whole
+// query to a search, and captures can be used in string values as $1 or ${name}:
+//
+//	{"rules": [{"name": "longer-than", "pattern": "strings over (?P<n>\\w+) long",
+//	            "filter": {"field": "length", "op": ">", "value": "${n}"}}]}
+type nlRuleFile struct {
+	Rules []nlRule `json:"rules"`
+}
+
+type nlRule struct {
+	Name    string `json:"name"`
+	Locale  string `json:"locale,omitempty"`
+	Pattern string `json:"pattern"`
+	filter.Query
+
+	pattern *regexp.Regexp
+}
+
+// ruleInterpreter answers queries from a rule file, handing anything no rule matches to the
+// fallback. The file is reloaded when it changes, keeping the last good rules if the new ones are invalid
+type ruleInterpreter struct {
+	path     string
+	fallback QueryInterpreter
+
+	mu      sync.Mutex
+	rules   []nlRule
+	modTime time.Time
+	size    int64
+}
+
+func newRuleInterpreter(path string, fallback QueryInterpreter) (*ruleInterpreter, error) {
+	interpreter := &ruleInterpreter{path: path, fallback: fallback}
+	if err := interpreter.reload(); err != nil {
+		return nil, err
+	}
+	return interpreter, nil
+}
+
+func (ri *ruleInterpreter) Interpret(query string, lex *nlLexicon) (filter.Query, error) {
+	_, parsed, ok, err := ri.match(query, lex)
+	if !ok {
+		return ri.fallback.Interpret(query, lex)
+	}
+	return parsed, err
+}
+
+// Explain names the matching rule and reports the whole query as the span it read
+func (ri *ruleInterpreter) Explain(query string, lex *nlLexicon) (ExplainResponse, error) {
+	rule, parsed, ok, err := ri.match(query, lex)
+	if !ok {
+		return ri.fallback.Explain(query, lex)
+	}
+	trimmed := strings.TrimSpace(query)
+	start := strings.Index(query, trimmed)
+	explanation := ExplainResponse{
+		Original:       query,
+		Locale:         lex.locale,
+		Tokens:         []ExplainToken{},
+		Spans:          []ExplainSpan{},
+		Ignored:        []string{},
+		Interpretation: parsed,
+		Alternatives:   []ExplainAlternative{},
+		Rule:           rule.Name,
+	}
+	if err != nil {
+		return explanation, err
+	}
+	span := ExplainSpan{Text: trimmed, Start: start, End: start + len(trimmed), Sort: parsed.Sort, Limit: parsed.Limit}
+	if !parsed.Where.IsEmpty() {
+		span.Filter = &parsed.Where
+	}
+	explanation.Spans = append(explanation.Spans, span)
+	explanation.Conflicts = filter.Conflicts(parsed.Where)
+	return explanation, nil
+}
+
+// match finds the first rule for the query's locale whose pattern matches it and fills in its captures,
+// ok is false when no rule matches
+func (ri *ruleInterpreter) match(query string, lex *nlLexicon) (nlRule, filter.Query, bool, error) {
+	query = strings.TrimSpace(query)
+	for _, rule := range ri.currentRules() {
+		if rule.Locale != "" && rule.Locale != lex.locale {
+			continue
+		}
+		match := rule.pattern.FindStringSubmatchIndex(query)
+		if match == nil {
+			continue
+		}
+		parsed := rule.Query
+		parsed.Where = substituteCaptures(rule.Query.Where, func(field, template string) any {
+			return expandCapture(rule.pattern, query, field, template, match, lex)
+		})
+		if err := parsed.Validate(); err != nil {
+			return rule, filter.Query{}, true, fmt.Errorf("rule %q produced an invalid filter: %v", rule.Name, err)
+		}
+		return rule, parsed, true, nil
+	}
+	return nlRule{}, filter.Query{}, false, nil
+}
+
+// currentRules reloads the file first if it was modified since it was last read
+func (ri *ruleInterpreter) currentRules() []nlRule {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+	info, err := os.Stat(ri.path)
+	if err == nil && (!info.ModTime().Equal(ri.modTime) || info.Size() != ri.size) {
+		if err := ri.load(info); err != nil {
+			slog.Error("error reloading rule file, keeping the previous rules", "path", ri.path, "err", err)
+			ri.modTime, ri.size = info.ModTime(), info.Size()
+		}
+	}
+	return ri.rules
+}
+
+func (ri *ruleInterpreter) reload() error {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+	info, err := os.Stat(ri.path)
+	if err != nil {
+		return err
+	}
+	return ri.load(info)
+}
+
+func (ri *ruleInterpreter) load(info os.FileInfo) error {
+	data, err := os.ReadFile(ri.path)
+	if err != nil {
+		return err
+	}
+	rules, err := parseRuleFile(data)
+	if err != nil {
+		return err
+	}
+	ri.rules, ri.modTime, ri.size = rules, info.ModTime(), info.Size()
+	return nil
+}
+
+// parseRuleFile decodes and checks every rule, patterns match the whole query and ignore case
+func parseRuleFile(data []byte) ([]nlRule, error) {
+	var file nlRuleFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid rule file: %v", err)
+	}
+	for i := range file.Rules {
+		rule := &file.Rules[i]
+		if rule.Name == "" {
+			rule.Name = strconv.Itoa(i)
+		}
+		if rule.Locale != "" {
+			if _, ok := nlLexicons[rule.Locale]; !ok {
+				return nil, fmt.Errorf("rule %q: unsupported locale %q", rule.Name, rule.Locale)
+			}
+		}
+		pattern, err := regexp.Compile("(?i)^(?:" + rule.Pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: invalid pattern: %v", rule.Name, err)
+		}
+		rule.pattern = pattern
+		if rule.Where.IsEmpty() && len(rule.Sort) == 0 && rule.Limit == 0 {
+			return nil, fmt.Errorf("rule %q: needs a filter, sort or limit", rule.Name)
+		}
+		// templates are only checked once their captures are filled in
+		if !hasCaptures(rule.Where) {
+			if err := rule.Query.Validate(); err != nil {
+				return nil, fmt.Errorf("rule %q: %v", rule.Name, err)
+			}
+		}
+	}
+	return file.Rules, nil
+}
+
+var capturePlaceholder = regexp.MustCompile(`\$(\d+|\{\w+\})`)
+
+// textFields keep captures as strings even when they look like numbers
+var textFields = map[string]bool{
+	filter.FieldValue:     true,
+	filter.FieldLanguage:  true,
+	filter.FieldCharAt:    true,
+	filter.FieldCreatedAt: true,
+}
+
+func hasCaptures(node filter.Node) bool {
+	found := false
+	substituteCaptures(node, func(field, template string) any {
+		found = true
+		return template
+	})
+	return found
+}
+
+// substituteCaptures replaces every string value and character holding a placeholder with expand's result
+func substituteCaptures(node filter.Node, expand func(field, template string) any) filter.Node {
+	if !node.IsCondition() {
+		return mapChildren(node, func(child filter.Node) filter.Node {
+			return substituteCaptures(child, expand)
+		})
+	}
+	if template, ok := node.Value.(string); ok && capturePlaceholder.MatchString(template) {
+		node.Value = expand(node.Field, template)
+	}
+	if capturePlaceholder.MatchString(node.Character) {
+		node.Character = fmt.Sprint(expand(filter.FieldValue, node.Character))
+	}
+	return node
+}
+
+// expandCapture fills in a template. For numeric fields a template that is just one placeholder becomes
+// a number, written in the query as digits or as words of its locale
+func expandCapture(pattern *regexp.Regexp, query, field, template string, match []int, lex *nlLexicon) any {
+	expanded := string(pattern.ExpandString(nil, template, query, match))
+	if capturePlaceholder.FindString(template) != template || textFields[field] {
+		return expanded
+	}
+	if number, err := strconv.ParseFloat(expanded, 64); err == nil {
+		return number
+	}
+	p := newNLParser(expanded, lex)
+	if number, ok := p.parseNumber(); ok && p.atEnd() {
+		return number
+	}
+	return expanded
+}
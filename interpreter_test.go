@@ -0,0 +1,178 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/filter"
+)
+
+const testRules = `{"rules": [
+	{"name": "tweet-sized", "pattern": "tweets?", "filter": {"field": "length", "op": "<=", "value": 280}},
+	{"name": "longer-than", "pattern": "(strings )?over (?P<n>\\w+) long", "filter": {"field": "length", "op": ">", "value": "${n}"}},
+	{"name": "with-letter", "pattern": "words with (\\w)", "filter": {"and": [
+		{"field": "word_count", "op": "=", "value": 1},
+		{"field": "value", "op": "contains", "value": "$1"}
+	]}},
+	{"name": "in-language", "pattern": "strings in (?P<lang>[a-z]{2})", "filter": {"field": "language", "op": "=", "value": "${lang}"}},
+	{"name": "nuevas", "locale": "es", "pattern": "m[aá]s nuevas", "sort": [{"field": "created_at", "direction": "desc"}], "limit": 10},
+	{"name": "shadowed", "pattern": "tweets", "filter": {"field": "length", "op": ">", "value": 1000}}
+]}`
+
+// writeRules writes a rules file with a modification time of its own, so every write is seen as a change
+func writeRules(t *testing.T, path, rules string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestRuleInterpreter(t *testing.T, rules string) (*ruleInterpreter, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.json")
+	writeRules(t, path, rules, time.Now().Add(-time.Hour))
+	interpreter, err := newRuleInterpreter(path, grammarInterpreter{})
+	if err != nil {
+		t.Fatalf("newRuleInterpreter: %v", err)
+	}
+	return interpreter, path
+}
+
+func TestRuleInterpreter(t *testing.T) {
+	interpreter, _ := newTestRuleInterpreter(t, testRules)
+
+	tests := []struct {
+		name   string
+		query  string
+		locale string
+		want   filter.Query
+	}{
+		{"first matching rule wins", "Tweets", "en", filter.Query{Where: filter.Cond(filter.FieldLength, filter.OpLte, 280.0)}},
+		{"surrounding spaces are ignored", "  tweet ", "en", filter.Query{Where: filter.Cond(filter.FieldLength, filter.OpLte, 280.0)}},
+		{"named capture as digits", "strings over 12 long", "en", filter.Query{Where: filter.Cond(filter.FieldLength, filter.OpGt, 12.0)}},
+		{"named capture as words of the locale", "over ten long", "en", filter.Query{Where: filter.Cond(filter.FieldLength, filter.OpGt, 10)}},
+		{"numbered capture in a nested condition", "words with z", "en", filter.Query{Where: filter.And(
+			filter.Cond(filter.FieldWordCount, filter.OpEq, 1.0),
+			filter.Cond(filter.FieldValue, filter.OpContains, "z"),
+		)}},
+		{"text fields keep captures as strings", "strings in fr", "en", filter.Query{Where: filter.Cond(filter.FieldLanguage, filter.OpEq, "fr")}},
+		{"rule of the query's locale", "más nuevas", "es", filter.Query{Sort: []filter.Sort{{Field: filter.FieldCreatedAt, Direction: filter.Desc}}, Limit: 10}},
+		{"other locales fall back to the grammar", "palindromes", "en", filter.Query{Where: filter.Cond(filter.FieldIsPalindrome, filter.OpEq, true)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := interpreter.Interpret(tt.query, nlLexicons[tt.locale])
+			if err != nil {
+				t.Fatalf("Interpret(%q): %v", tt.query, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Interpret(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	// a rule limited to spanish doesn't answer english queries
+	if _, err := interpreter.Interpret("mas nuevas", nlLexicons["en"]); err == nil {
+		t.Error("the spanish rule answered an english query")
+	}
+	// captures that don't fit the field fail like any invalid filter
+	if _, err := interpreter.Interpret("over lots long", nlLexicons["en"]); err == nil || !strings.Contains(err.Error(), `rule "longer-than"`) {
+		t.Errorf("error = %v, want the rule that produced an invalid filter", err)
+	}
+}
+
+func TestRuleInterpreterReloadsChangedFiles(t *testing.T) {
+	interpreter, path := newTestRuleInterpreter(t, testRules)
+	lex := nlLexicons["en"]
+
+	writeRules(t, path, `{"rules": [{"name": "tweets", "pattern": "tweets?", "filter": {"field": "length", "op": "<=", "value": 140}}]}`, time.Now())
+	got, err := interpreter.Interpret("tweets", lex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filter.Cond(filter.FieldLength, filter.OpLte, 140.0); !reflect.DeepEqual(got.Where, want) {
+		t.Errorf("after an edit: %+v, want %+v", got.Where, want)
+	}
+	// rules that were removed no longer match, the query goes to the grammar
+	if explanation, err := interpreter.Explain("words with z", lex); err == nil && explanation.Rule != "" {
+		t.Errorf("rule %q still matched after it was removed", explanation.Rule)
+	}
+}
+
+func TestRuleInterpreterKeepsRulesWhenTheFileBecomesInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+	}{
+		{"not JSON", `{"rules": [`},
+		{"unknown member", `{"rules": [{"name": "x", "pattern": "x", "colour": "red"}]}`},
+		{"bad pattern", `{"rules": [{"name": "x", "pattern": "(", "limit": 1}]}`},
+		{"rule without a result", `{"rules": [{"name": "x", "pattern": "x"}]}`},
+		{"unsupported locale", `{"rules": [{"name": "x", "locale": "de", "pattern": "x", "limit": 1}]}`},
+		{"invalid filter", `{"rules": [{"name": "x", "pattern": "x", "filter": {"field": "colour", "op": "=", "value": "red"}}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseRuleFile([]byte(tt.rules)); err == nil {
+				t.Fatal("parseRuleFile accepted the file")
+			}
+
+			interpreter, path := newTestRuleInterpreter(t, testRules)
+			writeRules(t, path, tt.rules, time.Now())
+			got, err := interpreter.Interpret("tweets", nlLexicons["en"])
+			if err != nil {
+				t.Fatalf("Interpret: %v", err)
+			}
+			if want := filter.Cond(filter.FieldLength, filter.OpLte, 280.0); !reflect.DeepEqual(got.Where, want) {
+				t.Errorf("after an invalid edit: %+v, want the previous rules' %+v", got.Where, want)
+			}
+			// the bad file isn't read again until it changes, and a fix is picked up
+			if stamp := interpreter.modTime; !stamp.Equal(mustStat(t, path).ModTime()) {
+				t.Errorf("modification time = %v, want the invalid file's", stamp)
+			}
+			writeRules(t, path, `{"rules": [{"name": "fixed", "pattern": "tweets", "limit": 3}]}`, time.Now().Add(time.Minute))
+			if got, err := interpreter.Interpret("tweets", nlLexicons["en"]); err != nil || got.Limit != 3 {
+				t.Errorf("after a fix: %+v, %v, want the fixed rule", got, err)
+			}
+		})
+	}
+}
+
+func mustStat(t *testing.T, path string) os.FileInfo {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestNewQueryInterpreter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	writeRules(t, path, testRules, time.Now())
+
+	if interpreter, err := newQueryInterpreter("", ""); err != nil || interpreter != (grammarInterpreter{}) {
+		t.Errorf("default = %v, %v, want the grammar", interpreter, err)
+	}
+	if interpreter, err := newQueryInterpreter("rules", path); err != nil {
+		t.Errorf("rules: %v", err)
+	} else if _, ok := interpreter.(*ruleInterpreter); !ok {
+		t.Errorf("rules = %T, want a rule interpreter", interpreter)
+	}
+	for _, tt := range []struct{ name, rulesFile string }{
+		{"rules", ""},
+		{"rules", filepath.Join(t.TempDir(), "missing.json")},
+		{"neural", ""},
+	} {
+		if _, err := newQueryInterpreter(tt.name, tt.rulesFile); err == nil {
+			t.Errorf("newQueryInterpreter(%q, %q) succeeded", tt.name, tt.rulesFile)
+		}
+	}
+}
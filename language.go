@@ -0,0 +1,266 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// language samples are embedded so detection works without any network or external model
+//
+//go:embed langprofiles/*.txt
+var languageSamples embed.FS
+
+const (
+	undeterminedLanguage = "und"
+	unknownScript        = "Unknown"
+)
+
+type languageProfile struct {
+	code     string
+	script   string
+	logProbs map[string]float64
+	unseen   float64
+}
+
+// sampleScripts records the writing system of each embedded sample
+var sampleScripts = map[string]string{
+	"en": "Latin",
+	"fr": "Latin",
+	"es": "Latin",
+	"de": "Latin",
+	"it": "Latin",
+	"pt": "Latin",
+	"nl": "Latin",
+	"ru": "Cyrillic",
+	"uk": "Cyrillic",
+}
+
+// scriptLanguages maps scripts that are (for our purposes) written by a single language
+var scriptLanguages = map[string]string{
+	"Arabic":     "ar",
+	"Greek":      "el",
+	"Hebrew":     "he",
+	"Hangul":     "ko",
+	"Thai":       "th",
+	"Devanagari": "hi",
+	"Han":        "zh",
+	"Hiragana":   "ja",
+	"Katakana":   "ja",
+}
+
+var detectableScripts = []struct {
+	name  string
+	table *unicode.RangeTable
+}{
+	{"Latin", unicode.Latin},
+	{"Cyrillic", unicode.Cyrillic},
+	{"Greek", unicode.Greek},
+	{"Arabic", unicode.Arabic},
+	{"Hebrew", unicode.Hebrew},
+	{"Han", unicode.Han},
+	{"Hiragana", unicode.Hiragana},
+	{"Katakana", unicode.Katakana},
+	{"Hangul", unicode.Hangul},
+	{"Devanagari", unicode.Devanagari},
+	{"Thai", unicode.Thai},
+}
+
+// languageNames maps the names users type in queries to the stored language codes
+var languageNames = map[string]string{
+	"english":    "en",
+	"french":     "fr",
+	"spanish":    "es",
+	"german":     "de",
+	"italian":    "it",
+	"portuguese": "pt",
+	"dutch":      "nl",
+	"russian":    "ru",
+	"ukrainian":  "uk",
+	"arabic":     "ar",
+	"greek":      "el",
+	"hebrew":     "he",
+	"korean":     "ko",
+	"thai":       "th",
+	"hindi":      "hi",
+	"chinese":    "zh",
+	"japanese":   "ja",
+}
+
+var languageProfiles = mustLoadLanguageProfiles(languageSamples)
+
+// mustLoadLanguageProfiles is like loadLanguageProfiles but panics on an error, the samples are
+// embedded at build time so a failure is a bug in the build rather than something to recover from
+func mustLoadLanguageProfiles(samples fs.FS) []languageProfile {
+	profiles, err := loadLanguageProfiles(samples)
+	if err != nil {
+		panic(fmt.Sprintf("loading language profiles: %v", err))
+	}
+	return profiles
+}
+
+// loadLanguageProfiles builds a trigram profile from every langprofiles/<code>.txt sample
+func loadLanguageProfiles(samples fs.FS) ([]languageProfile, error) {
+	entries, err := fs.ReadDir(samples, "langprofiles")
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []languageProfile
+	for _, entry := range entries {
+		sample, err := fs.ReadFile(samples, path.Join("langprofiles", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		code := strings.TrimSuffix(entry.Name(), ".txt")
+		script, ok := sampleScripts[code]
+		if !ok {
+			return nil, fmt.Errorf("no script recorded for the %q sample", code)
+		}
+
+		counts := make(map[string]int)
+		total := 0
+		for _, gram := range trigrams(string(sample)) {
+			counts[gram]++
+			total++
+		}
+
+		// add-one smoothing so unseen trigrams don't zero out a language
+		denominator := float64(total + len(counts) + 1)
+		logProbs := make(map[string]float64, len(counts))
+		for gram, count := range counts {
+			logProbs[gram] = math.Log(float64(count+1) / denominator)
+		}
+
+		profiles = append(profiles, languageProfile{
+			code:     code,
+			script:   script,
+			logProbs: logProbs,
+			unseen:   math.Log(1 / denominator),
+		})
+	}
+	return profiles, nil
+}
+
+// trigrams splits text into lower-cased letter trigrams, padding each word with spaces
+func trigrams(text string) []string {
+	var grams []string
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		runes := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			grams = append(grams, string(runes[i:i+3]))
+		}
+	}
+	return grams
+}
+
+// detectScript returns the dominant writing system of the text and the share of letters written in it
+func detectScript(text string) (string, float64) {
+	counts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, script := range detectableScripts {
+			if unicode.Is(script.table, r) {
+				counts[script.name]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return unknownScript, 0
+	}
+
+	dominant, best := unknownScript, 0
+	for _, script := range detectableScripts {
+		if counts[script.name] > best {
+			dominant, best = script.name, counts[script.name]
+		}
+	}
+	return dominant, float64(best) / float64(letters)
+}
+
+// detectLanguage returns an ISO 639-1 code, a confidence between 0 and 1 and the dominant script
+func detectLanguage(text string) (string, float64, string) {
+	script, share := detectScript(text)
+	if script == unknownScript {
+		return undeterminedLanguage, 0, script
+	}
+
+	// japanese mixes kana with han characters, so any kana at all decides it and
+	// the confidence covers every CJK letter rather than just the dominant script
+	if script == "Han" || script == "Hiragana" || script == "Katakana" {
+		letters, cjk, kana := 0, 0, 0
+		for _, r := range text {
+			if !unicode.IsLetter(r) {
+				continue
+			}
+			letters++
+			if unicode.In(r, unicode.Hiragana, unicode.Katakana) {
+				kana++
+				cjk++
+			} else if unicode.Is(unicode.Han, r) {
+				cjk++
+			}
+		}
+		if kana > 0 {
+			return "ja", roundConfidence(float64(cjk) / float64(letters)), script
+		}
+	}
+	if code, ok := scriptLanguages[script]; ok {
+		return code, roundConfidence(share), script
+	}
+
+	grams := trigrams(text)
+	if len(grams) == 0 {
+		return undeterminedLanguage, 0, script
+	}
+
+	var codes []string
+	var scores []float64
+	for _, profile := range languageProfiles {
+		if profile.script != script {
+			continue
+		}
+		score := 0.0
+		for _, gram := range grams {
+			if logProb, ok := profile.logProbs[gram]; ok {
+				score += logProb
+			} else {
+				score += profile.unseen
+			}
+		}
+		codes = append(codes, profile.code)
+		scores = append(scores, score)
+	}
+	if len(codes) == 0 {
+		return undeterminedLanguage, 0, script
+	}
+
+	// softmax over the log-likelihoods gives a probability for the best candidate
+	bestIndex := 0
+	for i, score := range scores {
+		if score > scores[bestIndex] {
+			bestIndex = i
+		}
+	}
+	sum := 0.0
+	for _, score := range scores {
+		sum += math.Exp(score - scores[bestIndex])
+	}
+	return codes[bestIndex], roundConfidence(share / sum), script
+}
+
+func roundConfidence(confidence float64) float64 {
+	return math.Round(confidence*1000) / 1000
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDetectScript(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		want      string
+		wantShare float64
+	}{
+		{"latin", "hello world", "Latin", 1},
+		{"cyrillic", "привет мир", "Cyrillic", 1},
+		{"greek", "γειά σου κόσμε", "Greek", 1},
+		{"arabic", "مرحبا بالعالم", "Arabic", 1},
+		{"hebrew", "שלום עולם", "Hebrew", 1},
+		{"han", "你好世界", "Han", 1},
+		{"hangul", "안녕하세요", "Hangul", 1},
+		{"devanagari", "नमस्ते दुनिया", "Devanagari", 1},
+		{"thai", "สวัสดีชาวโลก", "Thai", 1},
+		{"mixed scripts share the letters", "abc где", "Latin", 0.5},
+		{"no letters", "123 !?", unknownScript, 0},
+		{"empty", "", unknownScript, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, share := detectScript(tt.text)
+			if script != tt.want || share < tt.wantShare-0.01 || share > tt.wantShare+0.01 {
+				t.Errorf("detectScript(%q) = %s, %v, want %s, %v", tt.text, script, share, tt.want, tt.wantShare)
+			}
+		})
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		want       string
+		wantScript string
+	}{
+		{"english", "The weather was lovely this morning and we walked along the river with our friends", "en", "Latin"},
+		{"french", "Nous avons passé une très belle journée avec nos amis au bord de la rivière", "fr", "Latin"},
+		{"spanish", "Ayer fuimos al mercado con mis hermanos y compramos muchas frutas para la cena", "es", "Latin"},
+		{"german", "Gestern sind wir mit unseren Freunden durch den Wald gegangen und haben viel gelacht", "de", "Latin"},
+		{"italian", "Ieri siamo andati al mercato con i nostri amici e abbiamo comprato della frutta", "it", "Latin"},
+		{"portuguese", "Ontem fomos ao mercado com os nossos amigos e compramos muitas frutas para o jantar", "pt", "Latin"},
+		{"dutch", "Gisteren zijn we met onze vrienden naar de markt gegaan en hebben we veel fruit gekocht", "nl", "Latin"},
+		{"russian", "Вчера мы ходили на рынок с нашими друзьями и купили много фруктов", "ru", "Cyrillic"},
+		{"ukrainian", "Вчора ми ходили на ринок з нашими друзями і купили багато фруктів", "uk", "Cyrillic"},
+		{"script decides greek", "καλημέρα", "el", "Greek"},
+		{"script decides korean", "안녕하세요", "ko", "Hangul"},
+		{"han alone is chinese", "我们昨天去了市场", "zh", "Han"},
+		{"kana makes it japanese", "日本語学校に", "ja", "Han"},
+		{"hiragana", "きょうはいいてんきです", "ja", "Hiragana"},
+		{"katakana alone is japanese", "コンピューター", "ja", "Katakana"},
+		{"no letters", "12345", undeterminedLanguage, unknownScript},
+		{"empty", "", undeterminedLanguage, unknownScript},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, confidence, script := detectLanguage(tt.text)
+			if code != tt.want || script != tt.wantScript {
+				t.Errorf("detectLanguage(%q) = %s, %s, want %s, %s", tt.text, code, script, tt.want, tt.wantScript)
+			}
+			if confidence < 0 || confidence > 1 {
+				t.Errorf("confidence = %v, want it between 0 and 1", confidence)
+			}
+		})
+	}
+}
+
+// a word or two shared by several languages can't be told apart, the confidence has to say so
+func TestDetectLanguageAmbiguousInput(t *testing.T) {
+	_, short, _ := detectLanguage("a")
+	_, long, _ := detectLanguage("The weather was lovely this morning and we walked along the river with our friends")
+	if short >= long {
+		t.Errorf("confidence for a single letter = %v, want it below the %v of a full sentence", short, long)
+	}
+}
+
+func TestEveryEmbeddedSampleIsDetected(t *testing.T) {
+	if len(languageProfiles) != len(sampleScripts) {
+		t.Fatalf("%d profiles loaded, want one per sample script (%d)", len(languageProfiles), len(sampleScripts))
+	}
+	// each sample is the most likely language for its own text
+	for _, profile := range languageProfiles {
+		sample, err := languageSamples.ReadFile("langprofiles/" + profile.code + ".txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code, _, script := detectLanguage(string(sample)); code != profile.code || script != profile.script {
+			t.Errorf("the %s sample is detected as %s in %s", profile.code, code, script)
+		}
+	}
+}
+
+func TestLoadLanguageProfiles(t *testing.T) {
+	profiles, err := loadLanguageProfiles(fstest.MapFS{
+		"langprofiles/en.txt": {Data: []byte("the cat")},
+	})
+	if err != nil {
+		t.Fatalf("loadLanguageProfiles: %v", err)
+	}
+	if len(profiles) != 1 || profiles[0].code != "en" || profiles[0].script != "Latin" {
+		t.Errorf("profiles = %+v, want one Latin en profile", profiles)
+	}
+
+	tests := []struct {
+		name    string
+		samples fstest.MapFS
+		wantErr string
+	}{
+		{"no samples directory", fstest.MapFS{}, "langprofiles"},
+		{"sample without a script", fstest.MapFS{"langprofiles/xx.txt": {Data: []byte("text")}}, `"xx"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadLanguageProfiles(tt.samples)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want one mentioning %s", err, tt.wantErr)
+			}
+		})
+	}
+}
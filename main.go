@@ -1,14 +1,26 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
-	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"github.com/HamstimusPrime/text-analyzer-api/internal/config"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 //based on the schema of the database and this text, generate a query that best fulfills this request,
@@ -16,46 +28,203 @@ import (
 
 func main() {
 
-	err := godotenv.Load()
+	//a .env file is optional, its variables are read like any other environment variable
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "error loading .env file: %v\n", err)
+		os.Exit(1)
+	}
+
+	//load settings from defaults, the config file, the environment and flags
+	settings, options, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stdout)
+		return
+	}
+	//the config is printed before its errors, to show what the service would have run with
+	if options.PrintConfig {
+		settings.Print(os.Stdout)
+	}
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	if options.PrintConfig {
+		return
 	}
 
-	//setup Maps of accepted queries
-	filters := []string{"is_palindrome", "min_length", "max_length", "word_count", "contains_character"}
-	stringFilters := make(map[string]string)
-	for _, filter := range filters {
-		stringFilters[filter] = ""
+	//setup structured logger, every other log line goes through it
+	logger, err := newLogger(os.Stderr, settings.Logging.Format, settings.Logging.Level)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid logging configuration: %v\n", err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
+	if options.File != "" {
+		slog.Info("loaded config file", "path", options.File)
+	}
+
+	//setup profanity blocklist, optionally extended from a file
+	profanityBlocklist, err := loadBlocklist(settings.Analyzers.ProfanityBlocklist)
+	if err != nil {
+		slog.Error("unable to load profanity blocklist", "err", err)
+		os.Exit(1)
+	}
+
+	//setup natural-language interpreter, optionally driven by a rule file
+	interpreter, err := newQueryInterpreter(settings.NaturalLanguage.Interpreter, settings.NaturalLanguage.RulesFile)
+	if err != nil {
+		slog.Error("unable to setup natural-language interpreter", "err", err)
+		os.Exit(1)
 	}
 
 	//establish DB connection
-	dbURL := os.Getenv("DB_URL")
-	db, err := sql.Open("postgres", dbURL)
+	db, err := sql.Open("postgres", settings.Database.URL)
 	if err != nil {
-		log.Fatalf("unable to establish connection to database: %v", err)
+		slog.Error("unable to establish connection to database", "err", err)
+		os.Exit(1)
+	}
+	db.SetMaxOpenConns(settings.Database.MaxOpenConns)
+	db.SetMaxIdleConns(settings.Database.MaxIdleConns)
+	db.SetConnMaxLifetime(settings.Database.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(settings.Database.ConnMaxIdleTime)
+	// an unreachable database isn't fatal, /readyz reports it until the database comes up
+	pingCtx, cancelPing := context.WithTimeout(context.Background(), settings.Database.PingTimeout)
+	if err := db.PingContext(pingCtx); err != nil {
+		slog.Warn("database is not reachable yet", "err", err)
 	}
+	cancelPing()
 
-	//setup state for API
-	dbQueries := database.New(db)
+	//setup tracing, spans are exported as OTLP, printed to stdout or not recorded at all
+	tracer, err := newTracer(settings.Tracing.Exporter, settings.Tracing.OTLPEndpoint, settings.Tracing.ServiceName)
+	if err != nil {
+		slog.Error("unable to setup tracing", "err", err)
+		os.Exit(1)
+	}
+
+	//setup state for API, database queries are traced and measured for /metrics
+	metrics := newServiceMetrics(prometheus.DefaultRegisterer)
+	dbQueries := newQueries(db, metrics, tracer)
 	apiConfiguration := apiConfig{
-		DB:           dbQueries,
-		QueryFilters: stringFilters,
+		DB:                dbQueries,
+		QueryFilters:      queryFilters(settings.Analyzers),
+		HashAlgorithms:    selectHashAlgorithms(settings.Analyzers.HashAlgorithms),
+		Blocklist:         profanityBlocklist,
+		Analyzers:         settings.Analyzers,
+		Interpreter:       instrumentedInterpreter{interpreter: interpreter, metrics: metrics},
+		Metrics:           metrics,
+		Tracer:            tracer,
+		Conn:              db,
+		PingTimeout:       settings.Database.PingTimeout,
+		MaxBodyBytes:      settings.Limits.MaxBodyBytes,
+		AuthEnabled:       settings.Auth.Enabled,
+		RateLimiter:       newRateLimiter(settings.RateLimit),
+		TrustForwardedFor: settings.RateLimit.TrustForwardedFor,
+		DailyTextQuota:    settings.Quotas.DailyTexts,
+	}
+	if settings.Auth.AdminKey != "" {
+		apiConfiguration.AdminKeyHash = hashAPIKey(settings.Auth.AdminKey)
+	}
+	if !settings.Auth.Enabled {
+		slog.Warn("API key authentication is disabled, every endpoint is open")
 	}
 
-	//server setup
-	port := os.Getenv("PORT")
-	mux := http.NewServeMux()
-	mux.HandleFunc("GET /strings/{string_value}", apiConfiguration.GetText)
-	mux.HandleFunc("GET /strings", apiConfiguration.GetFilteredTexts)
-	mux.HandleFunc("GET /strings/filter-by-natural-language", apiConfiguration.GetTexByNaturalLang)
-	mux.HandleFunc("POST /strings", apiConfiguration.CreateText)
-	mux.HandleFunc("DELETE /strings/{string_value}", apiConfiguration.DeleteText)
+	//backfill texts stored before an analyzer was added or enabled, instead of serving
+	if options.Backfill {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		done, err := apiConfiguration.backfill(ctx)
+		stop()
+		flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+		tracer.shutdown(flushCtx)
+		cancelFlush()
+		db.Close()
+		if err != nil {
+			slog.Error("backfill stopped", "texts", done, "err", err)
+			os.Exit(1)
+		}
+		slog.Info("backfill finished", "texts", done)
+		return
+	}
+
+	//server setup, SIGINT or SIGTERM starts a graceful shutdown
+	port := strconv.Itoa(settings.Server.Port)
+	server := newServer(":"+port, newRouter(&apiConfiguration), settings.Server)
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		slog.Error("unable to listen", "addr", server.Addr, "err", err)
+		os.Exit(1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("server running", "port", port)
+	serveErr := serve(ctx, server, listener, settings.Server.ShutdownGracePeriod)
+	if serveErr != nil {
+		slog.Error("server stopped", "err", serveErr)
+	}
+
+	//release everything the drained requests were using
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := tracer.shutdown(flushCtx); err != nil {
+		slog.Error("error flushing spans", "err", err)
+	}
+	cancelFlush()
+	if err := db.Close(); err != nil {
+		slog.Error("error closing database", "err", err)
+	}
+	if serveErr != nil {
+		os.Exit(1)
+	}
+	slog.Info("server stopped")
+}
 
-	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+// queryFilters lists the GET /strings parameters, filters on the output of a disabled analyzer are rejected
+func queryFilters(analyzers config.Analyzers) map[string]string {
+	filters := []string{"is_palindrome", "min_length", "max_length", "word_count", "contains_character", "starts_with", "ends_with", "char_at", "page_size", "cursor"}
+	if analyzers.IsEnabled(config.AnalyzerLanguage) {
+		filters = append(filters, "language")
+	}
+	if analyzers.IsEnabled(config.AnalyzerSentiment) {
+		filters = append(filters, "sentiment_min", "sentiment_max")
+	}
+	if analyzers.IsEnabled(config.AnalyzerProfanity) {
+		filters = append(filters, "has_profanity")
 	}
 
-	log.Printf("server running on port: %v\n", port)
-	log.Fatal(server.ListenAndServe())
+	stringFilters := make(map[string]string)
+	for _, filter := range filters {
+		stringFilters[filter] = ""
+	}
+	return stringFilters
+}
+
+// newRouter registers every route and wraps them in the middleware shared by all requests
+func newRouter(cfg *apiConfig) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /strings/{string_value}", cfg.route(routeClassRead, scopeRead, cfg.GetText))
+	mux.Handle("GET /strings/{string_value}/ngrams", cfg.route(routeClassRead, scopeRead, cfg.GetTextNgrams))
+	mux.Handle("GET /strings", cfg.route(routeClassSearch, scopeRead, cfg.GetFilteredTexts))
+	mux.Handle("GET /ngrams/top", cfg.route(routeClassSearch, scopeRead, cfg.GetTopNgrams))
+	mux.Handle("GET /hashes/{algorithm}/{hash_value}", cfg.route(routeClassRead, scopeRead, cfg.GetTextsByHash))
+	mux.Handle("GET /strings/filter-by-natural-language", cfg.route(routeClassSearch, scopeRead, cfg.GetTexByNaturalLang))
+	mux.Handle("GET /strings/filter-by-natural-language/explain", cfg.route(routeClassRead, scopeRead, cfg.ExplainNaturalLanguageQuery))
+	mux.Handle("POST /strings", cfg.route(routeClassWrite, scopeWrite, cfg.CreateText))
+	mux.Handle("POST /strings/search", cfg.route(routeClassSearch, scopeRead, cfg.SearchStrings))
+	mux.Handle("GET /strings/search/schema", cfg.route(routeClassRead, scopeRead, cfg.GetSearchSchema))
+	mux.Handle("DELETE /strings/{string_value}", cfg.route(routeClassWrite, scopeDelete, cfg.DeleteText))
+	mux.Handle("POST /searches", cfg.route(routeClassWrite, scopeWrite, cfg.CreateSavedSearch))
+	mux.Handle("GET /searches", cfg.route(routeClassRead, scopeRead, cfg.ListSavedSearches))
+	mux.Handle("GET /searches/{name}", cfg.route(routeClassRead, scopeRead, cfg.GetSavedSearch))
+	mux.Handle("PUT /searches/{name}", cfg.route(routeClassWrite, scopeWrite, cfg.UpdateSavedSearch))
+	mux.Handle("DELETE /searches/{name}", cfg.route(routeClassWrite, scopeDelete, cfg.DeleteSavedSearch))
+	mux.Handle("GET /searches/{name}/results", cfg.route(routeClassSearch, scopeRead, cfg.GetSavedSearchResults))
+	mux.Handle("POST /admin/api-keys", cfg.route(routeClassWrite, scopeAdmin, cfg.CreateAPIKey))
+	mux.Handle("GET /admin/api-keys", cfg.route(routeClassRead, scopeAdmin, cfg.ListAPIKeys))
+	mux.Handle("DELETE /admin/api-keys/{id}", cfg.route(routeClassWrite, scopeAdmin, cfg.RevokeAPIKey))
+	// probes and metrics stay open and unlimited so orchestrators and scrapers don't need keys
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /healthz", cfg.Healthz)
+	mux.HandleFunc("GET /readyz", cfg.Readyz)
+	mux.HandleFunc("GET /version", cfg.Version)
+
+	return chain(cfg.limitUnmatched(mux), withRequestID, cfg.Tracer.traceRequests, logAccess, cfg.Metrics.observeRequests, recoverPanics, limitBodies(cfg.MaxBodyBytes), cfg.authenticate)
 }
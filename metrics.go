@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"github.com/HamstimusPrime/text-analyzer-api/internal/filter"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// latencyBuckets are upper bounds in seconds, the Prometheus client defaults
+var latencyBuckets = prometheus.DefBuckets
+
+// serviceMetrics holds every metric the service exposes on /metrics
+type serviceMetrics struct {
+	httpRequests  *prometheus.CounterVec
+	httpDuration  *prometheus.HistogramVec
+	dbDuration    *prometheus.HistogramVec
+	dbErrors      *prometheus.CounterVec
+	textsCreated  prometheus.Counter
+	textsRejected *prometheus.CounterVec
+	nlQueries     *prometheus.CounterVec
+	rateLimited   *prometheus.CounterVec
+}
+
+// newServiceMetrics registers the service's collectors, the server uses the default registry
+// that promhttp.Handler serves, tests their own
+func newServiceMetrics(registry prometheus.Registerer) *serviceMetrics {
+	m := &serviceMetrics{
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total", Help: "HTTP requests served, by route pattern and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "http_request_duration_seconds", Help: "Time taken to serve HTTP requests, by route pattern.", Buckets: latencyBuckets,
+		}, []string{"method", "route"}),
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "db_query_duration_seconds", Help: "Time taken by database queries, by sqlc query name.", Buckets: latencyBuckets,
+		}, []string{"query"}),
+		dbErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "db_query_errors_total", Help: "Database queries that returned an error, by sqlc query name.",
+		}, []string{"query"}),
+		textsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "texts_created_total", Help: "Texts analyzed and stored.",
+		}),
+		textsRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "texts_rejected_total", Help: "Texts rejected on creation, by reason (duplicate, numeric or quota).",
+		}, []string{"reason"}),
+		nlQueries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "nl_queries_total", Help: "Natural-language queries interpreted, by locale and result (parsed or failed).",
+		}, []string{"locale", "result"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_rate_limited_total", Help: "Requests refused by the rate limiter, by route class.",
+		}, []string{"class"}),
+	}
+	registry.MustRegister(m.httpRequests, m.httpDuration, m.dbDuration, m.dbErrors, m.textsCreated, m.textsRejected, m.nlQueries, m.rateLimited)
+	return m
+}
+
+// observeRequests counts every request and its latency under the route pattern it matched,
+// so /strings/racecar and /strings/level share one series
+func (m *serviceMetrics) observeRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		m.httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		m.httpDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// instrumentedDB times every query sent through it under the sqlc name of the query
+type instrumentedDB struct {
+	db      database.DBTX
+	metrics *serviceMetrics
+}
+
+func newInstrumentedDB(db database.DBTX, metrics *serviceMetrics) *instrumentedDB {
+	return &instrumentedDB{db: db, metrics: metrics}
+}
+
+func (idb *instrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := idb.db.ExecContext(ctx, query, args...)
+	idb.observe(query, start, err)
+	return result, err
+}
+
+func (idb *instrumentedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	start := time.Now()
+	stmt, err := idb.db.PrepareContext(ctx, query)
+	idb.observe(query, start, err)
+	return stmt, err
+}
+
+// QueryContext only times the query until its first rows are ready, not the caller's scanning
+func (idb *instrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := idb.db.QueryContext(ctx, query, args...)
+	idb.observe(query, start, err)
+	return rows, err
+}
+
+func (idb *instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := idb.db.QueryRowContext(ctx, query, args...)
+	idb.observe(query, start, row.Err())
+	return row
+}
+
+func (idb *instrumentedDB) observe(query string, start time.Time, err error) {
+	name := queryName(query)
+	idb.metrics.dbDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	// no rows is an answer, not a failure
+	if err != nil && err != sql.ErrNoRows {
+		idb.metrics.dbErrors.WithLabelValues(name).Inc()
+	}
+}
+
+// queryName reads the name from the "-- name: CreateText :one" header sqlc puts on every query
+func queryName(query string) string {
+	header, _, _ := strings.Cut(strings.TrimSpace(query), "\n")
+	fields := strings.Fields(strings.TrimPrefix(header, "-- name:"))
+	if !strings.HasPrefix(header, "-- name:") || len(fields) == 0 {
+		return "unknown"
+	}
+	return fields[0]
+}
+
+// instrumentedInterpreter counts the queries its interpreter could and couldn't parse
+type instrumentedInterpreter struct {
+	interpreter QueryInterpreter
+	metrics     *serviceMetrics
+}
+
+func (ii instrumentedInterpreter) Interpret(query string, lex *nlLexicon) (filter.Query, error) {
+	parsed, err := ii.interpreter.Interpret(query, lex)
+	result := "parsed"
+	if err != nil {
+		result = "failed"
+	}
+	ii.metrics.nlQueries.WithLabelValues(lex.locale, result).Inc()
+	return parsed, err
+}
+
+// Explain isn't counted, the query isn't run
+func (ii instrumentedInterpreter) Explain(query string, lex *nlLexicon) (ExplainResponse, error) {
+	return ii.interpreter.Explain(query, lex)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrumentedDBObservesQueries(t *testing.T) {
+	metrics := newServiceMetrics(prometheus.NewRegistry())
+	_, db := newFakeDB(t, map[string]fakeQuery{
+		"GetText": answer(),
+		"DeleteTextWithValue": func([]driver.Value) ([][]driver.Value, error) {
+			return nil, errors.New("connection reset")
+		},
+	})
+	q := database.New(newInstrumentedDB(db, metrics))
+
+	// finding nothing is timed but isn't an error
+	if _, err := q.GetText(context.Background(), "missing"); err == nil {
+		t.Fatal("GetText found a text in an empty database")
+	}
+	if err := q.DeleteTextWithValue(context.Background(), "level"); err == nil {
+		t.Fatal("DeleteTextWithValue succeeded")
+	}
+
+	if got := testutil.CollectAndCount(metrics.dbDuration); got != 2 {
+		t.Errorf("duration series = %d, want one per query", got)
+	}
+	if got := testutil.ToFloat64(metrics.dbErrors.WithLabelValues("GetText")); got != 0 {
+		t.Errorf("GetText errors = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(metrics.dbErrors.WithLabelValues("DeleteTextWithValue")); got != 1 {
+		t.Errorf("DeleteTextWithValue errors = %v, want 1", got)
+	}
+}
+
+func TestObserveRequestsByRoutePattern(t *testing.T) {
+	metrics := newServiceMetrics(prometheus.NewRegistry())
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /strings/{string_value}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handler := metrics.observeRequests(mux)
+	for _, path := range []string{"/strings/racecar", "/strings/level", "/nowhere"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(metrics.httpRequests.WithLabelValues("GET", "GET /strings/{string_value}", "404")); got != 2 {
+		t.Errorf("requests to the route = %v, want both texts in one series", got)
+	}
+	if got := testutil.ToFloat64(metrics.httpRequests.WithLabelValues("GET", "unmatched", "404")); got != 1 {
+		t.Errorf("unmatched requests = %v, want 1", got)
+	}
+}
+
+func TestMetricsExposition(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := newServiceMetrics(registry)
+	metrics.textsRejected.WithLabelValues("duplicate").Inc()
+
+	// a counter without labels is exposed from zero, before anything happened
+	want := `
+# HELP texts_created_total Texts analyzed and stored.
+# TYPE texts_created_total counter
+texts_created_total 0
+# HELP texts_rejected_total Texts rejected on creation, by reason (duplicate, numeric or quota).
+# TYPE texts_rejected_total counter
+texts_rejected_total{reason="duplicate"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(want), "texts_created_total", "texts_rejected_total"); err != nil {
+		t.Error(err)
+	}
+}
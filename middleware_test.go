@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newTestServer serves every route without a database, so any handler that reaches the database panics
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(newRouter(newTestConfig()))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestConfig is the default config without a database, auth or rate limits
+func newTestConfig() *apiConfig {
+	settings := config.Default()
+	return &apiConfig{
+		QueryFilters: queryFilters(settings.Analyzers),
+		Analyzers:    settings.Analyzers,
+		Interpreter:  grammarInterpreter{},
+		Metrics:      newServiceMetrics(prometheus.NewRegistry()),
+		Tracer:       newTracerWith(defaultServiceName),
+		PingTimeout:  settings.Database.PingTimeout,
+		MaxBodyBytes: settings.Limits.MaxBodyBytes,
+	}
+}
+
+func decodeProblem(t *testing.T, res *http.Response) map[string]any {
+	t.Helper()
+	if contentType := res.Header.Get("Content-Type"); contentType != problemContentType {
+		t.Fatalf("Content-Type = %q, want %q", contentType, problemContentType)
+	}
+	var problem map[string]any
+	if err := json.NewDecoder(res.Body).Decode(&problem); err != nil {
+		t.Fatalf("decoding problem details: %v", err)
+	}
+	return problem
+}
+
+func TestMalformedBodiesAreRejected(t *testing.T) {
+	server := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"truncated text", http.MethodPost, "/strings", `{"value": "racecar"`},
+		{"text is not an object", http.MethodPost, "/strings", `["racecar"]`},
+		{"empty text body", http.MethodPost, "/strings", ``},
+		{"wrong value type", http.MethodPost, "/strings", `{"value": 12}`},
+		{"truncated search", http.MethodPost, "/strings/search", `{"filter": {"field": "length"`},
+		{"saved search is not json", http.MethodPost, "/searches", `name=palindromes`},
+		{"saved search update is not json", http.MethodPut, "/searches/palindromes", `{`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed, the server may have gone down: %v", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", res.StatusCode, http.StatusBadRequest)
+			}
+			if code := decodeProblem(t, res)["code"]; code != codeInvalidRequestBody {
+				t.Errorf("code = %v, want %s", code, codeInvalidRequestBody)
+			}
+		})
+	}
+
+	// the server is still answering after every malformed request
+	res, err := http.Get(server.URL + "/strings/search/schema")
+	if err != nil {
+		t.Fatalf("server stopped responding: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+}
+
+func TestPanicsBecomeInternalErrors(t *testing.T) {
+	server := newTestServer(t)
+
+	// without a database GetText dereferences a nil connection
+	for i := 0; i < 2; i++ {
+		res, err := http.Get(server.URL + "/strings/racecar")
+		if err != nil {
+			t.Fatalf("request %d failed, the server may have gone down: %v", i, err)
+		}
+		problem := decodeProblem(t, res)
+		res.Body.Close()
+		if res.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("status = %d, want %d", res.StatusCode, http.StatusInternalServerError)
+		}
+		if problem["code"] != codeInternalError {
+			t.Errorf("code = %v, want %s", problem["code"], codeInternalError)
+		}
+		if requestID := res.Header.Get(requestIDHeader); requestID == "" || problem["request_id"] != requestID {
+			t.Errorf("request_id = %v, want the %s header %q", problem["request_id"], requestIDHeader, requestID)
+		}
+	}
+}
+
+func TestPanicAfterResponseStartedIsAborted(t *testing.T) {
+	handler := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"data": [`))
+		panic("failed halfway through")
+	}), recoverPanics)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	if err != nil {
+		// aborted before the client read the headers
+		return
+	}
+	defer res.Body.Close()
+	if _, err := io.ReadAll(res.Body); err == nil {
+		t.Fatal("expected the truncated response to be aborted")
+	}
+}
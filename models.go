@@ -1,75 +1,293 @@
 package main
 
 import (
+	"database/sql"
 	"time"
 
+	"github.com/HamstimusPrime/text-analyzer-api/internal/config"
 	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"github.com/HamstimusPrime/text-analyzer-api/internal/filter"
 	"github.com/google/uuid"
 )
 
 type apiConfig struct {
-	DB           *database.Queries
-	QueryFilters map[string]string
+	DB             *database.Queries
+	QueryFilters   map[string]string
+	HashAlgorithms []string
+	Blocklist      blocklist
+	Analyzers      config.Analyzers
+	Interpreter    QueryInterpreter
+	Metrics        *serviceMetrics
+	Tracer         *tracer
+	// Conn is the pool behind DB, used where a query isn't enough, e.g. to ping the database
+	Conn         *sql.DB
+	PingTimeout  time.Duration
+	MaxBodyBytes int64
+	// AuthEnabled turns on API key checks, AdminKeyHash is the hash of the bootstrap admin key from the config
+	AuthEnabled  bool
+	AdminKeyHash string
+	// RateLimiter is nil when rate limiting is off, DailyTextQuota is 0 when there is no quota
+	RateLimiter       *rateLimiter
+	TrustForwardedFor bool
+	DailyTextQuota    int
 }
 
 type RequestBody struct {
 	Value string `json:"value"`
+	Dedup string `json:"dedup,omitempty"`
+	// SimilarityThreshold is nil when the request leaves it out
+	SimilarityThreshold *float64 `json:"similarity_threshold,omitempty"`
+}
+
+// TextProperties holds the computed analytics returned for every stored text
+type TextProperties struct {
+	Length                int32             `json:"length"`
+	IsPalindrome          string            `json:"is_palindrome"`
+	UniqueCharacters      string            `json:"unique_characters"`
+	WordCount             string            `json:"word_count"`
+	Sha256Hash            string            `json:"sha256_hash"`
+	Language              string            `json:"language"`
+	LanguageConfidence    float64           `json:"language_confidence"`
+	Script                string            `json:"script"`
+	SentimentScore        float64           `json:"sentiment_score"`
+	SentimentLabel        string            `json:"sentiment_label"`
+	HasProfanity          bool              `json:"has_profanity"`
+	Hashes                map[string]string `json:"hashes,omitempty"`
+	CharacterFrequencyMap map[string]int    `json:"character_frequency_map"`
 }
 
 type FilteredTextsResponse struct {
-	Data []struct {
-		ID         string `json:"id"`
-		Value      string `json:"value"`
-		Properties struct {
-			Length                int32          `json:"length"`
-			IsPalindrome          string         `json:"is_palindrome"`
-			WordCount             string         `json:"word_count"`
-			Sha256Hash            string         `json:"sha256_hash"`
-			CharacterFrequencyMap map[string]int `json:"character_frequency_map"`
-		} `json:"properties"`
-		CreatedAt time.Time `json:"created_at"`
-	} `json:"data"`
-	Count          int `json:"count"`
-	FiltersApplied struct {
-		IsPalindrome      bool   `json:"is_palindrome"`
-		MinLength         int    `json:"min_length"`
-		MaxLength         int    `json:"max_length"`
-		WordCount         int    `json:"word_count"`
-		ContainsCharacter string `json:"contains_character"`
-	} `json:"filters_applied"`
+	Data           []SuccessResponseBody `json:"data"`
+	Count          int                   `json:"count"`
+	FiltersApplied FiltersApplied        `json:"filters_applied"`
+	NextCursor     string                `json:"next_cursor,omitempty"`
+}
+
+// FiltersApplied echoes back the filters used for a GET /strings request
+type FiltersApplied struct {
+	IsPalindrome      bool     `json:"is_palindrome"`
+	MinLength         int      `json:"min_length"`
+	MaxLength         int      `json:"max_length"`
+	WordCount         int      `json:"word_count"`
+	ContainsCharacter string   `json:"contains_character"`
+	Language          string   `json:"language,omitempty"`
+	SentimentMin      *float64 `json:"sentiment_min,omitempty"`
+	SentimentMax      *float64 `json:"sentiment_max,omitempty"`
+	HasProfanity      *bool    `json:"has_profanity,omitempty"`
+	StartsWith        string   `json:"starts_with,omitempty"`
+	EndsWith          string   `json:"ends_with,omitempty"`
+	CharAt            string   `json:"char_at,omitempty"`
 }
 
 type SuccessResponseBody struct {
-	ID         uuid.UUID `json:"id"`
-	Value      string    `json:"value"`
-	Properties struct {
-		Length                int32          `json:"length"`
-		IsPalindrome          string         `json:"is_palindrome"`
-		UniqueCharacters      string         `json:"unique_characters"`
-		WordCount             string         `json:"word_count"`
-		Sha256Hash            string         `json:"sha256_hash"`
-		CharacterFrequencyMap map[string]int `json:"character_frequency_map"`
-	} `json:"properties"`
-	CreatedAt time.Time `json:"created_at"`
-}
-
-// NLPFilters represents the parsed natural language query
+	ID         uuid.UUID      `json:"id"`
+	Value      string         `json:"value"`
+	Properties TextProperties `json:"properties"`
+	CreatedAt  time.Time      `json:"created_at"`
+	// CreatedByKey is the API key that stored the text, when it was created with one
+	CreatedByKey *uuid.UUID `json:"created_by_key,omitempty"`
+}
+
+// NLPFilters is the flat view of a parsed natural language query, when it only combines conditions with "and"
 type NLPFilters struct {
-	IsPalindrome      *bool   `json:"is_palindrome,omitempty"`
-	MinLength         *int    `json:"min_length,omitempty"`
-	MaxLength         *int    `json:"max_length,omitempty"`
-	WordCount         *int    `json:"word_count,omitempty"`
-	ContainsCharacter *string `json:"contains_character,omitempty"`
-	ContainsText      *string `json:"contains_text,omitempty"`
+	IsPalindrome      *bool    `json:"is_palindrome,omitempty"`
+	MinLength         *int     `json:"min_length,omitempty"`
+	MaxLength         *int     `json:"max_length,omitempty"`
+	WordCount         *int     `json:"word_count,omitempty"`
+	MinWordCount      *int     `json:"min_word_count,omitempty"`
+	MaxWordCount      *int     `json:"max_word_count,omitempty"`
+	ContainsCharacter *string  `json:"contains_character,omitempty"`
+	ContainsText      *string  `json:"contains_text,omitempty"`
+	Language          *string  `json:"language,omitempty"`
+	SentimentMin      *float64 `json:"sentiment_min,omitempty"`
+	SentimentMax      *float64 `json:"sentiment_max,omitempty"`
+	HasProfanity      *bool    `json:"has_profanity,omitempty"`
+	StartsWith        *string  `json:"starts_with,omitempty"`
+	EndsWith          *string  `json:"ends_with,omitempty"`
+	CharAt            *string  `json:"char_at,omitempty"`
 }
 
-// NaturalLanguageResponse represents the response format for natural language queries
+// views of the natural-language endpoint: just the matching values, or the same objects as GET /strings
+const (
+	viewValues = "values"
+	viewFull   = "full"
+)
+
+// NaturalLanguageResponse represents the response format for natural language queries,
+// Data holds []string for the values view and []SuccessResponseBody for the full view
 type NaturalLanguageResponse struct {
-	Data []string `json:"data"`
-	Count int `json:"count"`
+	Data             interface{} `json:"data"`
+	Count            int         `json:"count"`
+	NextCursor       string      `json:"next_cursor,omitempty"`
 	InterpretedQuery struct {
-		Original string `json:"original"`
-		ParsedFilters map[string]interface{} `json:"parsed_filters"`
+		Original      string                 `json:"original"`
+		Locale        string                 `json:"locale"`
+		ParsedFilters map[string]interface{} `json:"parsed_filters,omitempty"`
+		Filter        filter.Node            `json:"filter"`
+		Sort          []filter.Sort          `json:"sort,omitempty"`
+		Limit         int                    `json:"limit,omitempty"`
 	} `json:"interpreted_query"`
 }
 
+// SearchResponse is the result of a POST /strings/search, echoing the query that was run
+type SearchResponse struct {
+	Data       []SuccessResponseBody `json:"data"`
+	Count      int                   `json:"count"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+	Query      filter.Query          `json:"query"`
+}
+
+// SavedSearchRequest defines a saved search by either GET /strings filters or a natural-language query
+type SavedSearchRequest struct {
+	Name    string            `json:"name"`
+	Filters map[string]string `json:"filters,omitempty"`
+	Query   string            `json:"query,omitempty"`
+	Lang    string            `json:"lang,omitempty"`
+}
+
+type SavedSearchResponse struct {
+	ID              uuid.UUID              `json:"id"`
+	Name            string                 `json:"name"`
+	Kind            string                 `json:"kind"`
+	Filters         map[string]string      `json:"filters,omitempty"`
+	Query           string                 `json:"query,omitempty"`
+	Locale          string                 `json:"locale,omitempty"`
+	ParsedFilters   map[string]interface{} `json:"parsed_filters,omitempty"`
+	Search          filter.Query           `json:"search"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+	LastRunAt       *time.Time             `json:"last_run_at"`
+	LastResultCount *int                   `json:"last_result_count"`
+}
+
+type SavedSearchListResponse struct {
+	Data  []SavedSearchResponse `json:"data"`
+	Count int                   `json:"count"`
+}
+
+type APIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// APIKeyResponse describes a key, Key holds the key itself and is only set when the key is issued
+type APIKeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Key        string     `json:"key,omitempty"`
+}
+
+type APIKeyListResponse struct {
+	Data  []APIKeyResponse `json:"data"`
+	Count int              `json:"count"`
+}
+
+// SavedSearchResultsResponse is one run of a saved search, LastRunAt is the time of this run.
+// Count is the texts on this page and Total the texts the search matches across all pages
+type SavedSearchResultsResponse struct {
+	Name       string                `json:"name"`
+	Data       []SuccessResponseBody `json:"data"`
+	Count      int                   `json:"count"`
+	Total      int                   `json:"total"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+	LastRunAt  time.Time             `json:"last_run_at"`
+	Search     filter.Query          `json:"search"`
+}
+
+// ExplainResponse describes how a natural-language query was read, token by token
+type ExplainResponse struct {
+	Original       string               `json:"original"`
+	Locale         string               `json:"locale"`
+	Tokens         []ExplainToken       `json:"tokens"`
+	Spans          []ExplainSpan        `json:"spans"`
+	Ignored        []string             `json:"ignored"`
+	Interpretation filter.Query         `json:"interpretation"`
+	Conflicts      []filter.Conflict    `json:"conflicts,omitempty"`
+	Alternatives   []ExplainAlternative `json:"alternatives"`
+	// Rule names the rule that answered the query, its tokens aren't broken down
+	Rule string `json:"rule,omitempty"`
+}
+
+// ExplainToken is a single token of the query and the part it played: filter, order,
+// negation, operator, filler or ignored
+type ExplainToken struct {
+	Text  string `json:"text"`
+	Kind  string `json:"kind"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Role  string `json:"role"`
+}
+
+// ExplainSpan is a stretch of the query and the condition, sort or limit it produced
+type ExplainSpan struct {
+	Text   string        `json:"text"`
+	Start  int           `json:"start"`
+	End    int           `json:"end"`
+	Filter *filter.Node  `json:"filter,omitempty"`
+	Sort   []filter.Sort `json:"sort,omitempty"`
+	Limit  int           `json:"limit,omitempty"`
+}
+
+// ExplainAlternative is another plausible reading of the query, ranked by score
+type ExplainAlternative struct {
+	Rank   int         `json:"rank"`
+	Score  float64     `json:"score"`
+	Reason string      `json:"reason"`
+	Filter filter.Node `json:"filter"`
+}
+
+// NgramCount is a single ranked n-gram and how often it occurs
+type NgramCount struct {
+	Ngram     string `json:"ngram"`
+	Count     int    `json:"count"`
+	TextCount int    `json:"text_count,omitempty"`
+	Rank      int    `json:"rank"`
+}
+
+// NgramsResponse represents the n-gram breakdown for a single text or the whole corpus
+type NgramsResponse struct {
+	Value  string       `json:"value,omitempty"`
+	N      int          `json:"n"`
+	Unit   string       `json:"unit"`
+	Ngrams []NgramCount `json:"ngrams"`
+	Count  int          `json:"count"`
+}
+
+// HashLookupResponse represents the texts matching a hash lookup
+type HashLookupResponse struct {
+	Algorithm string                `json:"algorithm"`
+	Hash      string                `json:"hash"`
+	Data      []SuccessResponseBody `json:"data"`
+	Count     int                   `json:"count"`
+}
+
+// HealthResponse reports the service's health, Checks lists each dependency for readiness
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+type HealthCheck struct {
+	Status          string `json:"status"`
+	Error           string `json:"error,omitempty"`
+	CurrentVersion  *int64 `json:"current_version,omitempty"`
+	ExpectedVersion *int64 `json:"expected_version,omitempty"`
+}
+
+// VersionResponse describes the running build and the database schema it is using
+type VersionResponse struct {
+	Module                string `json:"module"`
+	Version               string `json:"version"`
+	Revision              string `json:"revision,omitempty"`
+	RevisionTime          string `json:"revision_time,omitempty"`
+	Modified              bool   `json:"modified"`
+	GoVersion             string `json:"go_version"`
+	SchemaVersion         *int64 `json:"schema_version"`
+	ExpectedSchemaVersion int64  `json:"expected_schema_version"`
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/config"
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	ngramUnitChar = "char"
+	ngramUnitWord = "word"
+	maxNgramSize  = 10
+)
+
+// persistedNgramSizes are stored in the ngram_count table on create so corpus queries don't rescan texts
+var persistedNgramSizes = []int{2, 3}
+
+// countNgrams counts the character or word n-grams of a string
+func countNgrams(str string, n int, unit string) map[string]int32 {
+	counts := make(map[string]int32)
+
+	var tokens []string
+	separator := ""
+	if unit == ngramUnitWord {
+		separator = " "
+		for _, word := range strings.Fields(strings.ToLower(str)) {
+			word = strings.TrimFunc(word, func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+			})
+			if word != "" {
+				tokens = append(tokens, word)
+			}
+		}
+	} else {
+		for _, r := range str {
+			tokens = append(tokens, string(r))
+		}
+	}
+
+	for i := 0; i+n <= len(tokens); i++ {
+		counts[strings.Join(tokens[i:i+n], separator)]++
+	}
+	return counts
+}
+
+// rankNgrams orders n-gram counts by frequency, breaking ties alphabetically
+func rankNgrams(counts map[string]int32) []NgramCount {
+	ranked := make([]NgramCount, 0, len(counts))
+	for ngram, count := range counts {
+		ranked = append(ranked, NgramCount{Ngram: ngram, Count: int(count)})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Count != ranked[j].Count {
+			return ranked[i].Count > ranked[j].Count
+		}
+		return ranked[i].Ngram < ranked[j].Ngram
+	})
+	for i := range ranked {
+		ranked[i].Rank = i + 1
+	}
+	return ranked
+}
+
+// storesNgrams reports whether the n-grams of value are stored on create. Longer texts have so many
+// distinct n-grams that storing them would dominate the request, theirs are computed when asked for
+func (cfg *apiConfig) storesNgrams(value string) bool {
+	return cfg.Analyzers.IsEnabled(config.AnalyzerNgrams) && utf8.RuneCountInString(value) <= cfg.Analyzers.NgramMaxLength
+}
+
+// storeNgrams writes the persisted n-gram sizes of both units in a single insert
+func storeNgrams(ctx context.Context, q *database.Queries, stringID uuid.UUID, value string) error {
+	params := database.CreateNgramCountsParams{StringID: stringID}
+	for _, unit := range []string{ngramUnitChar, ngramUnitWord} {
+		for _, n := range persistedNgramSizes {
+			for ngram, count := range countNgrams(value, n, unit) {
+				params.Units = append(params.Units, unit)
+				params.Ns = append(params.Ns, int32(n))
+				params.Ngrams = append(params.Ngrams, ngram)
+				params.NgramCounts = append(params.NgramCounts, count)
+			}
+		}
+	}
+	if len(params.Ngrams) == 0 {
+		return nil
+	}
+	return q.CreateNgramCounts(ctx, params)
+}
+
+func isPersistedNgramSize(n int) bool {
+	for _, size := range persistedNgramSizes {
+		if size == n {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNgramParams reads the n and unit query parameters, defaulting to character bigrams
+func parseNgramParams(r *http.Request) (int, string, error) {
+	n := 2
+	if value := r.URL.Query().Get("n"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxNgramSize {
+			return 0, "", invalidParameter(codeInvalidParameter, "n", "Invalid n parameter: must be an integer between 1 and "+strconv.Itoa(maxNgramSize))
+		}
+		n = parsed
+	}
+
+	unit := ngramUnitChar
+	if value := r.URL.Query().Get("unit"); value != "" {
+		if value != ngramUnitChar && value != ngramUnitWord {
+			return 0, "", invalidParameter(codeInvalidParameter, "unit", "Invalid unit parameter: must be 'char' or 'word'")
+		}
+		unit = value
+	}
+	return n, unit, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/config"
+)
+
+func TestCountNgrams(t *testing.T) {
+	tests := []struct {
+		name string
+		str  string
+		n    int
+		unit string
+		want map[string]int32
+	}{
+		{"char bigrams", "abab", 2, ngramUnitChar, map[string]int32{"ab": 2, "ba": 1}},
+		{"chars keep case and spaces", "Ab a", 2, ngramUnitChar, map[string]int32{"Ab": 1, "b ": 1, " a": 1}},
+		{"unicode characters, not bytes", "héé", 2, ngramUnitChar, map[string]int32{"hé": 1, "éé": 1}},
+		{"emoji", "👍👍👍", 2, ngramUnitChar, map[string]int32{"👍👍": 2}},
+		{"n is the whole text", "abc", 3, ngramUnitChar, map[string]int32{"abc": 1}},
+		{"n larger than the text", "abc", 4, ngramUnitChar, map[string]int32{}},
+		{"empty text", "", 1, ngramUnitChar, map[string]int32{}},
+		{"word bigrams", "The cat saw the cat", 2, ngramUnitWord, map[string]int32{"the cat": 2, "cat saw": 1, "saw the": 1}},
+		{"words drop punctuation", "Hello, world! (hello) world...", 2, ngramUnitWord, map[string]int32{"hello world": 2, "world hello": 1}},
+		{"unicode words", "Ça va, ÇA VA", 2, ngramUnitWord, map[string]int32{"ça va": 2, "va ça": 1}},
+		{"punctuation only words are skipped", "a -- b", 2, ngramUnitWord, map[string]int32{"a b": 1}},
+		{"n larger than the word count", "one two", 3, ngramUnitWord, map[string]int32{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countNgrams(tt.str, tt.n, tt.unit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("countNgrams(%q, %d, %s) = %v, want %v", tt.str, tt.n, tt.unit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankNgrams(t *testing.T) {
+	got := rankNgrams(map[string]int32{"cd": 1, "ab": 3, "zz": 3, "bc": 1, "é": 2})
+	want := []NgramCount{
+		{Ngram: "ab", Count: 3, Rank: 1},
+		{Ngram: "zz", Count: 3, Rank: 2},
+		{Ngram: "é", Count: 2, Rank: 3},
+		{Ngram: "bc", Count: 1, Rank: 4},
+		{Ngram: "cd", Count: 1, Rank: 5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rankNgrams = %+v, want %+v", got, want)
+	}
+	if got := rankNgrams(nil); len(got) != 0 {
+		t.Errorf("rankNgrams(nil) = %+v, want none", got)
+	}
+}
+
+func TestStoresNgrams(t *testing.T) {
+	enabled := config.Default().Analyzers
+	enabled.NgramMaxLength = 5
+	disabled := enabled
+	disabled.Enabled = []string{config.AnalyzerLanguage}
+
+	tests := []struct {
+		name      string
+		analyzers config.Analyzers
+		value     string
+		want      bool
+	}{
+		{"short text", enabled, "abc", true},
+		{"at the cutoff", enabled, "abcde", true},
+		{"past the cutoff", enabled, "abcdef", false},
+		{"cutoff counts characters, not bytes", enabled, "ééééé", true},
+		{"analyzer disabled", disabled, "abc", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &apiConfig{Analyzers: tt.analyzers}
+			if got := cfg.storesNgrams(tt.value); got != tt.want {
+				t.Errorf("storesNgrams(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNgramParams(t *testing.T) {
+	tests := []struct {
+		query    string
+		wantN    int
+		wantUnit string
+		wantErr  bool
+	}{
+		{"", 2, ngramUnitChar, false},
+		{"n=3&unit=word", 3, ngramUnitWord, false},
+		{"n=10", maxNgramSize, ngramUnitChar, false},
+		{"n=0", 0, "", true},
+		{"n=11", 0, "", true},
+		{"n=two", 0, "", true},
+		{"unit=sentence", 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/strings/abc/ngrams?"+tt.query, nil)
+			n, unit, err := parseNgramParams(r)
+			if (err != nil) != tt.wantErr || n != tt.wantN || unit != tt.wantUnit {
+				t.Errorf("parseNgramParams(%q) = %d, %s, %v, want %d, %s, error %v", tt.query, n, unit, err, tt.wantN, tt.wantUnit, tt.wantErr)
+			}
+		})
+	}
+}
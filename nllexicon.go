@@ -0,0 +1,651 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/filter"
+)
+
+// defaultLocale is used when a request names no supported locale
+const defaultLocale = "en"
+
+// sentiment words in a lexicon map to one of these
+const (
+	nlPositive = "positive"
+	nlNegative = "negative"
+	nlNeutral  = "neutral"
+	nlProfane  = "profane"
+)
+
+type nlComparator struct {
+	phrase []string
+	op     string
+	field  string
+}
+
+type nlSortPhrase struct {
+	phrase []string
+	sort   filter.Sort
+}
+
+type nlFieldPhrase struct {
+	phrase []string
+	field  string
+}
+
+// nlLexicon holds every word and phrase the parser recognizes in one locale.
+// Keys are lower-case and accent-folded, matching the word form of the tokens
+type nlLexicon struct {
+	locale  string
+	example string
+
+	// structure
+	fillers   map[string]bool
+	negations map[string]bool
+	orWords   map[string]bool
+	andWords  map[string]bool
+	elisions  map[string]bool
+
+	// numbers
+	numbers         map[string]int
+	tens            map[string]int
+	scales          map[string]int
+	dozens          map[string]bool
+	numberAnd       string
+	tensAnd         bool // "treinta y uno", "vingt et un"
+	compoundNumbers bool // "dix sept", "soixante dix", "quatre vingt"
+	indefinites     map[string]bool
+	definites       map[string]bool
+	ordinals        map[string]int
+	ordinalSuffixes map[string]bool
+	times           map[string]int
+	timesUnits      map[string]bool
+	countModifiers  map[string]bool
+
+	// lengths and comparisons
+	lengthUnits  map[string]bool
+	wordUnits    map[string]bool
+	longPhrases  [][]string
+	comparators  []nlComparator
+	between      map[string]bool
+	fieldPhrases []nlFieldPhrase
+	copulas      map[string]bool
+
+	// predicates
+	palindromes       map[string]bool
+	sameStarts        [][]string
+	sameTails         [][]string
+	containVerbs      map[string]bool
+	characterNouns    map[string]bool
+	occurVerbs        map[string]bool
+	firstVowel        [][]string
+	lastVowel         [][]string
+	anyVowel          [][]string
+	startVerbs        map[string]bool
+	endVerbs          map[string]bool
+	affixPrepositions map[string]bool
+	lastWords         map[string]bool
+	positionNouns     map[string]bool
+	languages         map[string]string
+	sentiments        map[string]string
+
+	// ordering
+	sortPhrases []nlSortPhrase
+	sortVerbs   map[string]bool
+	sortBy      string
+	sortFields  map[string]string
+	ascending   map[string]bool
+	descending  map[string]bool
+	limitWords  map[string]bool
+	orderNoise  map[string]bool
+}
+
+func words(list ...string) map[string]bool {
+	set := make(map[string]bool, len(list))
+	for _, word := range list {
+		set[word] = true
+	}
+	return set
+}
+
+func phrase(text string) []string {
+	return strings.Fields(text)
+}
+
+func sortBy(field, direction string, phrases ...string) []nlSortPhrase {
+	sorts := make([]nlSortPhrase, len(phrases))
+	for i, text := range phrases {
+		sorts[i] = nlSortPhrase{phrase(text), filter.Sort{Field: field, Direction: direction}}
+	}
+	return sorts
+}
+
+func concat(groups ...[]nlSortPhrase) []nlSortPhrase {
+	var all []nlSortPhrase
+	for _, group := range groups {
+		all = append(all, group...)
+	}
+	return all
+}
+
+// nlFoldAccents maps accented letters to their base letter so "palíndromo" and "palindromo" match.
+// "ù" is left alone since it only occurs in French "où", which must not become "ou" (or)
+var nlFoldAccents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i", "ó", "o", "ò", "o", "ô", "o", "ö", "o",
+	"ú", "u", "û", "u", "ü", "u", "ñ", "n", "ç", "c", "œ", "oe", "æ", "ae", "’", "'",
+)
+
+func foldWord(word string) string {
+	return nlFoldAccents.Replace(strings.ToLower(word))
+}
+
+var englishLexicon = &nlLexicon{
+	locale:  "en",
+	example: "Try queries like 'palindromes', 'single word palindromes', 'length > 10', 'contains character a', 'the 5 longest palindromes', etc.",
+
+	fillers: words("all", "any", "show", "me", "find", "get", "list", "give", "return", "please",
+		"strings", "string", "texts", "text", "values", "entries", "ones", "those", "that", "which",
+		"whose", "who", "are", "is", "be", "were", "have", "has", "with", "the", "a", "an", "of", "in",
+		"written", "long", "sentences", "phrases", "where", "words"),
+	negations: words("not", "non", "no", "without", "isn't", "aren't", "doesn't", "don't", "excluding", "except"),
+	orWords:   words("or"),
+	andWords:  words("and", "but", "then"),
+
+	numbers: map[string]int{
+		"zero": 0, "single": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+		"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+		"thirteen": 13, "fourteen": 14, "fifteen": 15, "sixteen": 16, "seventeen": 17,
+		"eighteen": 18, "nineteen": 19, "dozen": 12,
+	},
+	tens: map[string]int{
+		"twenty": 20, "thirty": 30, "forty": 40, "fifty": 50,
+		"sixty": 60, "seventy": 70, "eighty": 80, "ninety": 90,
+	},
+	scales:      map[string]int{"hundred": 100, "thousand": 1000},
+	dozens:      words("dozen"),
+	numberAnd:   "and",
+	indefinites: words("a", "an"),
+	definites:   words("the"),
+	ordinals: map[string]int{
+		"first": 0, "second": 1, "third": 2, "fourth": 3, "fifth": 4, "sixth": 5, "seventh": 6,
+		"eighth": 7, "ninth": 8, "tenth": 9, "eleventh": 10, "twelfth": 11, "thirteenth": 12,
+		"fourteenth": 13, "fifteenth": 14, "sixteenth": 15, "seventeenth": 16, "eighteenth": 17,
+		"nineteenth": 18, "twentieth": 19,
+	},
+	ordinalSuffixes: words("st", "nd", "rd", "th"),
+	times:           map[string]int{"once": 1, "twice": 2, "thrice": 3},
+	timesUnits:      words("times", "time"),
+	countModifiers:  words(),
+
+	lengthUnits: words("characters", "character", "chars", "char", "letters"),
+	wordUnits:   words("words", "word"),
+	longPhrases: [][]string{phrase("long")},
+	comparators: []nlComparator{
+		{phrase("greater than or equal to"), filter.OpGte, ""},
+		{phrase("less than or equal to"), filter.OpLte, ""},
+		{phrase("no more than"), filter.OpLte, ""},
+		{phrase("not more than"), filter.OpLte, ""},
+		{phrase("no less than"), filter.OpGte, ""},
+		{phrase("not less than"), filter.OpGte, ""},
+		{phrase("no fewer than"), filter.OpGte, ""},
+		{phrase("no longer than"), filter.OpLte, filter.FieldLength},
+		{phrase("no shorter than"), filter.OpGte, filter.FieldLength},
+		{phrase("at least"), filter.OpGte, ""},
+		{phrase("at most"), filter.OpLte, ""},
+		{phrase("up to"), filter.OpLte, ""},
+		{phrase("more than"), filter.OpGt, ""},
+		{phrase("greater than"), filter.OpGt, ""},
+		{phrase("longer than"), filter.OpGt, filter.FieldLength},
+		{phrase("less than"), filter.OpLt, ""},
+		{phrase("fewer than"), filter.OpLt, ""},
+		{phrase("shorter than"), filter.OpLt, filter.FieldLength},
+		{phrase("equal to"), filter.OpEq, ""},
+		{phrase("exactly"), filter.OpEq, ""},
+		{phrase("equals"), filter.OpEq, ""},
+		{phrase("over"), filter.OpGt, ""},
+		{phrase("above"), filter.OpGt, ""},
+		{phrase("exceeding"), filter.OpGt, ""},
+		{phrase("under"), filter.OpLt, ""},
+		{phrase("below"), filter.OpLt, ""},
+	},
+	between: words("between"),
+	fieldPhrases: []nlFieldPhrase{
+		{phrase("word count"), filter.FieldWordCount},
+		{phrase("number of words"), filter.FieldWordCount},
+		{phrase("number of characters"), filter.FieldLength},
+		{phrase("length"), filter.FieldLength},
+	},
+	copulas: words("is", "equals", "of", "="),
+
+	palindromes: words("palindrome", "palindromes", "palindromic"),
+	sameStarts:  [][]string{phrase("reads the same"), phrase("read the same"), phrase("same")},
+	sameTails: [][]string{
+		phrase("forwards and backwards"), phrase("backwards and forwards"),
+		phrase("forward and backward"), phrase("backward and forward"), phrase("both ways"),
+	},
+	containVerbs:      words("contain", "contains", "containing", "include", "includes", "including", "with", "has", "have", "having"),
+	characterNouns:    words("letter", "character", "char"),
+	occurVerbs:        words("appears", "appearing", "occurs", "occurring", "repeated"),
+	firstVowel:        [][]string{phrase("first vowel")},
+	lastVowel:         [][]string{phrase("last vowel")},
+	anyVowel:          [][]string{phrase("a vowel"), phrase("vowel")},
+	startVerbs:        words("starts", "start", "starting", "begins", "begin", "beginning"),
+	endVerbs:          words("ends", "end", "ending"),
+	affixPrepositions: words("with", "in"),
+	lastWords:         words("last"),
+	positionNouns:     words("letter", "character", "char", "vowel", "consonant"),
+	languages:         languageNames,
+	sentiments: map[string]string{
+		"positive": nlPositive, "happy": nlPositive, "negative": nlNegative, "sad": nlNegative,
+		"neutral": nlNeutral, "profane": nlProfane, "profanity": nlProfane, "offensive": nlProfane,
+		"vulgar": nlProfane, "swearing": nlProfane,
+	},
+
+	sortPhrases: concat(
+		sortBy(filter.FieldCreatedAt, filter.Desc, "most recent", "newest", "latest", "recent"),
+		sortBy(filter.FieldCreatedAt, filter.Asc, "least recent", "oldest", "earliest"),
+		sortBy(filter.FieldLength, filter.Desc, "longest"),
+		sortBy(filter.FieldLength, filter.Asc, "shortest"),
+		sortBy(filter.FieldWordCount, filter.Desc, "most words", "wordiest"),
+		sortBy(filter.FieldWordCount, filter.Asc, "fewest words"),
+		sortBy(filter.FieldSentimentScore, filter.Desc, "most positive"),
+		sortBy(filter.FieldSentimentScore, filter.Asc, "most negative"),
+		sortBy(filter.FieldValue, filter.Desc, "reverse alphabetical order", "reverse alphabetically", "reverse alphabetical", "z to a"),
+		sortBy(filter.FieldValue, filter.Asc, "alphabetical order", "alphabetically", "alphabetical", "a to z"),
+	),
+	sortVerbs: words("sorted", "sort", "ordered", "order"),
+	sortBy:    "by",
+	sortFields: map[string]string{
+		"length": filter.FieldLength, "size": filter.FieldLength, "words": filter.FieldWordCount,
+		"value": filter.FieldValue, "name": filter.FieldValue, "alphabet": filter.FieldValue,
+		"date": filter.FieldCreatedAt, "age": filter.FieldCreatedAt, "creation": filter.FieldCreatedAt,
+		"created": filter.FieldCreatedAt, "time": filter.FieldCreatedAt, "sentiment": filter.FieldSentimentScore,
+	},
+	ascending:  words("ascending", "asc", "increasing"),
+	descending: words("descending", "desc", "decreasing"),
+	limitWords: words("top", "first", "limit"),
+	orderNoise: words("first"),
+}
+
+var spanishLexicon = &nlLexicon{
+	locale:  "es",
+	example: "Prueba consultas como 'palíndromos', 'palíndromos de una sola palabra', 'longitud > 10', 'contiene la letra a', 'los 5 palíndromos más largos', etc.",
+
+	fillers: words("todos", "todas", "cualquier", "muestra", "muestrame", "mostrar", "dame", "encuentra",
+		"encontrar", "busca", "buscar", "lista", "listar", "cadenas", "cadena", "textos", "texto", "valores",
+		"frases", "palabras", "que", "cuyo", "cuya", "cuyos", "cuyas", "son", "es", "sea", "sean", "esta",
+		"estan", "con", "el", "la", "los", "las", "un", "una", "unos", "unas", "de", "del", "en", "escritas",
+		"escritos", "por", "favor", "donde", "tienen", "tiene"),
+	negations: words("no", "sin", "excepto", "salvo", "ni"),
+	orWords:   words("o", "u"),
+	andWords:  words("y", "e", "pero", "luego"),
+
+	numbers: map[string]int{
+		"cero": 0, "un": 1, "uno": 1, "una": 1, "dos": 2, "tres": 3, "cuatro": 4, "cinco": 5,
+		"seis": 6, "siete": 7, "ocho": 8, "nueve": 9, "diez": 10, "once": 11, "doce": 12,
+		"trece": 13, "catorce": 14, "quince": 15, "dieciseis": 16, "diecisiete": 17,
+		"dieciocho": 18, "diecinueve": 19, "veintiun": 21, "veintiuno": 21, "veintiuna": 21,
+		"veintidos": 22, "veintitres": 23, "veinticuatro": 24, "veinticinco": 25, "veintiseis": 26,
+		"veintisiete": 27, "veintiocho": 28, "veintinueve": 29, "docena": 12,
+	},
+	tens: map[string]int{
+		"veinte": 20, "treinta": 30, "cuarenta": 40, "cincuenta": 50,
+		"sesenta": 60, "setenta": 70, "ochenta": 80, "noventa": 90,
+	},
+	scales:      map[string]int{"cien": 100, "ciento": 100, "mil": 1000},
+	dozens:      words("docena"),
+	numberAnd:   "y",
+	tensAnd:     true,
+	indefinites: words("un", "una"),
+	definites:   words("el", "la", "los", "las"),
+	ordinals: map[string]int{
+		"primer": 0, "primera": 0, "primero": 0, "segundo": 1, "segunda": 1, "tercer": 2,
+		"tercera": 2, "tercero": 2, "cuarto": 3, "cuarta": 3, "quinto": 4, "quinta": 4,
+		"sexto": 5, "sexta": 5, "septimo": 6, "septima": 6, "octavo": 7, "octava": 7,
+		"noveno": 8, "novena": 8, "decimo": 9, "decima": 9,
+	},
+	ordinalSuffixes: words("o", "a", "er", "era", "º", "ª"),
+	times:           map[string]int{},
+	timesUnits:      words("veces", "vez"),
+	countModifiers:  words("sola", "solo", "unica", "unico"),
+
+	lengthUnits: words("caracteres", "caracter", "letras"),
+	wordUnits:   words("palabras", "palabra"),
+	longPhrases: [][]string{phrase("de largo"), phrase("de longitud")},
+	comparators: []nlComparator{
+		{phrase("mayor o igual a"), filter.OpGte, ""},
+		{phrase("mayor o igual que"), filter.OpGte, ""},
+		{phrase("menor o igual a"), filter.OpLte, ""},
+		{phrase("menor o igual que"), filter.OpLte, ""},
+		{phrase("no mas de"), filter.OpLte, ""},
+		{phrase("no menos de"), filter.OpGte, ""},
+		{phrase("mas largas que"), filter.OpGt, filter.FieldLength},
+		{phrase("mas largos que"), filter.OpGt, filter.FieldLength},
+		{phrase("mas larga que"), filter.OpGt, filter.FieldLength},
+		{phrase("mas largo que"), filter.OpGt, filter.FieldLength},
+		{phrase("mas cortas que"), filter.OpLt, filter.FieldLength},
+		{phrase("mas cortos que"), filter.OpLt, filter.FieldLength},
+		{phrase("mas corta que"), filter.OpLt, filter.FieldLength},
+		{phrase("mas corto que"), filter.OpLt, filter.FieldLength},
+		{phrase("por lo menos"), filter.OpGte, ""},
+		{phrase("al menos"), filter.OpGte, ""},
+		{phrase("como minimo"), filter.OpGte, ""},
+		{phrase("como maximo"), filter.OpLte, ""},
+		{phrase("como mucho"), filter.OpLte, ""},
+		{phrase("a lo sumo"), filter.OpLte, ""},
+		{phrase("hasta"), filter.OpLte, ""},
+		{phrase("mas de"), filter.OpGt, ""},
+		{phrase("mas que"), filter.OpGt, ""},
+		{phrase("mayor que"), filter.OpGt, ""},
+		{phrase("menos de"), filter.OpLt, ""},
+		{phrase("menos que"), filter.OpLt, ""},
+		{phrase("menor que"), filter.OpLt, ""},
+		{phrase("por encima de"), filter.OpGt, ""},
+		{phrase("por debajo de"), filter.OpLt, ""},
+		{phrase("igual a"), filter.OpEq, ""},
+		{phrase("exactamente"), filter.OpEq, ""},
+	},
+	between: words("entre"),
+	fieldPhrases: []nlFieldPhrase{
+		{phrase("numero de palabras"), filter.FieldWordCount},
+		{phrase("cantidad de palabras"), filter.FieldWordCount},
+		{phrase("numero de caracteres"), filter.FieldLength},
+		{phrase("cantidad de caracteres"), filter.FieldLength},
+		{phrase("longitud"), filter.FieldLength},
+	},
+	copulas: words("es", "sea", "="),
+
+	palindromes: words("palindromo", "palindromos", "palindroma", "palindromas", "palindromico",
+		"palindromicos", "palindromica", "palindromicas"),
+	sameStarts: [][]string{phrase("se leen igual"), phrase("se lee igual"), phrase("igual")},
+	sameTails: [][]string{
+		phrase("en ambos sentidos"), phrase("al derecho y al reves"),
+		phrase("de izquierda a derecha y de derecha a izquierda"),
+	},
+	containVerbs: words("contiene", "contienen", "conteniendo", "contenga", "contengan", "incluye",
+		"incluyen", "incluyendo", "con", "tiene", "tienen", "tengan"),
+	characterNouns:    words("letra", "caracter"),
+	occurVerbs:        words("aparece", "aparecen", "ocurre", "ocurren", "repetida", "repetido"),
+	firstVowel:        [][]string{phrase("primera vocal")},
+	lastVowel:         [][]string{phrase("ultima vocal")},
+	anyVowel:          [][]string{phrase("una vocal"), phrase("vocal")},
+	startVerbs:        words("empieza", "empiezan", "empiece", "empiecen", "empezando", "comienza", "comienzan", "comience", "comiencen", "comenzando", "inicia", "inician"),
+	endVerbs:          words("termina", "terminan", "termine", "terminen", "terminando", "acaba", "acaban", "acabando"),
+	affixPrepositions: words("con", "por", "en"),
+	lastWords:         words("ultima", "ultimo"),
+	positionNouns:     words("letra", "caracter", "vocal", "consonante"),
+	languages: map[string]string{
+		"ingles": "en", "inglesa": "en", "ingleses": "en", "inglesas": "en",
+		"frances": "fr", "francesa": "fr", "franceses": "fr", "francesas": "fr",
+		"espanol": "es", "espanola": "es", "espanoles": "es", "espanolas": "es", "castellano": "es",
+		"aleman": "de", "alemana": "de", "alemanes": "de", "alemanas": "de",
+		"italiano": "it", "italiana": "it", "italianos": "it", "italianas": "it",
+		"portugues": "pt", "portuguesa": "pt", "portugueses": "pt", "portuguesas": "pt",
+		"neerlandes": "nl", "holandes": "nl", "holandesa": "nl", "holandesas": "nl",
+		"ruso": "ru", "rusa": "ru", "rusos": "ru", "rusas": "ru",
+		"ucraniano": "uk", "ucraniana": "uk", "ucranianos": "uk", "ucranianas": "uk",
+		"arabe": "ar", "arabes": "ar", "griego": "el", "griega": "el", "griegos": "el", "griegas": "el",
+		"hebreo": "he", "hebrea": "he", "coreano": "ko", "coreana": "ko", "coreanos": "ko", "coreanas": "ko",
+		"tailandes": "th", "tailandesa": "th", "hindi": "hi",
+		"chino": "zh", "china": "zh", "chinos": "zh", "chinas": "zh",
+		"japones": "ja", "japonesa": "ja", "japoneses": "ja", "japonesas": "ja",
+	},
+	sentiments: map[string]string{
+		"positivo": nlPositive, "positiva": nlPositive, "positivos": nlPositive, "positivas": nlPositive,
+		"feliz": nlPositive, "felices": nlPositive,
+		"negativo": nlNegative, "negativa": nlNegative, "negativos": nlNegative, "negativas": nlNegative,
+		"triste": nlNegative, "tristes": nlNegative,
+		"neutral": nlNeutral, "neutrales": nlNeutral, "neutro": nlNeutral, "neutra": nlNeutral,
+		"neutros": nlNeutral, "neutras": nlNeutral,
+		"ofensivo": nlProfane, "ofensiva": nlProfane, "ofensivos": nlProfane, "ofensivas": nlProfane,
+		"vulgar": nlProfane, "vulgares": nlProfane, "groseras": nlProfane, "groseros": nlProfane,
+		"groserias": nlProfane, "palabrotas": nlProfane,
+	},
+
+	sortPhrases: concat(
+		sortBy(filter.FieldCreatedAt, filter.Desc, "mas recientes", "mas reciente", "mas nuevas", "mas nuevos", "recientes"),
+		sortBy(filter.FieldCreatedAt, filter.Asc, "mas antiguas", "mas antiguos", "mas antigua", "mas antiguo", "mas viejas", "mas viejos"),
+		sortBy(filter.FieldLength, filter.Desc, "mas largas", "mas largos", "mas larga", "mas largo"),
+		sortBy(filter.FieldLength, filter.Asc, "mas cortas", "mas cortos", "mas corta", "mas corto"),
+		sortBy(filter.FieldWordCount, filter.Desc, "mas palabras"),
+		sortBy(filter.FieldWordCount, filter.Asc, "menos palabras"),
+		sortBy(filter.FieldSentimentScore, filter.Desc, "mas positivas", "mas positivos"),
+		sortBy(filter.FieldSentimentScore, filter.Asc, "mas negativas", "mas negativos"),
+		sortBy(filter.FieldValue, filter.Desc, "orden alfabetico inverso", "de la z a la a"),
+		sortBy(filter.FieldValue, filter.Asc, "en orden alfabetico", "orden alfabetico", "alfabeticamente", "de la a a la z"),
+	),
+	sortVerbs: words("ordenadas", "ordenados", "ordenada", "ordenado", "ordenar", "ordena", "orden"),
+	sortBy:    "por",
+	sortFields: map[string]string{
+		"longitud": filter.FieldLength, "tamano": filter.FieldLength, "palabras": filter.FieldWordCount,
+		"valor": filter.FieldValue, "nombre": filter.FieldValue, "alfabeto": filter.FieldValue,
+		"fecha": filter.FieldCreatedAt, "edad": filter.FieldCreatedAt, "creacion": filter.FieldCreatedAt,
+		"sentimiento": filter.FieldSentimentScore,
+	},
+	ascending:  words("ascendente", "creciente", "asc"),
+	descending: words("descendente", "decreciente", "desc"),
+	limitWords: words("primeros", "primeras", "limite"),
+	orderNoise: words("primero"),
+}
+
+var frenchLexicon = &nlLexicon{
+	locale:  "fr",
+	example: "Essayez des requêtes comme 'palindromes', 'palindromes d'un seul mot', 'longueur > 10', 'contient la lettre a', 'les 5 palindromes les plus longs', etc.",
+
+	fillers: words("tous", "toutes", "tout", "montre", "montrez", "moi", "trouve", "trouvez", "trouver",
+		"donne", "donnez", "liste", "lister", "chaines", "chaine", "textes", "texte", "valeurs", "phrases",
+		"mots", "qui", "que", "qu'", "dont", "sont", "est", "soit", "soient", "avec", "en", "ecrites",
+		"ecrits", "le", "la", "les", "l'", "un", "une", "des", "de", "du", "d'", "ne", "n'", "s'", "c'",
+		"plait", "il", "ont", "a", "se"),
+	negations: words("pas", "non", "sans", "sauf", "excepte"),
+	orWords:   words("ou"),
+	andWords:  words("et", "mais", "puis"),
+	elisions:  words("l'", "d'", "qu'", "n'", "s'", "c'", "j'", "m'", "t'"),
+
+	numbers: map[string]int{
+		"zero": 0, "un": 1, "une": 1, "deux": 2, "trois": 3, "quatre": 4, "cinq": 5, "six": 6,
+		"sept": 7, "huit": 8, "neuf": 9, "dix": 10, "onze": 11, "douze": 12, "treize": 13,
+		"quatorze": 14, "quinze": 15, "seize": 16, "douzaine": 12,
+	},
+	tens: map[string]int{
+		"vingt": 20, "vingts": 20, "trente": 30, "quarante": 40, "cinquante": 50, "soixante": 60,
+	},
+	scales:          map[string]int{"cent": 100, "cents": 100, "mille": 1000},
+	dozens:          words("douzaine"),
+	numberAnd:       "et",
+	tensAnd:         true,
+	compoundNumbers: true,
+	indefinites:     words("un", "une"),
+	definites:       words("le", "la", "les", "l'"),
+	ordinals: map[string]int{
+		"premier": 0, "premiere": 0, "deuxieme": 1, "second": 1, "seconde": 1, "troisieme": 2,
+		"quatrieme": 3, "cinquieme": 4, "sixieme": 5, "septieme": 6, "huitieme": 7,
+		"neuvieme": 8, "dixieme": 9,
+	},
+	ordinalSuffixes: words("er", "re", "ere", "e", "eme", "nd", "nde"),
+	times:           map[string]int{},
+	timesUnits:      words("fois"),
+	countModifiers:  words("seul", "seule"),
+
+	lengthUnits: words("caracteres", "caractere", "lettres"),
+	wordUnits:   words("mots", "mot"),
+	longPhrases: [][]string{phrase("de long"), phrase("de longueur")},
+	comparators: []nlComparator{
+		{phrase("superieur ou egal a"), filter.OpGte, ""},
+		{phrase("superieure ou egale a"), filter.OpGte, ""},
+		{phrase("inferieur ou egal a"), filter.OpLte, ""},
+		{phrase("inferieure ou egale a"), filter.OpLte, ""},
+		{phrase("pas plus de"), filter.OpLte, ""},
+		{phrase("pas moins de"), filter.OpGte, ""},
+		{phrase("plus longues que"), filter.OpGt, filter.FieldLength},
+		{phrase("plus longs que"), filter.OpGt, filter.FieldLength},
+		{phrase("plus longue que"), filter.OpGt, filter.FieldLength},
+		{phrase("plus long que"), filter.OpGt, filter.FieldLength},
+		{phrase("plus courtes que"), filter.OpLt, filter.FieldLength},
+		{phrase("plus courts que"), filter.OpLt, filter.FieldLength},
+		{phrase("plus courte que"), filter.OpLt, filter.FieldLength},
+		{phrase("plus court que"), filter.OpLt, filter.FieldLength},
+		{phrase("au moins"), filter.OpGte, ""},
+		{phrase("au minimum"), filter.OpGte, ""},
+		{phrase("au plus"), filter.OpLte, ""},
+		{phrase("au maximum"), filter.OpLte, ""},
+		{phrase("jusqu'a"), filter.OpLte, ""},
+		{phrase("plus de"), filter.OpGt, ""},
+		{phrase("plus que"), filter.OpGt, ""},
+		{phrase("superieur a"), filter.OpGt, ""},
+		{phrase("superieure a"), filter.OpGt, ""},
+		{phrase("moins de"), filter.OpLt, ""},
+		{phrase("moins que"), filter.OpLt, ""},
+		{phrase("inferieur a"), filter.OpLt, ""},
+		{phrase("inferieure a"), filter.OpLt, ""},
+		{phrase("au dessus de"), filter.OpGt, ""},
+		{phrase("en dessous de"), filter.OpLt, ""},
+		{phrase("au dessous de"), filter.OpLt, ""},
+		{phrase("egal a"), filter.OpEq, ""},
+		{phrase("egale a"), filter.OpEq, ""},
+		{phrase("exactement"), filter.OpEq, ""},
+	},
+	between: words("entre"),
+	fieldPhrases: []nlFieldPhrase{
+		{phrase("nombre de mots"), filter.FieldWordCount},
+		{phrase("nombre de caracteres"), filter.FieldLength},
+		{phrase("longueur"), filter.FieldLength},
+	},
+	copulas: words("est", "soit", "="),
+
+	palindromes: words("palindrome", "palindromes", "palindromique", "palindromiques"),
+	sameStarts:  [][]string{phrase("lisent pareil"), phrase("lit pareil"), phrase("pareil")},
+	sameTails: [][]string{
+		phrase("dans les deux sens"), phrase("a l'endroit et a l'envers"),
+		phrase("de gauche a droite et de droite a gauche"),
+	},
+	containVerbs: words("contient", "contiennent", "contenant", "contenir", "inclut", "incluent",
+		"incluant", "avec", "a", "ont", "ayant"),
+	characterNouns:    words("lettre", "caractere"),
+	occurVerbs:        words("apparait", "apparaissent", "figure", "figurent", "repetee", "repete"),
+	firstVowel:        [][]string{phrase("premiere voyelle")},
+	lastVowel:         [][]string{phrase("derniere voyelle")},
+	anyVowel:          [][]string{phrase("une voyelle"), phrase("voyelle")},
+	startVerbs:        words("commence", "commencent", "commencant", "debute", "debutent", "debutant"),
+	endVerbs:          words("finit", "finissent", "finissant", "termine", "terminent", "terminant"),
+	affixPrepositions: words("par", "avec", "en"),
+	lastWords:         words("derniere", "dernier"),
+	positionNouns:     words("lettre", "caractere", "voyelle", "consonne"),
+	languages: map[string]string{
+		"anglais": "en", "anglaise": "en", "anglaises": "en",
+		"francais": "fr", "francaise": "fr", "francaises": "fr",
+		"espagnol": "es", "espagnole": "es", "espagnols": "es", "espagnoles": "es",
+		"allemand": "de", "allemande": "de", "allemands": "de", "allemandes": "de",
+		"italien": "it", "italienne": "it", "italiens": "it", "italiennes": "it",
+		"portugais": "pt", "portugaise": "pt", "portugaises": "pt",
+		"neerlandais": "nl", "neerlandaise": "nl", "neerlandaises": "nl",
+		"russe": "ru", "russes": "ru", "ukrainien": "uk", "ukrainienne": "uk", "ukrainiennes": "uk",
+		"arabe": "ar", "arabes": "ar", "grec": "el", "grecque": "el", "grecques": "el",
+		"hebreu": "he", "hebraique": "he", "coreen": "ko", "coreenne": "ko", "coreennes": "ko",
+		"thai": "th", "thaie": "th", "hindi": "hi",
+		"chinois": "zh", "chinoise": "zh", "chinoises": "zh",
+		"japonais": "ja", "japonaise": "ja", "japonaises": "ja",
+	},
+	sentiments: map[string]string{
+		"positif": nlPositive, "positive": nlPositive, "positifs": nlPositive, "positives": nlPositive,
+		"heureux": nlPositive, "heureuse": nlPositive, "heureuses": nlPositive,
+		"negatif": nlNegative, "negative": nlNegative, "negatifs": nlNegative, "negatives": nlNegative,
+		"triste": nlNegative, "tristes": nlNegative,
+		"neutre": nlNeutral, "neutres": nlNeutral,
+		"vulgaire": nlProfane, "vulgaires": nlProfane, "offensant": nlProfane, "offensante": nlProfane,
+		"offensants": nlProfane, "offensantes": nlProfane, "grossier": nlProfane, "grossiere": nlProfane,
+		"grossiers": nlProfane, "grossieres": nlProfane, "injurieux": nlProfane, "injurieuse": nlProfane,
+		"injurieuses": nlProfane,
+	},
+
+	sortPhrases: concat(
+		sortBy(filter.FieldCreatedAt, filter.Desc, "plus recentes", "plus recents", "plus recente", "plus recent", "recentes", "recents"),
+		sortBy(filter.FieldCreatedAt, filter.Asc, "plus anciennes", "plus anciens", "plus ancienne", "plus ancien", "plus vieilles", "plus vieux"),
+		sortBy(filter.FieldLength, filter.Desc, "plus longues", "plus longs", "plus longue", "plus long"),
+		sortBy(filter.FieldLength, filter.Asc, "plus courtes", "plus courts", "plus courte", "plus court"),
+		sortBy(filter.FieldWordCount, filter.Desc, "plus de mots"),
+		sortBy(filter.FieldWordCount, filter.Asc, "moins de mots"),
+		sortBy(filter.FieldSentimentScore, filter.Desc, "plus positives", "plus positifs"),
+		sortBy(filter.FieldSentimentScore, filter.Asc, "plus negatives", "plus negatifs"),
+		sortBy(filter.FieldValue, filter.Desc, "ordre alphabetique inverse", "de z a a"),
+		sortBy(filter.FieldValue, filter.Asc, "par ordre alphabetique", "ordre alphabetique", "alphabetiquement", "de a a z"),
+	),
+	sortVerbs: words("triees", "tries", "triee", "trie", "trier", "classees", "classes", "classee", "classe", "classer"),
+	sortBy:    "par",
+	sortFields: map[string]string{
+		"longueur": filter.FieldLength, "taille": filter.FieldLength, "mots": filter.FieldWordCount,
+		"valeur": filter.FieldValue, "nom": filter.FieldValue, "alphabet": filter.FieldValue,
+		"date": filter.FieldCreatedAt, "age": filter.FieldCreatedAt, "creation": filter.FieldCreatedAt,
+		"sentiment": filter.FieldSentimentScore,
+	},
+	ascending:  words("croissant", "croissante", "ascendant", "asc"),
+	descending: words("decroissant", "decroissante", "descendant", "desc"),
+	limitWords: words("premiers", "premieres", "limite"),
+	orderNoise: words("premier"),
+}
+
+// nlLexicons are the locales the natural-language parser understands
+var nlLexicons = map[string]*nlLexicon{
+	englishLexicon.locale: englishLexicon,
+	spanishLexicon.locale: spanishLexicon,
+	frenchLexicon.locale:  frenchLexicon,
+}
+
+// negotiateLocale picks the query locale from the lang parameter, which must be supported,
+// or else the most preferred supported language of the Accept-Language header
+func negotiateLocale(r *http.Request) (*nlLexicon, error) {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		lexicon, ok := nlLexicons[primaryLanguage(lang)]
+		if !ok {
+			return nil, invalidParameter(codeUnsupportedLocale, "lang", "Unsupported lang parameter: must be one of "+strings.Join(supportedLocales(), ", "))
+		}
+		return lexicon, nil
+	}
+
+	type preference struct {
+		locale  string
+		quality float64
+	}
+	var preferences []preference
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if _, ok := nlLexicons[primaryLanguage(tag)]; ok && quality > 0 {
+			preferences = append(preferences, preference{primaryLanguage(tag), quality})
+		}
+	}
+	sort.SliceStable(preferences, func(i, j int) bool {
+		return preferences[i].quality > preferences[j].quality
+	})
+	if len(preferences) > 0 {
+		return nlLexicons[preferences[0].locale], nil
+	}
+	return nlLexicons[defaultLocale], nil
+}
+
+// primaryLanguage reduces a language tag such as "es-MX" to "es"
+func primaryLanguage(tag string) string {
+	primary, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	primary, _, _ = strings.Cut(primary, "_")
+	return strings.ToLower(primary)
+}
+
+func supportedLocales() []string {
+	locales := make([]string, 0, len(nlLexicons))
+	for locale := range nlLexicons {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
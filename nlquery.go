@@ -0,0 +1,1051 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/filter"
+)
+
+type nlTokenKind int
+
+const (
+	tokenWord nlTokenKind = iota
+	tokenNumber
+	tokenQuoted
+	tokenSymbol
+)
+
+// nlToken is a single word, number, quoted string or symbol, with its byte offsets in the original query.
+// word is the lower-cased, accent-folded form keywords are matched against, empty for quoted strings
+type nlToken struct {
+	text  string
+	word  string
+	kind  nlTokenKind
+	start int
+	end   int
+}
+
+// tokenizeQuery splits a natural-language query into lower-cased words, numbers,
+// quoted strings (case preserved) and the symbols ( ) , = != < <= > >=.
+// Elided articles of the lexicon are split off the word they're attached to: "l'eau" is "l'" "eau"
+func tokenizeQuery(query string, lex *nlLexicon) []nlToken {
+	var tokens []nlToken
+	for i := 0; i < len(query); {
+		r, size := utf8.DecodeRuneInString(query[i:])
+		switch {
+		case unicode.IsSpace(r) || r == '-' || r == '?' || r == '¿' || r == '!' && !strings.HasPrefix(query[i:], "!=") || r == '¡':
+			i += size
+
+		case r == '\'' || r == '"':
+			end := strings.IndexRune(query[i+size:], r)
+			if end == -1 {
+				i += size
+				continue
+			}
+			tokens = append(tokens, nlToken{text: query[i+size : i+size+end], kind: tokenQuoted, start: i, end: i + size + end + size})
+			i += size + end + size
+
+		case strings.ContainsRune("()<>=!,", r):
+			text := string(r)
+			if (r == '<' || r == '>' || r == '!') && strings.HasPrefix(query[i+size:], "=") {
+				text += "="
+			}
+			tokens = append(tokens, nlToken{text: text, word: text, kind: tokenSymbol, start: i, end: i + len(text)})
+			i += len(text)
+
+		case unicode.IsDigit(r):
+			end := i
+			for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+				end++
+			}
+			tokens = append(tokens, nlToken{text: query[i:end], word: query[i:end], kind: tokenNumber, start: i, end: end})
+			i = end
+
+		case unicode.IsLetter(r):
+			end := i
+			for end < len(query) {
+				next, nextSize := utf8.DecodeRuneInString(query[end:])
+				if !unicode.IsLetter(next) && next != '\'' && next != '’' {
+					break
+				}
+				end += nextSize
+				// split "l'" from "l'eau"
+				if next == '\'' || next == '’' {
+					if lex.elisions[foldWord(query[i:end])] {
+						break
+					}
+				}
+			}
+			text := strings.ToLower(query[i:end])
+			tokens = append(tokens, nlToken{text: text, word: foldWord(text), kind: tokenWord, start: i, end: end})
+			i = end
+
+		default:
+			// any other punctuation is treated like whitespace
+			i += size
+		}
+	}
+	return tokens
+}
+
+// nlSymbolComparators are understood in every locale
+var nlSymbolComparators = []nlComparator{
+	{[]string{">="}, filter.OpGte, ""},
+	{[]string{"<="}, filter.OpLte, ""},
+	{[]string{">"}, filter.OpGt, ""},
+	{[]string{"<"}, filter.OpLt, ""},
+	{[]string{"="}, filter.OpEq, ""},
+	{[]string{"!="}, filter.OpNeq, ""},
+}
+
+// nlVowels are the alternatives for "a vowel"
+var nlVowels = []string{"a", "e", "i", "o", "u"}
+
+// nlParser is a recursive-descent parser for the grammar
+//
+//	query     = or
+//	or        = and { "or" and }
+//	and       = (unary | modifier) { ["and" | ","] (unary | modifier) }
+//	modifier  = [number] superlative [number] | ("top" | "first" | "limit") number | "sorted by" field [direction]
+//	unary     = negation unary | "(" or ")" | predicate
+//	predicate = palindrome | comparison | length | word count | contains | affix | position | language | sentiment
+//
+// where every keyword comes from the lexicon of the query's locale
+type nlParser struct {
+	lex     *nlLexicon
+	tokens  []nlToken
+	pos     int
+	ignored []nlToken
+	sort    []filter.Sort
+	limit   int
+	spans   []nlSpan
+	guesses []nlGuess
+
+	// a count waiting for the superlative after its noun, "los 5 palíndromos más largos"
+	pending      int
+	pendingStart int
+}
+
+// nlSpan records the tokens [start, end) that produced a condition or an ordering
+type nlSpan struct {
+	start int
+	end   int
+	node  *filter.Node
+	sort  []filter.Sort
+	limit int
+}
+
+// nlGuess records a condition chosen from several plausible readings of the same words
+type nlGuess struct {
+	chosen filter.Node
+	other  filter.Node
+	reason string
+}
+
+func newNLParser(query string, lex *nlLexicon) *nlParser {
+	return &nlParser{lex: lex, tokens: tokenizeQuery(query, lex)}
+}
+
+// parseNaturalLanguageQuery converts natural language in the lexicon's locale to a filter tree
+// with its sort order and limit
+func parseNaturalLanguageQuery(query string, lex *nlLexicon) (filter.Query, error) {
+	return newNLParser(query, lex).parse(query)
+}
+
+func (p *nlParser) parse(query string) (filter.Query, error) {
+	node, err := p.parseOr()
+	if err != nil {
+		return filter.Query{}, err
+	}
+	if !p.atEnd() {
+		return filter.Query{}, fmt.Errorf("unexpected %q at position %d", p.peek().text, p.peek().start)
+	}
+	if p.pending != 0 {
+		// no superlative followed, so the number wasn't a count after all
+		p.ignored = append(p.ignored, p.tokens[p.pendingStart])
+		for i, span := range p.spans {
+			if span.start == p.pendingStart && span.node == nil {
+				p.spans = append(p.spans[:i], p.spans[i+1:]...)
+				break
+			}
+		}
+	}
+	parsed := filter.Query{Where: node, Sort: p.sort, Limit: p.limit}
+
+	// If no patterns matched, return an error
+	if node.IsEmpty() && len(parsed.Sort) == 0 && parsed.Limit == 0 {
+		return parsed, fmt.Errorf("could not parse query: '%s'. %s", query, p.lex.example)
+	}
+	return parsed, nil
+}
+
+func (p *nlParser) atEnd() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *nlParser) peek() nlToken {
+	return p.peekAt(0)
+}
+
+func (p *nlParser) peekAt(offset int) nlToken {
+	if p.pos+offset >= len(p.tokens) {
+		return nlToken{}
+	}
+	return p.tokens[p.pos+offset]
+}
+
+// peekWord returns the keyword form of the current token, or "" for quoted strings so they never act as keywords
+func (p *nlParser) peekWord() string {
+	return p.peek().word
+}
+
+// matchPhrase reports whether the upcoming tokens are exactly the given words
+func (p *nlParser) matchPhrase(words ...string) bool {
+	for i, word := range words {
+		if token := p.peekAt(i); token.kind == tokenQuoted || token.word != word {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAny consumes the first of the phrases found at the current token
+func (p *nlParser) matchAny(phrases [][]string) bool {
+	for _, phrase := range phrases {
+		if p.matchPhrase(phrase...) {
+			p.pos += len(phrase)
+			return true
+		}
+	}
+	return false
+}
+
+func (p *nlParser) skipFillers() {
+	for !p.atEnd() && p.lex.fillers[p.peekWord()] {
+		p.pos++
+	}
+}
+
+func (p *nlParser) skipCopulas() {
+	for p.lex.copulas[p.peekWord()] {
+		p.pos++
+	}
+}
+
+func (p *nlParser) parseOr() (filter.Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return filter.Node{}, err
+	}
+	for p.lex.orWords[p.peekWord()] {
+		orToken := p.peek()
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return filter.Node{}, err
+		}
+		if left.IsEmpty() || right.IsEmpty() {
+			return filter.Node{}, fmt.Errorf("'%s' at position %d must join two conditions", orToken.text, orToken.start)
+		}
+		left = filter.Or(left, right)
+	}
+	return left, nil
+}
+
+func (p *nlParser) parseAnd() (filter.Node, error) {
+	var nodes []filter.Node
+	for !p.atEnd() {
+		next := p.peekWord()
+		if p.lex.orWords[next] || next == ")" {
+			break
+		}
+		start, sorts, limit := p.pos, len(p.sort), p.limit
+		if ok, err := p.parseModifier(); err != nil {
+			return filter.Node{}, err
+		} else if ok {
+			span := nlSpan{start: start, end: p.pos, sort: p.sort[sorts:]}
+			if p.limit != limit {
+				span.limit = p.limit
+			}
+			p.spans = append(p.spans, span)
+			continue
+		}
+		if p.lex.andWords[next] || next == "," || (p.lex.fillers[next] && !p.atUnitComparison()) {
+			// "una", "un" and "une" are both articles and numbers, "de una sola palabra"
+			if p.lex.fillers[next] && p.nextIsNumberWord(0) {
+				if node, ok, err := p.parseUnary(); err != nil {
+					return filter.Node{}, err
+				} else if ok {
+					nodes = append(nodes, node)
+					continue
+				}
+				p.pos = start
+			}
+			p.pos++
+			continue
+		}
+
+		node, ok, err := p.parseUnary()
+		if err != nil {
+			return filter.Node{}, err
+		}
+		if !ok {
+			// not part of any condition, remember it so callers can report it
+			p.ignored = append(p.ignored, p.peek())
+			p.pos++
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return filter.And(nodes...), nil
+}
+
+func (p *nlParser) parseUnary() (filter.Node, bool, error) {
+	token := p.peek()
+
+	// "no more than" and friends are comparators rather than negations
+	if p.lex.negations[p.peekWord()] && !p.atComparator() {
+		p.pos++
+		p.skipFillers()
+		operand, ok, err := p.parseUnary()
+		if err != nil {
+			return filter.Node{}, false, err
+		}
+		if !ok {
+			p.ignored = append(p.ignored, token)
+			return filter.Node{}, false, nil
+		}
+		return filter.Not(operand), true, nil
+	}
+
+	if p.peekWord() == "(" {
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return filter.Node{}, false, err
+		}
+		if p.peekWord() != ")" {
+			return filter.Node{}, false, fmt.Errorf("missing ')' for '(' at position %d", token.start)
+		}
+		p.pos++
+		if node.IsEmpty() {
+			return filter.Node{}, false, fmt.Errorf("empty parentheses at position %d", token.start)
+		}
+		return node, true, nil
+	}
+
+	return p.parsePredicate()
+}
+
+// parseModifier consumes a phrase that orders or limits the results rather than filtering them:
+// "the 5 longest", "most recent 10", "top 3", "alphabetically", "sorted by length descending"
+func (p *nlParser) parseModifier() (bool, error) {
+	start := p.pos
+
+	// a count before a superlative, "the 5 longest"
+	indefinite := p.lex.indefinites[p.peekWord()]
+	if limit, ok := p.parseNumber(); ok {
+		if p.parseSortPhrase() {
+			return true, p.setLimit(limit, start)
+		}
+		// or before the noun the superlative follows, "los 5 palíndromos más largos"
+		afterNumber := p.pos
+		_, isUnit := p.parseUnit()
+		next := p.peekWord()
+		p.pos = afterNumber
+		if indefinite || isUnit || p.lex.countModifiers[next] || p.lex.timesUnits[next] || p.pending != 0 || p.atEnd() {
+			p.pos = start
+			return false, nil
+		}
+		p.pending, p.pendingStart = limit, start
+		return true, nil
+	}
+
+	word := p.peekWord()
+	if p.lex.limitWords[word] {
+		p.pos++
+		if limit, ok := p.parseNumber(); ok {
+			if _, isUnit := p.parseUnit(); !isUnit {
+				return true, p.setLimit(limit, start)
+			}
+		}
+		p.pos = start
+	}
+	// "shortest strings first" only restates the order, but "first letter" is positional
+	if p.lex.orderNoise[word] {
+		next := p.peekAt(1)
+		if !p.lex.positionNouns[next.word] && !p.nextIsNumberWord(1) && next.kind != tokenNumber {
+			p.pos++
+			return true, nil
+		}
+		return false, nil
+	}
+
+	if p.lex.sortVerbs[word] && p.peekAt(1).word == p.lex.sortBy {
+		field, ok := p.lex.sortFields[p.peekAt(2).word]
+		if !ok {
+			return false, nil
+		}
+		p.pos += 3
+		direction := filter.Asc
+		if field == filter.FieldCreatedAt {
+			direction = filter.Desc
+		}
+		switch {
+		case p.lex.ascending[p.peekWord()]:
+			direction = filter.Asc
+			p.pos++
+		case p.lex.descending[p.peekWord()]:
+			direction = filter.Desc
+			p.pos++
+		}
+		p.addSort(filter.Sort{Field: field, Direction: direction})
+		return true, nil
+	}
+
+	if !p.parseSortPhrase() {
+		return false, nil
+	}
+	// a count after a superlative, "most recent 10", but not "longest 3 words"
+	afterSort := p.pos
+	if limit, ok := p.parseNumber(); ok {
+		if _, isUnit := p.parseUnit(); !isUnit {
+			return true, p.setLimit(limit, start)
+		}
+		p.pos = afterSort
+	}
+	return true, nil
+}
+
+// parseSortPhrase consumes a superlative or ordering phrase and records its sort.
+// A longer comparative wins, so "plus longues que 5" is a comparison rather than "plus longues"
+func (p *nlParser) parseSortPhrase() bool {
+	_, _, comparatorSize := p.peekComparator()
+	for _, candidate := range p.lex.sortPhrases {
+		if len(candidate.phrase) > comparatorSize && p.matchPhrase(candidate.phrase...) {
+			p.pos += len(candidate.phrase)
+			p.addSort(candidate.sort)
+			if p.pending != 0 && p.limit == 0 {
+				p.limit, p.pending = p.pending, 0
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// addSort appends a sort unless its field is already ordered by an earlier phrase
+func (p *nlParser) addSort(sort filter.Sort) {
+	for _, existing := range p.sort {
+		if existing.Field == sort.Field {
+			return
+		}
+	}
+	p.sort = append(p.sort, sort)
+}
+
+func (p *nlParser) setLimit(limit, start int) error {
+	if limit < 1 {
+		return fmt.Errorf("limit at position %d must be at least 1", p.tokens[start].start)
+	}
+	if p.limit != 0 && p.limit != limit {
+		return fmt.Errorf("conflicting limits %d and %d", p.limit, limit)
+	}
+	p.limit = limit
+	return nil
+}
+
+func (p *nlParser) parsePredicate() (filter.Node, bool, error) {
+	start := p.pos
+	for _, predicate := range []func() (filter.Node, bool, error){
+		p.parsePalindrome,
+		p.parseComparison,
+		p.parseFieldComparison,
+		p.parseCount,
+		p.parseContains,
+		p.parseAffix,
+		p.parsePosition,
+		p.parseLanguage,
+		p.parseSentiment,
+	} {
+		node, ok, err := predicate()
+		if err != nil {
+			return node, ok, err
+		}
+		if ok {
+			p.spans = append(p.spans, nlSpan{start: start, end: p.pos, node: &node})
+			return node, true, nil
+		}
+		p.pos = start
+	}
+	return filter.Node{}, false, nil
+}
+
+func (p *nlParser) parsePalindrome() (filter.Node, bool, error) {
+	if p.lex.palindromes[p.peekWord()] {
+		p.pos++
+		return filter.Cond(filter.FieldIsPalindrome, filter.OpEq, true), true, nil
+	}
+
+	// "reads the same forwards and backwards" and its variations
+	if p.matchAny(p.lex.sameStarts) && p.matchAny(p.lex.sameTails) {
+		return filter.Cond(filter.FieldIsPalindrome, filter.OpEq, true), true, nil
+	}
+	return filter.Node{}, false, nil
+}
+
+// atComparator reports whether a comparative phrase starts at the current token
+func (p *nlParser) atComparator() bool {
+	_, _, size := p.peekComparator()
+	return size > 0
+}
+
+func (p *nlParser) peekComparator() (string, string, int) {
+	for _, comparators := range [][]nlComparator{p.lex.comparators, nlSymbolComparators} {
+		for _, comparator := range comparators {
+			if p.matchPhrase(comparator.phrase...) {
+				return comparator.op, comparator.field, len(comparator.phrase)
+			}
+		}
+	}
+	return "", "", 0
+}
+
+// parseComparator consumes a comparative phrase, returning its operator and implied field
+func (p *nlParser) parseComparator() (string, string, bool) {
+	op, field, size := p.peekComparator()
+	if size == 0 {
+		return "", "", false
+	}
+	p.pos += size
+	return op, field, true
+}
+
+// parseNumber consumes a number written as digits or as words: "12", "twelve",
+// "twenty-one", "a dozen", "a hundred", "two hundred and five", "treinta y dos", "soixante-dix"
+func (p *nlParser) parseNumber() (int, bool) {
+	start := p.pos
+	if token := p.peek(); token.kind == tokenNumber {
+		value, err := strconv.Atoi(token.text)
+		if err != nil {
+			return 0, false
+		}
+		p.pos++
+		return value, true
+	}
+
+	// "a dozen", "a hundred", "a thousand"
+	if p.lex.indefinites[p.peekWord()] {
+		next := p.peekAt(1).word
+		if _, ok := p.lex.scales[next]; ok || p.lex.dozens[next] {
+			p.pos++
+		}
+	}
+
+	const (
+		partNone = iota
+		partUnit
+		partTens
+		partScale
+		partAnd
+	)
+	total, current, last := 0, 0, partNone
+	lastValue := 0
+	for {
+		word := p.peekWord()
+		value, isNumber := p.lex.numbers[word]
+		tens, isTens := p.lex.tens[word]
+		scale, isScale := p.lex.scales[word]
+		switch {
+		case isNumber && (last == partNone || last == partScale || last == partAnd || last == partTens && value < 10):
+			current += value
+			last = partUnit
+		case isNumber && p.lex.compoundNumbers && (last == partUnit && lastValue == 10 && value < 10 || last == partTens && value < 20):
+			// "dix sept", "soixante dix", "soixante et onze"
+			current += value
+			last = partUnit
+		case isTens && p.lex.compoundNumbers && last == partUnit && lastValue == 4 && tens == 20:
+			// "quatre vingt"
+			current += 80 - 4
+			last = partTens
+		case isTens && (last == partNone || last == partScale || last == partAnd):
+			current += tens
+			last = partTens
+		case isScale && last != partScale && last != partAnd:
+			if current == 0 {
+				current = 1
+			}
+			total += current * scale
+			current = 0
+			last = partScale
+		case word == p.lex.numberAnd && (last == partScale || last == partTens && p.lex.tensAnd) && p.nextIsNumberWord(1):
+			// "a hundred and five", "treinta y dos", but not "a hundred and palindromes"
+			if last == partTens {
+				last = partAnd
+				if next := p.lex.numbers[p.peekAt(1).word]; next < 10 || p.lex.compoundNumbers && next < 20 {
+					p.pos++
+					current += next
+					last = partUnit
+				}
+			} else {
+				last = partAnd
+			}
+		default:
+			if last == partNone {
+				p.pos = start
+				return 0, false
+			}
+			return total + current, true
+		}
+		lastValue = value
+		p.pos++
+	}
+}
+
+func (p *nlParser) nextIsNumberWord(offset int) bool {
+	word := p.peekAt(offset).word
+	_, isNumber := p.lex.numbers[word]
+	_, isTens := p.lex.tens[word]
+	return isNumber || isTens
+}
+
+// parseOrdinal consumes an ordinal, "third" or "3rd", and returns its zero-based position
+func (p *nlParser) parseOrdinal() (int, bool) {
+	if position, ok := p.lex.ordinals[p.peekWord()]; ok {
+		p.pos++
+		return position, true
+	}
+	token := p.peek()
+	if token.kind == tokenNumber {
+		if suffix := p.peekAt(1); p.lex.ordinalSuffixes[suffix.word] {
+			value, err := strconv.Atoi(token.text)
+			if err != nil || value < 1 || suffix.start != token.end {
+				return 0, false
+			}
+			p.pos += 2
+			return value - 1, true
+		}
+	}
+	return 0, false
+}
+
+// parseTimes consumes an occurrence count: "twice", "3 times", "at least three times"
+func (p *nlParser) parseTimes() (string, int, bool) {
+	start := p.pos
+	op := filter.OpEq
+	if comparatorOp, _, ok := p.parseComparator(); ok {
+		op = comparatorOp
+	}
+	if times, ok := p.lex.times[p.peekWord()]; ok {
+		p.pos++
+		return op, times, true
+	}
+	if value, ok := p.parseNumber(); ok && p.lex.timesUnits[p.peekWord()] {
+		p.pos++
+		return op, value, true
+	}
+	p.pos = start
+	return "", 0, false
+}
+
+// parseUnit consumes a unit word and returns the field it measures
+func (p *nlParser) parseUnit() (string, bool) {
+	token := p.peekWord()
+	switch {
+	case p.lex.lengthUnits[token]:
+		p.pos++
+		return filter.FieldLength, true
+	case p.lex.wordUnits[token]:
+		p.pos++
+		return filter.FieldWordCount, true
+	}
+	return "", false
+}
+
+// atUnitComparison reports whether a unit such as "words" starts a comparison, where it is the field
+// rather than a filler
+func (p *nlParser) atUnitComparison() bool {
+	start := p.pos
+	defer func() { p.pos = start }()
+	if _, ok := p.parseUnit(); !ok {
+		return false
+	}
+	p.skipCopulas()
+	return p.atComparator() || p.lex.between[p.peekWord()]
+}
+
+// parseBetween consumes "between X and Y" and the optional unit that follows it
+func (p *nlParser) parseBetween(field string) (filter.Node, bool, error) {
+	if !p.lex.between[p.peekWord()] {
+		return filter.Node{}, false, nil
+	}
+	p.pos++
+	low, ok := p.parseNumber()
+	if !ok || p.peekWord() != p.lex.numberAnd {
+		return filter.Node{}, false, nil
+	}
+	p.pos++
+	high, ok := p.parseNumber()
+	if !ok {
+		return filter.Node{}, false, nil
+	}
+	if unit, ok := p.parseUnit(); ok {
+		field = unit
+	}
+	if field == "" {
+		return filter.Node{}, false, nil
+	}
+	return filter.And(filter.Cond(field, filter.OpGte, low), filter.Cond(field, filter.OpLte, high)), true, nil
+}
+
+// parseComparison handles comparator-first phrases: "more than 5 words", "longer than 10", "between 3 and 5 characters"
+func (p *nlParser) parseComparison() (filter.Node, bool, error) {
+	if node, ok, err := p.parseBetween(""); ok || err != nil {
+		return node, ok, err
+	}
+
+	op, field, ok := p.parseComparator()
+	if !ok {
+		return filter.Node{}, false, nil
+	}
+	value, ok := p.parseNumber()
+	if !ok {
+		return filter.Node{}, false, nil
+	}
+	if unit, ok := p.parseUnit(); ok {
+		field = unit
+	}
+	if field == "" {
+		if p.lex.timesUnits[p.peekWord()] {
+			return filter.Node{}, false, nil
+		}
+		// a bare number most often means characters, "strings over 10"
+		node := filter.Cond(filter.FieldLength, op, value)
+		p.guesses = append(p.guesses, nlGuess{
+			chosen: node,
+			other:  filter.Cond(filter.FieldWordCount, op, value),
+			reason: fmt.Sprintf("%d read as a number of words instead of characters", value),
+		})
+		return node, true, nil
+	}
+	return filter.Cond(field, op, value), true, nil
+}
+
+// parseFieldComparison handles field-first phrases: "length > 10", "word count is at least 3", "length between 2 and 4"
+func (p *nlParser) parseFieldComparison() (filter.Node, bool, error) {
+	var field string
+	for _, candidate := range p.lex.fieldPhrases {
+		if p.matchPhrase(candidate.phrase...) {
+			field = candidate.field
+			p.pos += len(candidate.phrase)
+			break
+		}
+	}
+	// "words > 3", the unit names the field when a comparison follows it
+	if field == "" && p.atUnitComparison() {
+		field, _ = p.parseUnit()
+	}
+	if field == "" {
+		return filter.Node{}, false, nil
+	}
+
+	p.skipCopulas()
+	if node, ok, err := p.parseBetween(field); ok || err != nil {
+		return node, ok, err
+	}
+
+	op := filter.OpEq
+	if comparatorOp, _, ok := p.parseComparator(); ok {
+		op = comparatorOp
+	}
+	value, ok := p.parseNumber()
+	if !ok {
+		return filter.Node{}, false, nil
+	}
+	p.parseUnit()
+	return filter.Cond(field, op, value), true, nil
+}
+
+// parseCount handles number-first phrases: "single word", "3 words", "10 characters long"
+func (p *nlParser) parseCount() (filter.Node, bool, error) {
+	value, ok := p.parseNumber()
+	if !ok {
+		return filter.Node{}, false, nil
+	}
+	// "una sola palabra", "un seul mot"
+	if p.lex.countModifiers[p.peekWord()] {
+		p.pos++
+	}
+	field, ok := p.parseUnit()
+	if !ok {
+		return filter.Node{}, false, nil
+	}
+	p.matchAny(p.lex.longPhrases)
+	return filter.Cond(field, filter.OpEq, value), true, nil
+}
+
+// parseContains handles "contains the letter a", "with the character z", "containing 'abc'"
+func (p *nlParser) parseContains() (filter.Node, bool, error) {
+	if p.lex.containVerbs[p.peekWord()] {
+		p.pos++
+	}
+	for p.lex.definites[p.peekWord()] || p.lex.indefinites[p.peekWord()] {
+		p.pos++
+	}
+
+	if p.lex.characterNouns[p.peekWord()] {
+		p.pos++
+		token := p.peek()
+		if (token.kind != tokenWord && token.kind != tokenQuoted) || utf8.RuneCountInString(token.text) != 1 {
+			return filter.Node{}, false, nil
+		}
+		p.pos++
+		character := strings.ToLower(token.text)
+
+		// "the letter a appears at least twice", "containing the letter e 3 times"
+		countStart := p.pos
+		if p.lex.occurVerbs[p.peekWord()] {
+			p.pos++
+		}
+		if op, times, ok := p.parseTimes(); ok {
+			node := filter.Cond(filter.FieldCharacterCount, op, times)
+			node.Character = character
+			return node, true, nil
+		}
+		p.pos = countStart
+		return filter.Cond(filter.FieldValue, filter.OpContains, character), true, nil
+	}
+
+	if token := p.peek(); token.kind == tokenQuoted && token.text != "" {
+		p.pos++
+		return filter.Cond(filter.FieldValue, filter.OpContains, token.text), true, nil
+	}
+	return filter.Node{}, false, nil
+}
+
+// parseCharacterOperand consumes what a string starts or ends with: "z", "'abc'", "the letter z",
+// "the first vowel" (a), "the last vowel" (u) or "a vowel" (any of them)
+func (p *nlParser) parseCharacterOperand() ([]string, bool) {
+	if p.lex.definites[p.peekWord()] {
+		p.pos++
+	}
+	switch {
+	case p.matchAny(p.lex.firstVowel):
+		return []string{"a"}, true
+	case p.matchAny(p.lex.lastVowel):
+		return []string{"u"}, true
+	case p.matchAny(p.lex.anyVowel):
+		return nlVowels, true
+	}
+
+	// "a" is an article when another letter follows it, "starts with a z", and the letter itself otherwise
+	if p.lex.indefinites[p.peekWord()] {
+		next := p.peekAt(1)
+		if next.kind == tokenQuoted || next.kind == tokenWord && (utf8.RuneCountInString(next.text) == 1 || p.lex.characterNouns[next.word]) {
+			p.pos++
+		}
+	}
+	if p.lex.characterNouns[p.peekWord()] {
+		p.pos++
+	}
+
+	token := p.peek()
+	switch {
+	case token.kind == tokenQuoted && token.text != "":
+		p.pos++
+		return []string{token.text}, true
+	case token.kind == tokenWord && utf8.RuneCountInString(token.text) == 1:
+		p.pos++
+		return []string{token.text}, true
+	}
+	return nil, false
+}
+
+// parseAffix handles "starts with z", "beginning with the first vowel", "ending in 'ing'", "last letter is x"
+func (p *nlParser) parseAffix() (filter.Node, bool, error) {
+	var op string
+	word := p.peekWord()
+	switch {
+	case p.lex.startVerbs[word]:
+		op = filter.OpStartsWith
+	case p.lex.endVerbs[word]:
+		op = filter.OpEndsWith
+	case p.lex.lastWords[word]:
+		if !p.lex.characterNouns[p.peekAt(1).word] {
+			return filter.Node{}, false, nil
+		}
+		p.pos += 2
+		p.skipCopulas()
+		alternatives, ok := p.parseCharacterOperand()
+		if !ok {
+			return filter.Node{}, false, nil
+		}
+		return affixNode(filter.OpEndsWith, alternatives), true, nil
+	default:
+		return filter.Node{}, false, nil
+	}
+	p.pos++
+	if !p.lex.affixPrepositions[p.peekWord()] {
+		return filter.Node{}, false, nil
+	}
+	p.pos++
+
+	alternatives, ok := p.parseCharacterOperand()
+	if !ok {
+		return filter.Node{}, false, nil
+	}
+	return affixNode(op, alternatives), true, nil
+}
+
+func affixNode(op string, alternatives []string) filter.Node {
+	nodes := make([]filter.Node, len(alternatives))
+	for i, alternative := range alternatives {
+		nodes[i] = filter.Cond(filter.FieldValue, op, alternative)
+	}
+	return filter.Or(nodes...)
+}
+
+// parsePosition handles ordinal positions: "first letter is z", "third character equals 'a'"
+func (p *nlParser) parsePosition() (filter.Node, bool, error) {
+	index, ok := p.parseOrdinal()
+	if !ok {
+		return filter.Node{}, false, nil
+	}
+	if !p.lex.characterNouns[p.peekWord()] {
+		return filter.Node{}, false, nil
+	}
+	p.pos++
+	p.skipCopulas()
+
+	token := p.peek()
+	if (token.kind != tokenWord && token.kind != tokenQuoted) || utf8.RuneCountInString(token.text) != 1 {
+		return filter.Node{}, false, nil
+	}
+	p.pos++
+	node := filter.Cond(filter.FieldCharAt, filter.OpEq, strings.ToLower(token.text))
+	node.Index = &index
+	return node, true, nil
+}
+
+// parseLanguage handles language names: "french strings", "strings in spanish"
+func (p *nlParser) parseLanguage() (filter.Node, bool, error) {
+	if code, ok := p.lex.languages[p.peekWord()]; ok {
+		p.pos++
+		return filter.Cond(filter.FieldLanguage, filter.OpEq, code), true, nil
+	}
+	return filter.Node{}, false, nil
+}
+
+// parseSentiment handles "positive", "negative", "neutral" and profanity words
+func (p *nlParser) parseSentiment() (filter.Node, bool, error) {
+	switch p.lex.sentiments[p.peekWord()] {
+	case nlPositive:
+		p.pos++
+		return filter.Cond(filter.FieldSentimentScore, filter.OpGte, 0.05), true, nil
+	case nlNegative:
+		p.pos++
+		return filter.Cond(filter.FieldSentimentScore, filter.OpLte, -0.05), true, nil
+	case nlNeutral:
+		p.pos++
+		return filter.And(
+			filter.Cond(filter.FieldSentimentScore, filter.OpGt, -0.05),
+			filter.Cond(filter.FieldSentimentScore, filter.OpLt, 0.05),
+		), true, nil
+	case nlProfane:
+		p.pos++
+		return filter.Cond(filter.FieldHasProfanity, filter.OpEq, true), true, nil
+	}
+	return filter.Node{}, false, nil
+}
+
+// nlpFiltersFromNode flattens an and-only filter tree into the legacy NLPFilters shape,
+// returning ok=false when the tree uses or/not or conditions NLPFilters can't express
+func nlpFiltersFromNode(node filter.Node) (NLPFilters, bool) {
+	filters := NLPFilters{}
+	conditions, ok := node.Conditions()
+	if !ok {
+		return filters, false
+	}
+
+	for _, condition := range conditions {
+		switch value := condition.Value.(type) {
+		case bool:
+			if condition.Op != filter.OpEq {
+				return filters, false
+			}
+			switch condition.Field {
+			case filter.FieldIsPalindrome:
+				filters.IsPalindrome = &value
+			case filter.FieldHasProfanity:
+				filters.HasProfanity = &value
+			}
+
+		case int:
+			var minimum, maximum **int
+			switch condition.Field {
+			case filter.FieldLength:
+				minimum, maximum = &filters.MinLength, &filters.MaxLength
+			case filter.FieldWordCount:
+				if condition.Op == filter.OpEq {
+					filters.WordCount = &value
+					continue
+				}
+				minimum, maximum = &filters.MinWordCount, &filters.MaxWordCount
+			default:
+				return filters, false
+			}
+			switch condition.Op {
+			case filter.OpEq:
+				*minimum, *maximum = &value, &value
+			case filter.OpGte:
+				*minimum = &value
+			case filter.OpGt:
+				bound := value + 1
+				*minimum = &bound
+			case filter.OpLte:
+				*maximum = &value
+			case filter.OpLt:
+				bound := value - 1
+				*maximum = &bound
+			default:
+				return filters, false
+			}
+
+		case float64:
+			switch condition.Op {
+			case filter.OpGte, filter.OpGt:
+				filters.SentimentMin = &value
+			case filter.OpLte, filter.OpLt:
+				filters.SentimentMax = &value
+			default:
+				return filters, false
+			}
+
+		case string:
+			switch {
+			case condition.Field == filter.FieldValue && condition.Op == filter.OpStartsWith:
+				filters.StartsWith = &value
+			case condition.Field == filter.FieldValue && condition.Op == filter.OpEndsWith:
+				filters.EndsWith = &value
+			case condition.Field == filter.FieldCharAt && condition.Op == filter.OpEq:
+				charAt := fmt.Sprintf("%d:%s", *condition.Index, value)
+				filters.CharAt = &charAt
+			case condition.Field == filter.FieldLanguage && condition.Op == filter.OpEq:
+				filters.Language = &value
+			case condition.Field == filter.FieldValue && condition.Op == filter.OpContains:
+				if utf8.RuneCountInString(value) == 1 {
+					filters.ContainsCharacter = &value
+				} else {
+					filters.ContainsText = &value
+				}
+			default:
+				return filters, false
+			}
+		}
+	}
+	return filters, true
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/filter"
+)
+
+func TestParseNaturalLanguageQuery(t *testing.T) {
+	palindrome := filter.Cond(filter.FieldIsPalindrome, filter.OpEq, true)
+	singleWord := filter.Cond(filter.FieldWordCount, filter.OpEq, 1)
+	longest := []filter.Sort{{Field: filter.FieldLength, Direction: filter.Desc}}
+
+	tests := []struct {
+		locale string
+		query  string
+		want   filter.Query
+	}{
+		{"en", "words > 3", filter.Query{Where: filter.Cond(filter.FieldWordCount, filter.OpGt, 3)}},
+		{"en", "words greater than 5", filter.Query{Where: filter.Cond(filter.FieldWordCount, filter.OpGt, 5)}},
+		{"en", "words between 2 and 4", filter.Query{Where: filter.And(filter.Cond(filter.FieldWordCount, filter.OpGte, 2), filter.Cond(filter.FieldWordCount, filter.OpLte, 4))}},
+		{"en", "length between 2 and 4", filter.Query{Where: filter.And(filter.Cond(filter.FieldLength, filter.OpGte, 2), filter.Cond(filter.FieldLength, filter.OpLte, 4))}},
+		{"en", "strings longer than 10 characters", filter.Query{Where: filter.Cond(filter.FieldLength, filter.OpGt, 10)}},
+		{"en", "single word palindromic strings", filter.Query{Where: filter.And(singleWord, palindrome)}},
+		{"en", "strings containing the letter z", filter.Query{Where: filter.Cond(filter.FieldValue, filter.OpContains, "z")}},
+		{"en", "palindromes that start with a", filter.Query{Where: filter.And(palindrome, filter.Cond(filter.FieldValue, filter.OpStartsWith, "a"))}},
+		{"en", "not palindromes", filter.Query{Where: filter.Cond(filter.FieldIsPalindrome, filter.OpEq, false)}},
+		{"en", "strings with more than 3 words or palindromes", filter.Query{Where: filter.Or(filter.Cond(filter.FieldWordCount, filter.OpGt, 3), palindrome)}},
+		{"en", "the 5 longest palindromes", filter.Query{Where: palindrome, Sort: longest, Limit: 5}},
+		{"es", "palabras > 3", filter.Query{Where: filter.Cond(filter.FieldWordCount, filter.OpGt, 3)}},
+		{"es", "cadenas con más de 10 caracteres", filter.Query{Where: filter.Cond(filter.FieldLength, filter.OpGt, 10)}},
+		{"es", "palíndromos de una sola palabra", filter.Query{Where: filter.And(palindrome, singleWord)}},
+		{"es", "cadenas que contienen la letra z", filter.Query{Where: filter.Cond(filter.FieldValue, filter.OpContains, "z")}},
+		{"es", "los 5 palíndromos más largos", filter.Query{Where: palindrome, Sort: longest, Limit: 5}},
+		{"fr", "mots > 3", filter.Query{Where: filter.Cond(filter.FieldWordCount, filter.OpGt, 3)}},
+		{"fr", "chaînes de plus de 10 caractères", filter.Query{Where: filter.Cond(filter.FieldLength, filter.OpGt, 10)}},
+		{"fr", "palindromes d'un seul mot", filter.Query{Where: filter.And(palindrome, singleWord)}},
+		{"fr", "chaînes contenant la lettre z", filter.Query{Where: filter.Cond(filter.FieldValue, filter.OpContains, "z")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale+"/"+tt.query, func(t *testing.T) {
+			got, err := parseNaturalLanguageQuery(tt.query, nlLexicons[tt.locale])
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parse(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+			if err := got.Validate(); err != nil {
+				t.Errorf("parsed query is invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseNaturalLanguageQueryRejects(t *testing.T) {
+	_, err := parseNaturalLanguageQuery("gibberish zzz", nlLexicons["en"])
+	if err == nil || !strings.Contains(err.Error(), "could not parse query") {
+		t.Errorf("error = %v, want could not parse query", err)
+	}
+
+	// numbers the parser reads but the database can't compare are caught by validation
+	parsed, err := parseNaturalLanguageQuery("words > 99999999999", nlLexicons["en"])
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if err := parsed.Validate(); err == nil {
+		t.Error("Validate accepted a word count beyond 32 bits")
+	}
+}
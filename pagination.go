@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"github.com/HamstimusPrime/text-analyzer-api/internal/filter"
+)
+
+const maxPageSize = 1000
+
+// pageRequest is the page_size and cursor query parameters shared by GET /strings and the
+// natural-language endpoint. A zero size returns every remaining result
+type pageRequest struct {
+	size   int
+	cursor *filter.Cursor
+}
+
+// parsePageParams reads page_size and cursor, the cursor must come from a previous page of the same query
+func parsePageParams(r *http.Request, query filter.Query) (pageRequest, error) {
+	var page pageRequest
+	if value := r.URL.Query().Get("page_size"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 1 || size > maxPageSize {
+			return page, invalidParameter(codeInvalidParameter, "page_size", fmt.Sprintf("Invalid page_size parameter: must be between 1 and %d", maxPageSize))
+		}
+		page.size = size
+	}
+	if token := r.URL.Query().Get("cursor"); token != "" {
+		cursor, err := filter.DecodeCursor(query, token)
+		if err != nil {
+			return page, invalidParameter(codeInvalidCursor, "cursor", "Invalid cursor parameter: it must be the next_cursor of a previous page of the same query")
+		}
+		page.cursor = &cursor
+	}
+	return page, nil
+}
+
+// cursorValues returns the text's values for each ordering key, as stored in a cursor
+func cursorValues(text database.Text, keys []filter.Sort) []any {
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		switch key.Field {
+		case filter.FieldLength:
+			values[i] = text.Length
+		case filter.FieldWordCount:
+			values[i] = text.WordCount
+		case filter.FieldValue:
+			values[i] = text.Value
+		case filter.FieldSentimentScore:
+			values[i] = text.SentimentScore
+		case filter.FieldCreatedAt:
+			values[i] = text.CreatedAt.Format(time.RFC3339Nano)
+		default:
+			values[i] = text.ID.String()
+		}
+	}
+	return values
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"github.com/HamstimusPrime/text-analyzer-api/internal/filter"
+	"github.com/google/uuid"
+)
+
+func getPage(t *testing.T, server *httptest.Server, params url.Values) (*http.Response, FilteredTextsResponse) {
+	t.Helper()
+	res, err := http.Get(server.URL + "/strings?" + params.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var page FilteredTextsResponse
+	if res.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res, page
+}
+
+func TestPagesFollowTheCursor(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	texts := make([]database.Text, 3)
+	for i := range texts {
+		texts[i] = database.Text{ID: uuid.New(), Value: "level", Length: 5, IsPalindrome: true, WordCount: 1, CreatedAt: created.Add(-time.Duration(i) * time.Hour)}
+	}
+	var pages [][]database.Text
+	cfg, fake := newFakeDBConfig(t, map[string]fakeQuery{
+		"SearchTexts": func([]driver.Value) ([][]driver.Value, error) {
+			var rows [][]driver.Value
+			for _, text := range pages[0] {
+				rows = append(rows, textRow(text))
+			}
+			pages = pages[1:]
+			return rows, nil
+		},
+		"GetCharacterCountsByID": answer(),
+	})
+	server := httptest.NewServer(newRouter(cfg))
+	defer server.Close()
+
+	// the extra row tells there is another page
+	pages = [][]database.Text{texts, texts[2:]}
+	params := url.Values{"is_palindrome": {"true"}, "page_size": {"2"}}
+	res, page := getPage(t, server, params)
+	if res.StatusCode != http.StatusOK || page.Count != 2 || page.NextCursor == "" {
+		t.Fatalf("first page: status %d, %d texts, cursor %q, want 2 texts and a cursor", res.StatusCode, page.Count, page.NextCursor)
+	}
+
+	params.Set("cursor", page.NextCursor)
+	res, page = getPage(t, server, params)
+	if res.StatusCode != http.StatusOK || page.Count != 1 || page.NextCursor != "" {
+		t.Fatalf("last page: status %d, %d texts, cursor %q, want 1 text and no cursor", res.StatusCode, page.Count, page.NextCursor)
+	}
+
+	// the second query starts after the last text of the first page
+	searches := fake.calledWith("SearchTexts")
+	want := []driver.Value{true, texts[1].CreatedAt, texts[1].ID.String()}
+	if got := searches[1]; len(got) != len(want) || got[0] != want[0] || !got[1].(time.Time).Equal(texts[1].CreatedAt) || got[2] != want[2] {
+		t.Errorf("second search args = %v, want %v", got, want)
+	}
+}
+
+func TestBadCursorsAreRejected(t *testing.T) {
+	// no query is registered, a cursor that got as far as the database would fail with 500
+	cfg, _ := newFakeDBConfig(t, nil)
+	server := httptest.NewServer(newRouter(cfg))
+	defer server.Close()
+
+	query := filter.Query{Where: filter.And(filter.Cond(filter.FieldIsPalindrome, filter.OpEq, true))}
+	raw := func(values ...any) string {
+		cursor := filter.NewCursor(query, values, 2)
+		data, err := json.Marshal(cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	tests := []struct {
+		name   string
+		params url.Values
+	}{
+		{"garbage", url.Values{"is_palindrome": {"true"}, "cursor": {"garbage"}}},
+		{"object value", url.Values{"is_palindrome": {"true"}, "cursor": {raw(map[string]any{"$gt": 1}, uuid.NewString())}}},
+		{"array value", url.Values{"is_palindrome": {"true"}, "cursor": {raw("2024-01-01T00:00:00Z", []string{"a"})}}},
+		{"cursor of another filter", url.Values{"is_palindrome": {"false"}, "cursor": {raw("2024-01-01T00:00:00Z", uuid.NewString())}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := http.Get(server.URL + "/strings?" + tt.params.Encode())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", res.StatusCode, http.StatusBadRequest)
+			}
+			if problem := decodeProblem(t, res); problem["code"] != codeInvalidCursor {
+				t.Errorf("code = %v, want %s", problem["code"], codeInvalidCursor)
+			}
+		})
+	}
+
+	// the same cursor is accepted with its own filter
+	res, err := http.Get(server.URL + "/strings?" + url.Values{"is_palindrome": {"true"}, "cursor": {raw("2024-01-01T00:00:00Z", uuid.NewString())}}.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if problem := decodeProblem(t, res); problem["code"] == codeInvalidCursor {
+		t.Errorf("the cursor of the same filter was rejected: %v", problem)
+	}
+}
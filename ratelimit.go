@@ -0,0 +1,258 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/config"
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+)
+
+// route classes, each is limited by its own bucket per client
+const (
+	routeClassRead   = "read"
+	routeClassWrite  = "write"
+	routeClassSearch = "search"
+	// classAuthFailure isn't a route class, it limits the invalid keys presented from an IP address
+	classAuthFailure = "auth_failure"
+)
+
+// sweepInterval is how often buckets that have refilled are dropped, a full bucket is the same as a new one
+const sweepInterval = time.Minute
+
+// rateLimiter keeps a token bucket per route class and client. A nil limiter allows everything
+type rateLimiter struct {
+	mu        sync.Mutex
+	limits    map[string]config.Bucket
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type tokenBucket struct {
+	limit   config.Bucket
+	tokens  float64
+	updated time.Time
+}
+
+// rateDecision is the outcome of taking a token, reported to the client in X-RateLimit-* headers
+type rateDecision struct {
+	allowed   bool
+	limit     int
+	remaining int
+	// reset is the time until the bucket is full again, retryAfter until the next token when refused
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+func newRateLimiter(settings config.RateLimit) *rateLimiter {
+	if !settings.Enabled {
+		return nil
+	}
+	return &rateLimiter{
+		limits: map[string]config.Bucket{
+			routeClassRead:   settings.Read,
+			routeClassWrite:  settings.Write,
+			routeClassSearch: settings.Search,
+			classAuthFailure: settings.AuthFailures,
+		},
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// take spends a token from the client's bucket for class, if it has one
+func (l *rateLimiter) take(class, client string) rateDecision {
+	return l.decide(class, client, true)
+}
+
+// peek reports whether take would allow a request, without spending a token
+func (l *rateLimiter) peek(class, client string) rateDecision {
+	return l.decide(class, client, false)
+}
+
+func (l *rateLimiter) decide(class, client string, spend bool) rateDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+	limit := l.limits[class]
+	key := class + " " + client
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{limit: limit, tokens: float64(limit.Burst), updated: now}
+		l.buckets[key] = bucket
+	}
+	bucket.refill(now)
+
+	decision := rateDecision{limit: limit.Burst}
+	if bucket.tokens >= 1 {
+		if spend {
+			bucket.tokens--
+		}
+		decision.allowed = true
+	} else {
+		decision.retryAfter = bucket.timeToFill(1)
+	}
+	decision.remaining = int(bucket.tokens)
+	decision.reset = bucket.timeToFill(float64(limit.Burst))
+	return decision
+}
+
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		bucket.refill(now)
+		if bucket.tokens >= float64(bucket.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.ratePerSecond())
+		b.updated = now
+	}
+}
+
+func (b *tokenBucket) ratePerSecond() float64 {
+	return float64(b.limit.PerMinute) / 60
+}
+
+// timeToFill is how long until the bucket holds tokens again
+func (b *tokenBucket) timeToFill(tokens float64) time.Duration {
+	if b.tokens >= tokens {
+		return 0
+	}
+	return time.Duration((tokens - b.tokens) / b.ratePerSecond() * float64(time.Second))
+}
+
+// route serves handler to callers with scope, within the rate limit of class
+func (cfg *apiConfig) route(class, scope string, handler http.HandlerFunc) http.Handler {
+	return cfg.limitRate(class, cfg.requireScope(scope, handler))
+}
+
+// limitRate refuses requests with 429 once the client has used up its bucket for class
+func (cfg *apiConfig) limitRate(class string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.RateLimiter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		decision := cfg.RateLimiter.take(class, cfg.clientID(r))
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(decision.limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(decision.remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.reset)))
+		if !decision.allowed {
+			cfg.refuseRateLimited(w, r, class, decision, fmt.Sprintf("Too many %s requests", class))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// refuseRateLimited answers 429 with the time until the bucket for class has a token again
+func (cfg *apiConfig) refuseRateLimited(w http.ResponseWriter, r *http.Request, class string, decision rateDecision, detail string) {
+	retryAfter := ceilSeconds(decision.retryAfter)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	cfg.Metrics.rateLimited.WithLabelValues(class).Inc()
+	respondWithError(w, r, newAPIError(http.StatusTooManyRequests, codeRateLimited, fmt.Sprintf("%s, retry in %d seconds", detail, retryAfter)).
+		with("route_class", class).
+		with("retry_after", retryAfter))
+}
+
+// limitUnmatched spends read tokens on requests no route matches, so probing for endpoints is limited too
+func (cfg *apiConfig) limitUnmatched(mux *http.ServeMux) http.Handler {
+	limited := cfg.limitRate(routeClassRead, mux)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern == "" {
+			limited.ServeHTTP(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// authFailureClient is the bucket invalid keys are counted in, by IP since the key identifies no one
+func (cfg *apiConfig) authFailureClient(r *http.Request) string {
+	return "ip:" + clientIP(r, cfg.TrustForwardedFor)
+}
+
+// clientID identifies the caller for rate limits and quotas, by API key when there is one and by IP otherwise
+func (cfg *apiConfig) clientID(r *http.Request) string {
+	if identity, ok := apiKeyFrom(r.Context()); ok {
+		if identity.ID.Valid {
+			return "key:" + identity.ID.UUID.String()
+		}
+		return "key:" + identity.Prefix
+	}
+	return "ip:" + clientIP(r, cfg.TrustForwardedFor)
+}
+
+// clientIP is the address the request came from, or the first one in X-Forwarded-For when the proxy is trusted
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// consumeTextQuota counts a new text against the client's daily quota within the transaction storing it,
+// so a text that isn't stored doesn't count. Once the quota is used up it returns a 429 error
+func (cfg *apiConfig) consumeTextQuota(w http.ResponseWriter, r *http.Request, q *database.Queries) error {
+	if cfg.DailyTextQuota <= 0 {
+		return nil
+	}
+	used, err := q.ConsumeTextQuota(r.Context(), database.ConsumeTextQuotaParams{
+		Client:     cfg.clientID(r),
+		DailyLimit: int32(cfg.DailyTextQuota),
+	})
+	reset := ceilSeconds(untilNextUTCDay(time.Now()))
+	w.Header().Set("X-Quota-Limit", strconv.Itoa(cfg.DailyTextQuota))
+	w.Header().Set("X-Quota-Reset", strconv.Itoa(reset))
+	if err == sql.ErrNoRows {
+		// the upsert only counts while the client is under its quota
+		w.Header().Set("X-Quota-Remaining", "0")
+		w.Header().Set("Retry-After", strconv.Itoa(reset))
+		cfg.Metrics.textsRejected.WithLabelValues("quota").Inc()
+		return newAPIError(http.StatusTooManyRequests, codeQuotaExceeded, fmt.Sprintf("Daily quota of %d texts is used up", cfg.DailyTextQuota)).
+			with("quota", cfg.DailyTextQuota).
+			with("retry_after", reset)
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("X-Quota-Remaining", strconv.Itoa(cfg.DailyTextQuota-int(used)))
+	return nil
+}
+
+func untilNextUTCDay(now time.Time) time.Duration {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC).Sub(now)
+}
+
+// ceilSeconds rounds up, so a client waiting the advertised time isn't refused again
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/config"
+	"github.com/google/uuid"
+)
+
+// newTestLimiter limits every class to bucket, on a clock the test moves with the returned func
+func newTestLimiter(bucket config.Bucket) (*rateLimiter, func(time.Duration)) {
+	limiter := newRateLimiter(config.RateLimit{Enabled: true, Read: bucket, Write: bucket, Search: bucket, AuthFailures: bucket})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+	return limiter, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestNewRateLimiterDisabled(t *testing.T) {
+	if limiter := newRateLimiter(config.RateLimit{Enabled: false}); limiter != nil {
+		t.Errorf("newRateLimiter = %v, want nil when disabled", limiter)
+	}
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	limiter, advance := newTestLimiter(config.Bucket{PerMinute: 60, Burst: 3})
+
+	steps := []struct {
+		name          string
+		advance       time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+		wantReset     time.Duration
+	}{
+		{"first", 0, true, 2, 0, time.Second},
+		{"second", 0, true, 1, 0, 2 * time.Second},
+		{"third", 0, true, 0, 0, 3 * time.Second},
+		{"empty", 0, false, 0, time.Second, 3 * time.Second},
+		{"part of a token", 500 * time.Millisecond, false, 0, 500 * time.Millisecond, 2500 * time.Millisecond},
+		{"refilled one", 500 * time.Millisecond, true, 0, 0, 3 * time.Second},
+		{"never above the burst", time.Hour, true, 2, 0, time.Second},
+	}
+	for _, step := range steps {
+		advance(step.advance)
+		decision := limiter.take(routeClassRead, "ip:192.0.2.1")
+		if decision.allowed != step.wantAllowed || decision.remaining != step.wantRemaining ||
+			decision.retryAfter != step.wantRetry || decision.reset != step.wantReset || decision.limit != 3 {
+			t.Errorf("%s: decision = %+v, want allowed %v, remaining %d, retry after %v, reset %v, limit 3",
+				step.name, decision, step.wantAllowed, step.wantRemaining, step.wantRetry, step.wantReset)
+		}
+	}
+}
+
+func TestBucketsAreKeptPerClassAndClient(t *testing.T) {
+	limiter, _ := newTestLimiter(config.Bucket{PerMinute: 1, Burst: 1})
+
+	if !limiter.take(routeClassWrite, "ip:192.0.2.1").allowed {
+		t.Fatal("first write was refused")
+	}
+	if limiter.take(routeClassWrite, "ip:192.0.2.1").allowed {
+		t.Error("second write was allowed")
+	}
+	if !limiter.take(routeClassRead, "ip:192.0.2.1").allowed {
+		t.Error("read shares the write bucket")
+	}
+	if !limiter.take(routeClassWrite, "ip:192.0.2.2").allowed {
+		t.Error("another client shares the bucket")
+	}
+	// peek doesn't spend the token it reports
+	for i := 0; i < 2; i++ {
+		if !limiter.peek(routeClassSearch, "ip:192.0.2.1").allowed {
+			t.Fatalf("peek %d was refused", i)
+		}
+	}
+}
+
+func TestFullBucketsAreSwept(t *testing.T) {
+	limiter, advance := newTestLimiter(config.Bucket{PerMinute: 60, Burst: 10})
+	limiter.take(routeClassRead, "ip:192.0.2.1")
+	advance(sweepInterval)
+	limiter.take(routeClassRead, "ip:192.0.2.2")
+	if _, ok := limiter.buckets[routeClassRead+" ip:192.0.2.1"]; ok {
+		t.Error("refilled bucket was kept")
+	}
+	if len(limiter.buckets) != 1 {
+		t.Errorf("buckets = %d, want 1", len(limiter.buckets))
+	}
+}
+
+func TestLimitRateHeaders(t *testing.T) {
+	cfg := newTestConfig()
+	limiter, advance := newTestLimiter(config.Bucket{PerMinute: 30, Burst: 2})
+	cfg.RateLimiter = limiter
+	handler := cfg.limitRate(routeClassSearch, http.HandlerFunc(okHandler))
+
+	request := func() *http.Response {
+		r := httptest.NewRequest(http.MethodPost, "/strings/search", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	tests := []struct {
+		wantStatus    int
+		wantRemaining string
+		wantReset     string
+		wantRetry     string
+	}{
+		{http.StatusOK, "1", "2", ""},
+		{http.StatusOK, "0", "4", ""},
+		{http.StatusTooManyRequests, "0", "4", "2"},
+	}
+	for i, tt := range tests {
+		res := request()
+		if res.StatusCode != tt.wantStatus {
+			t.Fatalf("request %d: status = %d, want %d", i, res.StatusCode, tt.wantStatus)
+		}
+		headers := map[string]string{
+			"X-RateLimit-Limit":     "2",
+			"X-RateLimit-Remaining": tt.wantRemaining,
+			"X-RateLimit-Reset":     tt.wantReset,
+			"Retry-After":           tt.wantRetry,
+		}
+		for name, want := range headers {
+			if got := res.Header.Get(name); got != want {
+				t.Errorf("request %d: %s = %q, want %q", i, name, got, want)
+			}
+		}
+		if res.StatusCode == http.StatusTooManyRequests {
+			problem := decodeProblem(t, res)
+			if problem["code"] != codeRateLimited || problem["route_class"] != routeClassSearch || problem["retry_after"] != float64(2) {
+				t.Errorf("problem = %v, want %s for %s with retry_after 2", problem, codeRateLimited, routeClassSearch)
+			}
+		}
+	}
+
+	// waiting the advertised time is enough
+	advance(2 * time.Second)
+	if res := request(); res.StatusCode != http.StatusOK {
+		t.Errorf("after Retry-After: status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+}
+
+func TestUnmatchedRoutesAreLimited(t *testing.T) {
+	cfg := newTestConfig()
+	limiter, _ := newTestLimiter(config.Bucket{PerMinute: 1, Burst: 2})
+	cfg.RateLimiter = limiter
+	server := httptest.NewServer(newRouter(cfg))
+	defer server.Close()
+
+	want := []int{http.StatusNotFound, http.StatusNotFound, http.StatusTooManyRequests}
+	for i, status := range want {
+		res, err := http.Get(server.URL + "/admin/.env")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != status {
+			t.Errorf("probe %d: status = %d, want %d", i, res.StatusCode, status)
+		}
+	}
+}
+
+func TestClientID(t *testing.T) {
+	keyID := uuid.New()
+	tests := []struct {
+		name      string
+		trust     bool
+		forwarded string
+		identity  *apiKeyIdentity
+		want      string
+	}{
+		{"remote address", false, "", nil, "ip:192.0.2.1"},
+		{"forwarded for is ignored by default", false, "203.0.113.9", nil, "ip:192.0.2.1"},
+		{"trusted forwarded for", true, "203.0.113.9, 10.0.0.1", nil, "ip:203.0.113.9"},
+		{"stored key", false, "", &apiKeyIdentity{ID: uuid.NullUUID{UUID: keyID, Valid: true}, Prefix: "tak_abcdefgh"}, "key:" + keyID.String()},
+		{"config admin key", false, "", &apiKeyIdentity{Prefix: "config"}, "key:config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &apiConfig{TrustForwardedFor: tt.trust}
+			r := httptest.NewRequest(http.MethodGet, "/strings", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.identity != nil {
+				r = r.WithContext(context.WithValue(r.Context(), apiKeyKey, tt.identity))
+			}
+			if got := cfg.clientID(r); got != tt.want {
+				t.Errorf("clientID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidKeysAreLimitedByIP(t *testing.T) {
+	cfg, _ := newAuthConfig(t, nil)
+	settings := config.Default().RateLimit
+	settings.Enabled = true
+	settings.AuthFailures = config.Bucket{PerMinute: 6, Burst: 2}
+	cfg.RateLimiter = newRateLimiter(settings)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg.RateLimiter.now = func() time.Time { return now }
+	handler := cfg.authenticate(cfg.requireScope(scopeRead, okHandler))
+
+	request := func(key, remoteAddr string) *http.Response {
+		r := httptest.NewRequest(http.MethodGet, "/strings", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	for i := 0; i < 2; i++ {
+		if res := request("tak_guess", "192.0.2.1:1234"); res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("guess %d: status = %d, want %d", i, res.StatusCode, http.StatusUnauthorized)
+		}
+	}
+	// once the bucket is empty even a valid key from the address is refused before it is looked up
+	res := request(testAdminKey, "192.0.2.1:1234")
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", res.StatusCode, http.StatusTooManyRequests)
+	}
+	if retryAfter := res.Header.Get("Retry-After"); retryAfter != "10" {
+		t.Errorf("Retry-After = %q, want 10", retryAfter)
+	}
+	if problem := decodeProblem(t, res); problem["route_class"] != classAuthFailure {
+		t.Errorf("route_class = %v, want %s", problem["route_class"], classAuthFailure)
+	}
+
+	// other addresses and valid keys don't spend the bucket
+	if res := request(testAdminKey, "198.51.100.7:1234"); res.StatusCode != http.StatusOK {
+		t.Errorf("other address: status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	now = now.Add(10 * time.Second)
+	for i := 0; i < 3; i++ {
+		if res := request(testAdminKey, "192.0.2.1:1234"); res.StatusCode != http.StatusOK {
+			t.Fatalf("after refill, request %d: status = %d, want %d", i, res.StatusCode, http.StatusOK)
+		}
+	}
+}
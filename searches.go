@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"github.com/HamstimusPrime/text-analyzer-api/internal/filter"
+)
+
+// kinds of saved search: GET /strings filters, or a natural-language query
+const (
+	savedSearchFilters         = "filters"
+	savedSearchNaturalLanguage = "natural_language"
+)
+
+// names are used in URLs, so they're kept to letters, digits, '-' and '_'
+var savedSearchName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// savedSearchParams validates a saved search definition and resolves it to the filter tree it runs
+func (cfg *apiConfig) savedSearchParams(r *http.Request, reqBody SavedSearchRequest) (database.CreateSavedSearchParams, error) {
+	params := database.CreateSavedSearchParams{
+		Name:          reqBody.Name,
+		Filters:       json.RawMessage("{}"),
+		ParsedFilters: json.RawMessage("{}"),
+	}
+	if !savedSearchName.MatchString(reqBody.Name) {
+		return params, newAPIError(http.StatusBadRequest, codeInvalidSavedSearch, `Invalid "name": must be 1 to 64 letters, digits, '-' or '_'`).
+			withField("name", "must be 1 to 64 letters, digits, '-' or '_'")
+	}
+	if (len(reqBody.Filters) > 0) == (reqBody.Query != "") {
+		return params, newAPIError(http.StatusBadRequest, codeInvalidSavedSearch, `Invalid saved search: set exactly one of "filters" or "query"`)
+	}
+
+	var query filter.Query
+	if len(reqBody.Filters) > 0 {
+		values := url.Values{}
+		for key, value := range reqBody.Filters {
+			if _, ok := cfg.QueryFilters[key]; !ok || key == "page_size" || key == "cursor" {
+				message := "must be one of " + strings.Join(savedSearchFilterNames(cfg.QueryFilters), ", ")
+				return params, newAPIError(http.StatusBadRequest, codeInvalidFilter, fmt.Sprintf("Invalid filter %q: %s", key, message)).
+					withField("filters."+key, message)
+			}
+			values.Set(key, value)
+		}
+		conditions, _, err := parseFilterParams(values)
+		if err != nil {
+			return params, err
+		}
+		query = filter.Query{Where: filter.And(conditions...)}
+		params.Kind = savedSearchFilters
+		params.Filters, err = json.Marshal(reqBody.Filters)
+		if err != nil {
+			return params, err
+		}
+	} else {
+		lex, err := negotiateLocale(r)
+		if reqBody.Lang != "" {
+			var ok bool
+			if lex, ok = nlLexicons[primaryLanguage(reqBody.Lang)]; !ok {
+				return params, newAPIError(http.StatusBadRequest, codeUnsupportedLocale, `Unsupported "lang": must be one of `+strings.Join(supportedLocales(), ", ")).
+					withField("lang", "must be one of "+strings.Join(supportedLocales(), ", "))
+			}
+		} else if err != nil {
+			return params, err
+		}
+		query, err = cfg.Interpreter.Interpret(reqBody.Query, lex)
+		if err != nil {
+			return params, newAPIError(http.StatusBadRequest, codeUnparseableQuery, fmt.Sprintf("Could not understand query: %s", err.Error())).
+				withField("query", err.Error())
+		}
+		if conflicts := filter.Conflicts(query.Where); len(conflicts) > 0 {
+			return params, conflictingConstraints(conflicts)
+		}
+		params.Kind = savedSearchNaturalLanguage
+		params.NaturalLanguageQuery = reqBody.Query
+		params.Locale = lex.locale
+		if filters, ok := nlpFiltersFromNode(query.Where); ok {
+			params.ParsedFilters, err = json.Marshal(filters)
+			if err != nil {
+				return params, err
+			}
+		}
+	}
+
+	var err error
+	params.Query, err = json.Marshal(query)
+	return params, err
+}
+
+// savedSearchFilterNames lists the GET /strings parameters a saved search can store
+func savedSearchFilterNames(queryFilters map[string]string) []string {
+	names := []string{}
+	for name := range queryFilters {
+		if name != "page_size" && name != "cursor" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func buildSavedSearchResponse(search database.SavedSearch) SavedSearchResponse {
+	response := SavedSearchResponse{
+		ID:        search.ID,
+		Name:      search.Name,
+		Kind:      search.Kind,
+		Query:     search.NaturalLanguageQuery,
+		Locale:    search.Locale,
+		CreatedAt: search.CreatedAt,
+		UpdatedAt: search.UpdatedAt,
+	}
+	if search.Kind == savedSearchFilters {
+		json.Unmarshal(search.Filters, &response.Filters)
+	} else {
+		var parsedFilters NLPFilters
+		if err := json.Unmarshal(search.ParsedFilters, &parsedFilters); err == nil {
+			response.ParsedFilters = parsedFilters.toMap()
+		}
+	}
+	json.Unmarshal(search.Query, &response.Search)
+	if search.LastRunAt.Valid {
+		response.LastRunAt = &search.LastRunAt.Time
+	}
+	if search.LastResultCount.Valid {
+		count := int(search.LastResultCount.Int32)
+		response.LastResultCount = &count
+	}
+	return response
+}
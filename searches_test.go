@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"github.com/google/uuid"
+)
+
+// savedSearchStore keeps the saved_searches table for fakeDB
+type savedSearchStore struct {
+	mu       sync.Mutex
+	searches map[string]database.SavedSearch
+}
+
+func savedSearchRow(search database.SavedSearch) []driver.Value {
+	var lastRunAt, lastResultCount driver.Value
+	if search.LastRunAt.Valid {
+		lastRunAt = search.LastRunAt.Time
+	}
+	if search.LastResultCount.Valid {
+		lastResultCount = int64(search.LastResultCount.Int32)
+	}
+	return []driver.Value{
+		search.ID.String(), search.Name, search.Kind, []byte(search.Filters), search.NaturalLanguageQuery, search.Locale,
+		[]byte(search.ParsedFilters), []byte(search.Query), search.CreatedAt, search.UpdatedAt, lastRunAt, lastResultCount,
+	}
+}
+
+// queries answers the saved search queries, create and update take their columns in the same order
+func (s *savedSearchStore) queries() map[string]fakeQuery {
+	store := func(args []driver.Value, search database.SavedSearch) database.SavedSearch {
+		search.Name, search.Kind = args[0].(string), args[1].(string)
+		search.Filters, search.ParsedFilters, search.Query = args[2].([]byte), args[5].([]byte), args[6].([]byte)
+		search.NaturalLanguageQuery, search.Locale = args[3].(string), args[4].(string)
+		search.UpdatedAt = time.Now()
+		s.searches[search.Name] = search
+		return search
+	}
+	return map[string]fakeQuery{
+		"GetSavedSearch": func(args []driver.Value) ([][]driver.Value, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if search, ok := s.searches[args[0].(string)]; ok {
+				return [][]driver.Value{savedSearchRow(search)}, nil
+			}
+			return nil, nil
+		},
+		"ListSavedSearches": func([]driver.Value) ([][]driver.Value, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			var rows [][]driver.Value
+			for _, search := range s.searches {
+				rows = append(rows, savedSearchRow(search))
+			}
+			sort.Slice(rows, func(i, j int) bool { return rows[i][1].(string) < rows[j][1].(string) })
+			return rows, nil
+		},
+		"CreateSavedSearch": func(args []driver.Value) ([][]driver.Value, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			search := store(args, database.SavedSearch{ID: uuid.New(), CreatedAt: time.Now()})
+			return [][]driver.Value{savedSearchRow(search)}, nil
+		},
+		"UpdateSavedSearch": func(args []driver.Value) ([][]driver.Value, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			existing, ok := s.searches[args[0].(string)]
+			if !ok {
+				return nil, nil
+			}
+			return [][]driver.Value{savedSearchRow(store(args, existing))}, nil
+		},
+		"DeleteSavedSearch": func(args []driver.Value) ([][]driver.Value, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if _, ok := s.searches[args[0].(string)]; !ok {
+				return nil, nil
+			}
+			delete(s.searches, args[0].(string))
+			return [][]driver.Value{{}}, nil
+		},
+		"RecordSavedSearchRun": func(args []driver.Value) ([][]driver.Value, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			search := s.searches[args[0].(string)]
+			search.LastRunAt.Time, search.LastRunAt.Valid = time.Now(), true
+			search.LastResultCount.Int32, search.LastResultCount.Valid = int32(args[1].(int64)), true
+			s.searches[search.Name] = search
+			return [][]driver.Value{{search.LastRunAt.Time}}, nil
+		},
+	}
+}
+
+func newSavedSearchServer(t *testing.T, extra map[string]fakeQuery) (*httptest.Server, *fakeDB) {
+	t.Helper()
+	store := &savedSearchStore{searches: make(map[string]database.SavedSearch)}
+	queries := store.queries()
+	for name, query := range extra {
+		queries[name] = query
+	}
+	cfg, fake := newFakeDBConfig(t, queries)
+	server := httptest.NewServer(newRouter(cfg))
+	t.Cleanup(server.Close)
+	return server, fake
+}
+
+func doJSON(t *testing.T, method, url string, body any, into any) *http.Response {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	if into != nil && res.StatusCode < 300 {
+		if err := json.NewDecoder(res.Body).Decode(into); err != nil {
+			t.Fatalf("%s %s: decoding: %v", method, url, err)
+		}
+	}
+	return res
+}
+
+func TestSavedSearchLifecycle(t *testing.T) {
+	server, _ := newSavedSearchServer(t, nil)
+	searches := server.URL + "/searches"
+
+	var created SavedSearchResponse
+	res := doJSON(t, http.MethodPost, searches, SavedSearchRequest{Name: "long-palindromes", Filters: map[string]string{"is_palindrome": "true", "min_length": "5"}}, &created)
+	if res.StatusCode != http.StatusCreated || created.Kind != savedSearchFilters || created.Filters["min_length"] != "5" || created.LastRunAt != nil {
+		t.Fatalf("create: status %d, %+v", res.StatusCode, created)
+	}
+	if res := doJSON(t, http.MethodPost, searches, SavedSearchRequest{Name: "long-palindromes", Query: "palindromes"}, nil); res.StatusCode != http.StatusConflict {
+		t.Errorf("create with a taken name: status = %d, want %d", res.StatusCode, http.StatusConflict)
+	}
+	res = doJSON(t, http.MethodPost, searches, SavedSearchRequest{Name: "recent-french", Query: "the 10 most recent french strings"}, &created)
+	if res.StatusCode != http.StatusCreated || created.Kind != savedSearchNaturalLanguage || created.Locale != "en" || created.Search.Limit != 10 {
+		t.Fatalf("create natural language: status %d, %+v", res.StatusCode, created)
+	}
+
+	var list SavedSearchListResponse
+	if res := doJSON(t, http.MethodGet, searches, nil, &list); res.StatusCode != http.StatusOK || list.Count != 2 ||
+		list.Data[0].Name != "long-palindromes" || list.Data[1].Name != "recent-french" {
+		t.Errorf("list: status %d, %+v, want both searches by name", res.StatusCode, list)
+	}
+
+	var updated SavedSearchResponse
+	res = doJSON(t, http.MethodPut, searches+"/long-palindromes", SavedSearchRequest{Query: "palindromes"}, &updated)
+	if res.StatusCode != http.StatusOK || updated.Kind != savedSearchNaturalLanguage || updated.Query != "palindromes" || len(updated.Filters) != 0 {
+		t.Errorf("update: status %d, %+v", res.StatusCode, updated)
+	}
+	if res := doJSON(t, http.MethodPut, searches+"/long-palindromes", SavedSearchRequest{Name: "renamed", Query: "palindromes"}, nil); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("rename: status = %d, want %d", res.StatusCode, http.StatusBadRequest)
+	}
+	if res := doJSON(t, http.MethodPut, searches+"/missing", SavedSearchRequest{Query: "palindromes"}, nil); res.StatusCode != http.StatusNotFound {
+		t.Errorf("update a missing search: status = %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+
+	var fetched SavedSearchResponse
+	if res := doJSON(t, http.MethodGet, searches+"/long-palindromes", nil, &fetched); res.StatusCode != http.StatusOK || fetched.Query != "palindromes" {
+		t.Errorf("get: status %d, %+v", res.StatusCode, fetched)
+	}
+	if res := doJSON(t, http.MethodDelete, searches+"/long-palindromes", nil, nil); res.StatusCode != http.StatusNoContent {
+		t.Errorf("delete: status = %d, want %d", res.StatusCode, http.StatusNoContent)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		res := doJSON(t, method, searches+"/long-palindromes", nil, nil)
+		if res.StatusCode != http.StatusNotFound {
+			t.Fatalf("%s after delete: status = %d, want %d", method, res.StatusCode, http.StatusNotFound)
+		}
+		if problem := decodeProblem(t, res); problem["code"] != codeSavedSearchNotFound {
+			t.Errorf("%s after delete: code = %v, want %s", method, problem["code"], codeSavedSearchNotFound)
+		}
+	}
+}
+
+func TestCreateSavedSearchRejects(t *testing.T) {
+	server, fake := newSavedSearchServer(t, nil)
+
+	tests := []struct {
+		name       string
+		body       SavedSearchRequest
+		wantStatus int
+		wantCode   string
+	}{
+		{"bad name", SavedSearchRequest{Name: "no spaces", Query: "palindromes"}, http.StatusBadRequest, codeInvalidSavedSearch},
+		{"filters and a query", SavedSearchRequest{Name: "both", Query: "palindromes", Filters: map[string]string{"is_palindrome": "true"}}, http.StatusBadRequest, codeInvalidSavedSearch},
+		{"neither", SavedSearchRequest{Name: "neither"}, http.StatusBadRequest, codeInvalidSavedSearch},
+		{"pagination is not a filter", SavedSearchRequest{Name: "paged", Filters: map[string]string{"page_size": "2"}}, http.StatusBadRequest, codeInvalidFilter},
+		{"unknown filter", SavedSearchRequest{Name: "colour", Filters: map[string]string{"colour": "red"}}, http.StatusBadRequest, codeInvalidFilter},
+		{"unsupported lang", SavedSearchRequest{Name: "german", Query: "palindromes", Lang: "de"}, http.StatusBadRequest, codeUnsupportedLocale},
+		{"unparseable query", SavedSearchRequest{Name: "gibberish", Query: "gibberish zzz"}, http.StatusBadRequest, codeUnparseableQuery},
+		{"conflicting query", SavedSearchRequest{Name: "never", Query: "french and german strings"}, http.StatusUnprocessableEntity, codeConflictingFilters},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := doJSON(t, http.MethodPost, server.URL+"/searches", tt.body, nil)
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if problem := decodeProblem(t, res); problem["code"] != tt.wantCode {
+				t.Errorf("code = %v, want %s", problem["code"], tt.wantCode)
+			}
+		})
+	}
+	if created := fake.calledWith("CreateSavedSearch"); len(created) != 0 {
+		t.Errorf("%d invalid searches were stored", len(created))
+	}
+}
+
+// the stored result count is the total the search matches, not the size of the page that was returned
+func TestSavedSearchResultsStoreTheTotal(t *testing.T) {
+	texts := make([][]driver.Value, 3)
+	for i := range texts {
+		texts[i] = textRow(database.Text{ID: uuid.New(), Value: "level", Length: 5, IsPalindrome: true, WordCount: 1, CreatedAt: time.Now()})
+	}
+	server, fake := newSavedSearchServer(t, map[string]fakeQuery{
+		"SearchTexts":            answer(texts...),
+		"CountTexts":             answer([]driver.Value{int64(7)}),
+		"GetCharacterCountsByID": answer(),
+	})
+
+	tests := []struct {
+		name      string
+		search    SavedSearchRequest
+		wantTotal int
+	}{
+		{"every match", SavedSearchRequest{Name: "palindromes", Filters: map[string]string{"is_palindrome": "true"}}, 7},
+		{"capped at the query limit", SavedSearchRequest{Name: "longest", Query: "the 5 longest palindromes"}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := doJSON(t, http.MethodPost, server.URL+"/searches", tt.search, nil); res.StatusCode != http.StatusCreated {
+				t.Fatalf("create: status = %d", res.StatusCode)
+			}
+
+			var results SavedSearchResultsResponse
+			res := doJSON(t, http.MethodGet, server.URL+"/searches/"+tt.search.Name+"/results?page_size=2", nil, &results)
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("run: status = %d", res.StatusCode)
+			}
+			if results.Count != 2 || results.Total != tt.wantTotal || results.NextCursor == "" || results.LastRunAt.IsZero() {
+				t.Errorf("results: count %d, total %d, cursor %q, last run %v, want a page of 2 out of %d",
+					results.Count, results.Total, results.NextCursor, results.LastRunAt, tt.wantTotal)
+			}
+
+			runs := fake.calledWith("RecordSavedSearchRun")
+			if last := runs[len(runs)-1]; last[1] != int64(tt.wantTotal) {
+				t.Errorf("recorded result count = %v, want %d", last[1], tt.wantTotal)
+			}
+			var fetched SavedSearchResponse
+			doJSON(t, http.MethodGet, server.URL+"/searches/"+tt.search.Name, nil, &fetched)
+			if fetched.LastResultCount == nil || *fetched.LastResultCount != tt.wantTotal || fetched.LastRunAt == nil {
+				t.Errorf("fetched search: last result count %v, last run %v, want %d", fetched.LastResultCount, fetched.LastRunAt, tt.wantTotal)
+			}
+		})
+	}
+
+	if res := doJSON(t, http.MethodGet, server.URL+"/searches/missing/results", nil, nil); res.StatusCode != http.StatusNotFound {
+		t.Errorf("run a missing search: status = %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+}
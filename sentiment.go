@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// both lexicons are embedded so moderation signals are computed without any external service
+//
+//go:embed lexicons/sentiment.txt
+var sentimentLexiconFile string
+
+//go:embed lexicons/profanity.txt
+var defaultBlocklistFile string
+
+var sentimentLexicon = parseSentimentLexicon(sentimentLexiconFile)
+
+// negations flip the polarity of the next few sentiment words
+var negations = map[string]bool{
+	"not":     true,
+	"no":      true,
+	"never":   true,
+	"neither": true,
+	"nor":     true,
+	"nothing": true,
+	"don't":   true,
+	"doesn't": true,
+	"didn't":  true,
+	"isn't":   true,
+	"wasn't":  true,
+	"aren't":  true,
+	"can't":   true,
+	"won't":   true,
+}
+
+// leetReplacer undoes common character substitutions used to dodge blocklists
+var leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+
+func parseSentimentLexicon(file string) map[string]int {
+	lexicon := make(map[string]int)
+	for _, line := range strings.Split(file, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 2 {
+			continue
+		}
+		score, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		lexicon[fields[0]] = score
+	}
+	return lexicon
+}
+
+// tokenizeWords lower-cases a string and splits it into words, keeping inner apostrophes
+func tokenizeWords(str string) []string {
+	var words []string
+	for _, field := range strings.Fields(strings.ToLower(str)) {
+		word := strings.TrimFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '@' && r != '$'
+		})
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// analyzeSentiment sums lexicon scores, flipping them after a negation, and normalizes
+// the total VADER-style into a compound score between -1 and 1
+func analyzeSentiment(str string) float64 {
+	total := 0
+	negateFor := 0
+	for _, word := range tokenizeWords(str) {
+		if negations[word] {
+			negateFor = 3
+			continue
+		}
+		score := sentimentLexicon[word]
+		if negateFor > 0 {
+			score = -score
+			negateFor--
+		}
+		total += score
+	}
+	if total == 0 {
+		return 0
+	}
+
+	compound := float64(total) / math.Sqrt(float64(total*total)+15)
+	return math.Round(compound*1000) / 1000
+}
+
+func sentimentLabel(score float64) string {
+	switch {
+	case score >= 0.05:
+		return "positive"
+	case score <= -0.05:
+		return "negative"
+	default:
+		return "neutral"
+	}
+}
+
+// blocklist holds the words and phrases flagged as profanity
+type blocklist map[string]bool
+
+// loadBlocklist reads the embedded defaults plus, when path is set, one extra entry per line from that file
+func loadBlocklist(path string) (blocklist, error) {
+	words := make(blocklist)
+	addEntries := func(scanner *bufio.Scanner) {
+		for scanner.Scan() {
+			line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			words[strings.Join(strings.Fields(line), " ")] = true
+		}
+	}
+
+	addEntries(bufio.NewScanner(strings.NewReader(defaultBlocklistFile)))
+	if path == "" {
+		return words, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	addEntries(scanner)
+	return words, scanner.Err()
+}
+
+// matches returns the blocklisted words and phrases that appear in str
+func (b blocklist) matches(str string) []string {
+	words := tokenizeWords(str)
+	for i, word := range words {
+		// numbers stay numbers, "room 455" isn't "room ass"
+		if strings.IndexFunc(word, unicode.IsLetter) >= 0 {
+			words[i] = leetReplacer.Replace(word)
+		}
+	}
+	padded := " " + strings.Join(words, " ") + " "
+
+	var found []string
+	for entry := range b {
+		if strings.Contains(padded, " "+entry+" ") {
+			found = append(found, entry)
+		}
+	}
+	sort.Strings(found)
+	return found
+}
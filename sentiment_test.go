@@ -0,0 +1,33 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBlocklistMatches(t *testing.T) {
+	words, err := loadBlocklist("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"plain word", "what an ass", []string{"ass"}},
+		{"leetspeak", "what an a55", []string{"ass"}},
+		{"symbol substitutions", "what a @$$hole", []string{"asshole"}},
+		{"numbers are not folded", "room 455 is on floor 5", nil},
+		{"phone number", "call 555 0135", nil},
+		{"clean text", "a perfectly polite sentence", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := words.matches(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matches(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/config"
+)
+
+// newServer applies the configured timeouts, which bound how long a client may take at each stage of a connection
+func newServer(addr string, handler http.Handler, settings config.Server) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: settings.ReadHeaderTimeout,
+		ReadTimeout:       settings.ReadTimeout,
+		WriteTimeout:      settings.WriteTimeout,
+		IdleTimeout:       settings.IdleTimeout,
+	}
+}
+
+// serve runs the server on listener until ctx is done, then stops accepting connections and gives
+// in-flight requests up to gracePeriod to finish before cutting them off
+func serve(ctx context.Context, server *http.Server, listener net.Listener, gracePeriod time.Duration) error {
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down, draining connections", "grace_period", gracePeriod.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		// the grace period ran out, drop whatever is still running
+		server.Close()
+		return fmt.Errorf("connections still open after the %s grace period: %w", gracePeriod, err)
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
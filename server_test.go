@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/config"
+)
+
+// startServer serves handler on a free port until the returned cancel is called,
+// serve's result is sent on the returned channel
+func startServer(t *testing.T, handler http.Handler, gracePeriod time.Duration) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newServer(listener.Addr().String(), handler, config.Default().Server)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, server, listener, gracePeriod)
+	}()
+	return "http://" + listener.Addr().String(), cancel, done
+}
+
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("finished"))
+	})
+	url, shutdown, done := startServer(t, handler, 5*time.Second)
+
+	type result struct {
+		body string
+		err  error
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		res, err := http.Get(url)
+		if err != nil {
+			inFlight <- result{err: err}
+			return
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		inFlight <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	shutdown()
+
+	// the server keeps draining while the request is still running
+	select {
+	case err := <-done:
+		t.Fatalf("serve returned before the in-flight request finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// new connections are refused once shutdown has started
+	if res, err := http.Get(url); err == nil {
+		res.Body.Close()
+		t.Error("expected new requests to be refused during shutdown")
+	}
+
+	close(release)
+	got := <-inFlight
+	if got.err != nil {
+		t.Fatalf("in-flight request failed: %v", got.err)
+	}
+	if got.body != "finished" {
+		t.Errorf("body = %q, want %q", got.body, "finished")
+	}
+	if err := <-done; err != nil {
+		t.Errorf("serve = %v, want a clean shutdown", err)
+	}
+}
+
+func TestShutdownGivesUpAfterGracePeriod(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		// never finishes on its own, only when the connection is cut
+		<-r.Context().Done()
+	})
+	url, shutdown, done := startServer(t, handler, 50*time.Millisecond)
+
+	go func() {
+		res, err := http.Get(url)
+		if err == nil {
+			res.Body.Close()
+		}
+	}()
+
+	<-started
+	shutdown()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected an error for requests cut off by the grace period")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve didn't return after the grace period")
+	}
+}
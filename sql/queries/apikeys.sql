@@ -0,0 +1,33 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (id, name, prefix, key_hash, scopes, created_by, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    NOW()
+)
+RETURNING id, name, prefix, key_hash, scopes, created_by, created_at, last_used_at, revoked_at;
+
+-- name: GetActiveAPIKeyByHash :one
+SELECT id, name, prefix, key_hash, scopes, created_by, created_at, last_used_at, revoked_at
+FROM api_keys
+WHERE key_hash = $1 AND revoked_at IS NULL;
+
+-- name: ListAPIKeys :many
+SELECT id, name, prefix, key_hash, scopes, created_by, created_at, last_used_at, revoked_at
+FROM api_keys
+ORDER BY created_at;
+
+-- name: RevokeAPIKey :one
+UPDATE api_keys
+SET revoked_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL
+RETURNING id, name, prefix, key_hash, scopes, created_by, created_at, last_used_at, revoked_at;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = NOW()
+WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute');
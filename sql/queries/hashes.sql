@@ -0,0 +1,33 @@
+-- name: CreateTextHashes :exec
+INSERT INTO text_hashes (id, string_id, algorithm, hash_value)
+SELECT gen_random_uuid(), @string_id::uuid, algorithm, hash_value
+FROM unnest(@algorithms::text[], @hash_values::text[]) AS h(algorithm, hash_value)
+ON CONFLICT (string_id, algorithm) DO NOTHING;
+
+-- name: GetTextHashesByID :many
+SELECT algorithm, hash_value
+FROM text_hashes
+WHERE string_id = $1
+ORDER BY algorithm;
+
+-- name: GetTextsByHash :many
+SELECT
+    t.id,
+    t.value,
+    t.length,
+    t.is_palindrome,
+    t.word_count,
+    t.sha256_hash,
+    t.created_at,
+    t.language,
+    t.language_confidence,
+    t.script,
+    t.normalized_value,
+    t.sentiment_score,
+    t.has_profanity,
+    t.created_by_key,
+    t.fuzzy_key_length
+FROM texts t
+JOIN text_hashes h ON h.string_id = t.id
+WHERE h.algorithm = $1 AND h.hash_value = $2
+ORDER BY t.created_at DESC;
@@ -0,0 +1,22 @@
+-- name: CreateNgramCounts :exec
+INSERT INTO ngram_count (id, string_id, unit, n, ngram, ngram_count)
+SELECT gen_random_uuid(), @string_id::uuid, unit, n, ngram, ngram_count
+FROM unnest(@units::text[], @ns::int[], @ngrams::text[], @ngram_counts::int[]) AS g(unit, n, ngram, ngram_count)
+ON CONFLICT (string_id, unit, n, ngram) DO NOTHING;
+
+-- name: GetNgramCountsByID :many
+SELECT ngram, ngram_count
+FROM ngram_count
+WHERE string_id = $1 AND unit = $2 AND n = $3
+ORDER BY ngram_count DESC, ngram;
+
+-- name: GetTopNgrams :many
+SELECT
+    ngram,
+    SUM(ngram_count)::int AS total_count,
+    COUNT(DISTINCT string_id)::int AS text_count
+FROM ngram_count
+WHERE unit = $1 AND n = $2
+GROUP BY ngram
+ORDER BY total_count DESC, ngram
+LIMIT $3;
@@ -0,0 +1,8 @@
+-- name: ConsumeTextQuota :one
+INSERT INTO text_quota_usage (client, day, texts_created)
+VALUES (@client, (NOW() AT TIME ZONE 'UTC')::date, 1)
+ON CONFLICT (client, day) DO UPDATE
+SET texts_created = text_quota_usage.texts_created + 1
+WHERE text_quota_usage.texts_created < @daily_limit
+RETURNING texts_created;
+
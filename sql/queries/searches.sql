@@ -0,0 +1,48 @@
+-- name: CreateSavedSearch :one
+INSERT INTO saved_searches (id, name, kind, filters, natural_language_query, locale, parsed_filters, query, created_at, updated_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    NOW(),
+    NOW()
+)
+RETURNING id, name, kind, filters, natural_language_query, locale, parsed_filters, query, created_at, updated_at, last_run_at, last_result_count;
+
+-- name: GetSavedSearch :one
+SELECT id, name, kind, filters, natural_language_query, locale, parsed_filters, query, created_at, updated_at, last_run_at, last_result_count
+FROM saved_searches
+WHERE name = $1;
+
+-- name: ListSavedSearches :many
+SELECT id, name, kind, filters, natural_language_query, locale, parsed_filters, query, created_at, updated_at, last_run_at, last_result_count
+FROM saved_searches
+ORDER BY name;
+
+-- name: UpdateSavedSearch :one
+UPDATE saved_searches
+SET kind = $2,
+    filters = $3,
+    natural_language_query = $4,
+    locale = $5,
+    parsed_filters = $6,
+    query = $7,
+    updated_at = NOW()
+WHERE name = $1
+RETURNING id, name, kind, filters, natural_language_query, locale, parsed_filters, query, created_at, updated_at, last_run_at, last_result_count;
+
+-- name: DeleteSavedSearch :execrows
+DELETE FROM saved_searches
+WHERE name = $1;
+
+-- name: RecordSavedSearchRun :one
+UPDATE saved_searches
+SET last_run_at = NOW(),
+    last_result_count = $2
+WHERE name = $1
+RETURNING last_run_at;
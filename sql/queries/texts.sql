@@ -1,5 +1,5 @@
 -- name: CreateText :one
-INSERT INTO texts (id, value, length, is_palindrome, word_count, sha256_hash, created_at)
+INSERT INTO texts (id, value, length, is_palindrome, word_count, sha256_hash, created_at, language, language_confidence, script, normalized_value, sentiment_score, has_profanity, created_by_key, fuzzy_key_length)
 VALUES (
     gen_random_uuid(),
     $1,
@@ -7,58 +7,55 @@ VALUES (
     $3,
     $4,
     $5,
-    NOW()
+    NOW(),
+    $6,
+    $7,
+    $8,
+    $9,
+    $10,
+    $11,
+    $12,
+    $13
 )
 RETURNING id;
 
--- name: CreateCharCount :exec
+-- name: CreateCharCounts :exec
 INSERT INTO character_count (id, string_id, character, unique_char_count)
-VALUES (
-    gen_random_uuid(),
-    $1,
-    $2,
-    $3
-);
+SELECT gen_random_uuid(), @string_id::uuid, character, unique_char_count
+FROM unnest(@characters::text[], @unique_char_counts::int[]) AS c(character, unique_char_count);
 
 -- name: GetText :one
-SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at 
+SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at, language, language_confidence, script, normalized_value, sentiment_score, has_profanity, created_by_key, fuzzy_key_length
 FROM texts WHERE value = $1;
 
 -- name: GetTextByID :one
-SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at 
+SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at, language, language_confidence, script, normalized_value, sentiment_score, has_profanity, created_by_key, fuzzy_key_length
 FROM texts WHERE id = $1;
 
+-- name: GetTextByNormalizedValue :one
+SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at, language, language_confidence, script, normalized_value, sentiment_score, has_profanity, created_by_key, fuzzy_key_length
+FROM texts WHERE normalized_value = $1
+ORDER BY created_at
+LIMIT 1;
+
+-- name: GetTextsByFuzzyKeyLength :many
+SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at, language, language_confidence, script, normalized_value, sentiment_score, has_profanity, created_by_key, fuzzy_key_length
+FROM texts
+WHERE (fuzzy_key_length >= @min_length AND fuzzy_key_length <= @max_length) OR fuzzy_key_length < 0
+ORDER BY created_at;
+
 -- name: GetAllTexts :many
-SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at 
+SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at, language, language_confidence, script, normalized_value, sentiment_score, has_profanity, created_by_key, fuzzy_key_length
 FROM texts 
 ORDER BY created_at DESC;
 
 -- name: GetCharacterCountsByID :many
 SELECT character, unique_char_count
-FROM texts
-JOIN character_count ON $1 = character_count.string_id
+FROM character_count
+WHERE string_id = $1
 ORDER BY character;
 
 
--- name: GetFilteredTexts :many
-SELECT DISTINCT
-    t.id,
-    t.value,
-    t.length,
-    t.is_palindrome,
-    t.word_count,
-    t.sha256_hash,
-    t.created_at
-FROM texts t
-WHERE 
-    t.is_palindrome = @is_palindrome AND
-    t.length >= @min_length AND
-    t.length <= @max_length AND
-    t.word_count = @word_count AND
-    t.value LIKE '%' || @contains_character || '%'
-ORDER BY t.created_at DESC;
-
-
 -- name: DeleteTextWithValue :exec
 DELETE FROM texts
 WHERE value = $1;
@@ -67,8 +64,19 @@ WHERE value = $1;
 DELETE FROM texts
 WHERE id = $1;
 
-
-
-
-
-
+-- name: GetTextsAfterID :many
+SELECT id, value, length, is_palindrome, word_count, sha256_hash, created_at, language, language_confidence, script, normalized_value, sentiment_score, has_profanity, created_by_key, fuzzy_key_length
+FROM texts
+WHERE id > $1
+ORDER BY id
+LIMIT $2;
+
+-- name: UpdateTextAnalysis :exec
+UPDATE texts
+SET language = $2,
+    language_confidence = $3,
+    script = $4,
+    sentiment_score = $5,
+    has_profanity = $6,
+    fuzzy_key_length = $7
+WHERE id = $1;
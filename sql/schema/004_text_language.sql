@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE texts
+    ADD COLUMN language TEXT NOT NULL DEFAULT 'und',
+    ADD COLUMN language_confidence DOUBLE PRECISION NOT NULL DEFAULT 0,
+    ADD COLUMN script TEXT NOT NULL DEFAULT 'Unknown';
+
+CREATE INDEX idx_texts_language ON texts(language);
+
+-- +goose Down
+DROP INDEX idx_texts_language;
+ALTER TABLE texts
+    DROP COLUMN script,
+    DROP COLUMN language_confidence,
+    DROP COLUMN language;
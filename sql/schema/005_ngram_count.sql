@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE ngram_count(
+    id UUID PRIMARY KEY,
+    string_id UUID NOT NULL,
+    unit TEXT NOT NULL,
+    n INTEGER NOT NULL,
+    ngram TEXT NOT NULL,
+    ngram_count INTEGER NOT NULL,
+    CONSTRAINT fk_ngram_string_id
+        FOREIGN KEY(string_id)
+        REFERENCES texts(id)
+        ON DELETE CASCADE,
+    UNIQUE(string_id, unit, n, ngram)
+);
+
+CREATE INDEX idx_ngram_count_unit_n ON ngram_count(unit, n);
+
+-- +goose Down
+DROP TABLE ngram_count;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE text_hashes(
+    id UUID PRIMARY KEY,
+    string_id UUID NOT NULL,
+    algorithm TEXT NOT NULL,
+    hash_value TEXT NOT NULL,
+    CONSTRAINT fk_hash_string_id
+        FOREIGN KEY(string_id)
+        REFERENCES texts(id)
+        ON DELETE CASCADE,
+    UNIQUE(string_id, algorithm)
+);
+
+CREATE INDEX idx_text_hashes_lookup ON text_hashes(algorithm, hash_value);
+
+-- +goose Down
+DROP TABLE text_hashes;
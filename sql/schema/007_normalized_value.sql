@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE texts ADD COLUMN normalized_value TEXT NOT NULL DEFAULT '';
+
+UPDATE texts SET normalized_value = lower(regexp_replace(btrim(value), '\s+', ' ', 'g'));
+
+CREATE INDEX idx_texts_normalized_value ON texts(normalized_value);
+CREATE INDEX idx_texts_length ON texts(length);
+
+-- +goose Down
+DROP INDEX idx_texts_length;
+DROP INDEX idx_texts_normalized_value;
+ALTER TABLE texts DROP COLUMN normalized_value;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE texts
+    ADD COLUMN sentiment_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+    ADD COLUMN has_profanity BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE texts
+    DROP COLUMN has_profanity,
+    DROP COLUMN sentiment_score;
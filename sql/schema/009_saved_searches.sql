@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE saved_searches(
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    kind TEXT NOT NULL,
+    filters JSONB NOT NULL DEFAULT '{}',
+    natural_language_query TEXT NOT NULL DEFAULT '',
+    locale TEXT NOT NULL DEFAULT '',
+    parsed_filters JSONB NOT NULL DEFAULT '{}',
+    query JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_run_at TIMESTAMP,
+    last_result_count INT,
+    CONSTRAINT chk_saved_search_kind
+        CHECK (kind IN ('filters', 'natural_language'))
+);
+
+-- +goose Down
+DROP TABLE saved_searches;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE api_keys(
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL UNIQUE,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,
+    created_by UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    CONSTRAINT chk_api_key_scopes
+        CHECK (cardinality(scopes) > 0 AND scopes <@ ARRAY['read', 'write', 'delete', 'admin'])
+);
+
+ALTER TABLE texts
+    ADD COLUMN created_by_key UUID REFERENCES api_keys(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE texts
+    DROP COLUMN created_by_key;
+DROP TABLE api_keys;
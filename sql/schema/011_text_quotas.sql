@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE text_quota_usage(
+    client TEXT NOT NULL,
+    day DATE NOT NULL,
+    texts_created INT NOT NULL DEFAULT 0,
+    PRIMARY KEY(client, day)
+);
+
+-- +goose Down
+DROP TABLE text_quota_usage;
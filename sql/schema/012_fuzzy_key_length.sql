@@ -0,0 +1,9 @@
+-- +goose Up
+-- stored texts get -1 until --backfill computes the key in Go, fuzzy dedup treats them as candidates of any length
+ALTER TABLE texts ADD COLUMN fuzzy_key_length INT NOT NULL DEFAULT -1;
+
+CREATE INDEX idx_texts_fuzzy_key_length ON texts(fuzzy_key_length);
+
+-- +goose Down
+DROP INDEX idx_texts_fuzzy_key_length;
+ALTER TABLE texts DROP COLUMN fuzzy_key_length;
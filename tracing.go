@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracing uses the OpenTelemetry SDK: spans are propagated with W3C trace context and exported
+// over OTLP/HTTP, so any OpenTelemetry collector can receive them
+
+const (
+	// traceresponse tells the caller which trace its request ended up in, see W3C Trace Context Level 2
+	traceresponseHeader = "traceresponse"
+
+	defaultServiceName  = "text-analyzer-api"
+	defaultOTLPEndpoint = "http://localhost:4318"
+
+	// the OTLP exporter sends a batch when it is full or when the interval elapses, whichever comes first
+	otlpBatchSize     = 512
+	otlpBatchInterval = 5 * time.Second
+	otlpQueueSize     = 4096
+)
+
+// tracer starts spans, a tracer without an exporter still propagates trace context but exports nothing
+type tracer struct {
+	provider   *sdktrace.TracerProvider
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// newTracer builds the tracer from OTEL_TRACES_EXPORTER (otlp, stdout or none), OTEL_EXPORTER_OTLP_ENDPOINT
+// and OTEL_SERVICE_NAME
+func newTracer(exporterName, otlpEndpoint, serviceName string) (*tracer, error) {
+	var options []sdktrace.TracerProviderOption
+	switch strings.ToLower(exporterName) {
+	case "", "none":
+	case "stdout", "console":
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, fmt.Errorf("creating stdout exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithSyncer(exporter))
+	case "otlp":
+		if otlpEndpoint == "" {
+			otlpEndpoint = defaultOTLPEndpoint
+		}
+		exporter, err := otlptracehttp.New(context.Background(),
+			otlptracehttp.WithEndpointURL(strings.TrimSuffix(otlpEndpoint, "/")+"/v1/traces"))
+		if err != nil {
+			return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exporter,
+			sdktrace.WithMaxExportBatchSize(otlpBatchSize),
+			sdktrace.WithBatchTimeout(otlpBatchInterval),
+			sdktrace.WithMaxQueueSize(otlpQueueSize),
+		))
+	default:
+		return nil, fmt.Errorf("invalid traces exporter %q: must be otlp, stdout or none", exporterName)
+	}
+	return newTracerWith(serviceName, options...), nil
+}
+
+// newTracerWith builds a tracer on its own provider, options add the span processors that export spans
+func newTracerWith(serviceName string, options ...sdktrace.TracerProviderOption) *tracer {
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	options = append([]sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+		// the caller's sampling decision is kept, new traces are always sampled
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+	}, options...)
+	provider := sdktrace.NewTracerProvider(options...)
+	return &tracer{
+		provider:   provider,
+		tracer:     provider.Tracer(defaultServiceName),
+		propagator: propagation.TraceContext{},
+	}
+}
+
+// start begins a span as a child of the span in ctx, or as the root of a new trace
+func (t *tracer) start(ctx context.Context, name string, kind trace.SpanKind, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attributes...))
+}
+
+// startStep begins the span of one analysis step of a text, e.g. "analyze language"
+func (t *tracer) startStep(ctx context.Context, step string) (context.Context, trace.Span) {
+	return t.start(ctx, "analyze "+step, trace.SpanKindInternal)
+}
+
+// endSpan ends a span, marking it failed when err is set
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traceRequests runs every request in a server span, continuing the caller's trace when it
+// sends a traceparent header, and tags the request's log lines with the trace ID
+func (t *tracer) traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := t.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := t.start(ctx, r.Method, trace.SpanKindServer,
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLPath(r.URL.Path),
+		)
+		ctx = context.WithValue(ctx, loggerKey, requestLogger(ctx).With("trace_id", span.SpanContext().TraceID().String()))
+		r = r.WithContext(ctx)
+		response := propagation.MapCarrier{}
+		t.propagator.Inject(ctx, response)
+		w.Header().Set(traceresponseHeader, response.Get("traceparent"))
+
+		rec := &responseRecorder{ResponseWriter: w}
+		defer func() {
+			// spans are named after the route pattern, e.g. "GET /strings/{string_value}"
+			if r.Pattern != "" {
+				span.SetName(r.Pattern)
+				span.SetAttributes(semconv.HTTPRoute(r.Pattern))
+			}
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+			span.End()
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// shutdown flushes spans that haven't been exported yet
+func (t *tracer) shutdown(ctx context.Context) error {
+	return t.provider.Shutdown(ctx)
+}
+
+// tracedDB runs every query sent through it in a client span named after its sqlc query
+type tracedDB struct {
+	db     database.DBTX
+	tracer *tracer
+}
+
+func newTracedDB(db database.DBTX, tracer *tracer) *tracedDB {
+	return &tracedDB{db: db, tracer: tracer}
+}
+
+func (tdb *tracedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := tdb.startSpan(ctx, query)
+	result, err := tdb.db.ExecContext(ctx, query, args...)
+	tdb.endSpan(span, err)
+	return result, err
+}
+
+func (tdb *tracedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := tdb.startSpan(ctx, query)
+	stmt, err := tdb.db.PrepareContext(ctx, query)
+	tdb.endSpan(span, err)
+	return stmt, err
+}
+
+// QueryContext spans the query until its first rows are ready, not the caller's scanning
+func (tdb *tracedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := tdb.startSpan(ctx, query)
+	rows, err := tdb.db.QueryContext(ctx, query, args...)
+	tdb.endSpan(span, err)
+	return rows, err
+}
+
+func (tdb *tracedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := tdb.startSpan(ctx, query)
+	row := tdb.db.QueryRowContext(ctx, query, args...)
+	tdb.endSpan(span, row.Err())
+	return row
+}
+
+func (tdb *tracedDB) startSpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	name := queryName(query)
+	return tdb.tracer.start(ctx, name, trace.SpanKindClient, semconv.DBSystemPostgreSQL, semconv.DBOperationName(name))
+}
+
+// endSpan ends a query's span, finding no rows isn't a failure
+func (tdb *tracedDB) endSpan(span trace.Span, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	endSpan(span, err)
+}
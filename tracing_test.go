@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	callerTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	callerTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	callerSpanID      = "00f067aa0ba902b7"
+)
+
+// newRecordingTracer is a tracer keeping every ended span in the returned recorder
+func newRecordingTracer() (*tracer, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	return newTracerWith(defaultServiceName, sdktrace.WithSpanProcessor(recorder)), recorder
+}
+
+// serveTraced runs one request through traceRequests in front of a mux serving pattern with handler
+func serveTraced(tr *tracer, pattern string, handler http.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc(pattern, handler)
+	rec := httptest.NewRecorder()
+	tr.traceRequests(mux).ServeHTTP(rec, req)
+	return rec
+}
+
+func attributeOf(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestTraceRequestsContinuesCallerTrace(t *testing.T) {
+	tr, recorder := newRecordingTracer()
+	var inHandler trace.SpanContext
+	req := httptest.NewRequest(http.MethodGet, "/strings/abc", nil)
+	req.Header.Set("traceparent", callerTraceparent)
+	req.Header.Set("tracestate", "vendor=value")
+	rec := serveTraced(tr, "GET /strings/{string_value}", func(w http.ResponseWriter, r *http.Request) {
+		inHandler = trace.SpanContextFromContext(r.Context())
+	}, req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.SpanContext().SpanID() != inHandler.SpanID() {
+		t.Errorf("handler context = %v, want the server span's", inHandler)
+	}
+	if got := span.SpanContext().TraceID().String(); got != callerTraceID {
+		t.Errorf("trace ID = %s, want the caller's", got)
+	}
+	if got := span.Parent().SpanID().String(); got != callerSpanID || !span.Parent().IsRemote() {
+		t.Errorf("parent = %s, want the caller's span", got)
+	}
+	if got := span.SpanContext().TraceState().String(); got != "vendor=value" {
+		t.Errorf("trace state = %q, want vendor=value", got)
+	}
+	if span.Name() != "GET /strings/{string_value}" || span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("name, kind = %q, %v, want the route pattern, server", span.Name(), span.SpanKind())
+	}
+	if route, _ := attributeOf(span, "http.route"); route.AsString() != "GET /strings/{string_value}" {
+		t.Errorf("http.route = %v, want the route pattern", route)
+	}
+	if status, _ := attributeOf(span, "http.response.status_code"); status.AsInt64() != http.StatusOK {
+		t.Errorf("http.response.status_code = %v, want 200", status)
+	}
+	want := "00-" + callerTraceID + "-" + span.SpanContext().SpanID().String() + "-01"
+	if got := rec.Header().Get(traceresponseHeader); got != want {
+		t.Errorf("traceresponse = %q, want %q", got, want)
+	}
+}
+
+func TestTraceRequestsStartsATraceWithoutAValidTraceparent(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{"none", ""},
+		{"version ff", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{"zero trace ID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{"zero span ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{"trace ID not hex", "00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01"},
+		{"missing flags", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, recorder := newRecordingTracer()
+			req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+			if tt.header != "" {
+				req.Header.Set("traceparent", tt.header)
+			}
+			serveTraced(tr, "GET /healthz", func(http.ResponseWriter, *http.Request) {}, req)
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("got %d spans, want 1, new traces are always sampled", len(spans))
+			}
+			if parent := spans[0].Parent(); parent.IsValid() {
+				t.Errorf("parent = %v, want a root span", parent)
+			}
+			if spans[0].SpanContext().TraceID().String() == callerTraceID {
+				t.Error("the invalid header's trace was continued")
+			}
+		})
+	}
+}
+
+func TestTraceRequestsKeepsTheCallersSamplingDecision(t *testing.T) {
+	tr, recorder := newRecordingTracer()
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("traceparent", "00-"+callerTraceID+"-"+callerSpanID+"-00")
+	rec := serveTraced(tr, "GET /healthz", func(http.ResponseWriter, *http.Request) {}, req)
+
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Errorf("recorded %d spans of an unsampled trace", len(spans))
+	}
+	// the caller still learns its trace was continued
+	if got := rec.Header().Get(traceresponseHeader); !strings.HasPrefix(got, "00-"+callerTraceID+"-") || !strings.HasSuffix(got, "-00") {
+		t.Errorf("traceresponse = %q, want the caller's unsampled trace", got)
+	}
+}
+
+func TestTraceRequestsMarksServerErrors(t *testing.T) {
+	tr, recorder := newRecordingTracer()
+	serveTraced(tr, "GET /boom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}, httptest.NewRequest(http.MethodGet, "/boom", nil))
+
+	span := recorder.Ended()[0]
+	if span.Status().Code != codes.Error || span.Status().Description != http.StatusText(http.StatusBadGateway) {
+		t.Errorf("status = %+v, want an error", span.Status())
+	}
+}
+
+func TestCreateTextTracesEachAnalysisStep(t *testing.T) {
+	tr, recorder := newRecordingTracer()
+	created := database.Text{ID: uuid.New(), Value: "level", Length: 5, IsPalindrome: true, WordCount: 1, CreatedAt: time.Now()}
+	cfg, _ := newFakeDBConfig(t, map[string]fakeQuery{
+		"GetText":                answer(),
+		"CreateText":             answer([]driver.Value{created.ID.String()}),
+		"CreateCharCounts":       answer(),
+		"CreateNgramCounts":      answer(),
+		"CreateTextHashes":       answer(),
+		"GetCharacterCountsByID": answer([]driver.Value{"l", int64(2)}),
+		"GetTextByID":            answer(textRow(created)),
+	})
+	cfg.Tracer = tr
+	cfg.DB = newQueries(cfg.Conn, cfg.Metrics, tr)
+	cfg.HashAlgorithms = selectHashAlgorithms(nil)
+	server := httptest.NewServer(newRouter(cfg))
+	defer server.Close()
+
+	res, err := http.Post(server.URL+"/strings", "application/json", strings.NewReader(`{"value": "level"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d: %s", res.StatusCode, body)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	serverSpan, ok := spans["POST /strings"]
+	if !ok {
+		t.Fatalf("no server span among %v", spans)
+	}
+	// analysis steps are children of the request, the queries they make are children of the step
+	for step, query := range map[string]string{
+		"analyze dedup":       "GetText",
+		"analyze language":    "",
+		"analyze sentiment":   "",
+		"analyze profanity":   "",
+		"analyze char counts": "CreateCharCounts",
+		"analyze ngrams":      "CreateNgramCounts",
+		"analyze hashes":      "CreateTextHashes",
+	} {
+		span, ok := spans[step]
+		if !ok {
+			t.Errorf("no %q span", step)
+			continue
+		}
+		if span.Parent().SpanID() != serverSpan.SpanContext().SpanID() {
+			t.Errorf("%q is not a child of the request", step)
+		}
+		if query == "" {
+			continue
+		}
+		if querySpan, ok := spans[query]; !ok || querySpan.Parent().SpanID() != span.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of %q", query, step)
+		}
+	}
+	if query, ok := spans["GetTextByID"]; !ok || query.SpanKind() != trace.SpanKindClient {
+		t.Errorf("GetTextByID span = %v, want a client span", query)
+	} else if operation, _ := attributeOf(query, "db.operation.name"); operation.AsString() != "GetTextByID" {
+		t.Errorf("db.operation.name = %v, want GetTextByID", operation)
+	}
+}
+
+func TestOTLPExporterSendsSpans(t *testing.T) {
+	received := make(chan *coltracepb.ExportTraceServiceRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+			t.Errorf("request = %s %s, want POST /v1/traces", r.Method, r.URL.Path)
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		request := &coltracepb.ExportTraceServiceRequest{}
+		if err := proto.Unmarshal(body, request); err != nil {
+			t.Errorf("decoding export request: %v", err)
+		}
+		received <- request
+	}))
+	defer collector.Close()
+
+	tr, err := newTracer("otlp", collector.URL+"/", "analyzer-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := tr.start(context.Background(), "GET /strings", trace.SpanKindServer, attribute.String("http.request.method", "GET"))
+	endSpan(span, io.ErrUnexpectedEOF)
+
+	// shutdown flushes the batch without waiting for the interval
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tr.shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	var request *coltracepb.ExportTraceServiceRequest
+	select {
+	case request = <-received:
+	default:
+		t.Fatal("collector received nothing")
+	}
+	if len(request.ResourceSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("request = %v, want one resource with one scope", request)
+	}
+	resource := request.ResourceSpans[0]
+	serviceName := ""
+	for _, kv := range resource.Resource.Attributes {
+		if kv.Key == "service.name" {
+			serviceName = kv.Value.GetStringValue()
+		}
+	}
+	if serviceName != "analyzer-test" {
+		t.Errorf("service.name = %q, want analyzer-test", serviceName)
+	}
+	if name := resource.ScopeSpans[0].Scope.Name; name != defaultServiceName {
+		t.Errorf("scope name = %q, want %q", name, defaultServiceName)
+	}
+	spans := resource.ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if spans[0].Name != "GET /strings" || spans[0].Status.GetMessage() != io.ErrUnexpectedEOF.Error() {
+		t.Errorf("span = %v, want the failed GET /strings", spans[0])
+	}
+}
+
+func TestNewTracerRejectsUnknownExporters(t *testing.T) {
+	if _, err := newTracer("zipkin", "", ""); err == nil {
+		t.Error("newTracer accepted the zipkin exporter")
+	}
+}
+
+func TestSpansEndedAfterShutdownAreDropped(t *testing.T) {
+	tr, err := newTracer("otlp", "http://127.0.0.1:0", defaultServiceName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	// a request still in flight when the server shuts down ends its span late, it must not panic
+	_, span := tr.start(context.Background(), "late", trace.SpanKindServer)
+	span.End()
+	if err := tr.shutdown(context.Background()); err != nil {
+		t.Fatalf("second shutdown: %v", err)
+	}
+}
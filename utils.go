@@ -3,15 +3,16 @@ package main
 import (
 	"context"
 	"crypto/sha256"
-	"database/sql"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
 	"unicode"
+
+	"github.com/HamstimusPrime/text-analyzer-api/internal/database"
+	"github.com/google/uuid"
 )
 
 func isPalindrome(text string) bool {
@@ -38,57 +39,31 @@ func parseReqBody(req *http.Request, format RequestBody) (RequestBody, error) {
 	return format, nil
 }
 
-func respondWithError(w http.ResponseWriter, errMsg string, HTTPstatus int) {
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.WriteHeader(HTTPstatus)
-	w.Write([]byte(errMsg))
-}
-
 func respondWithJSON(w http.ResponseWriter, resTemplate interface{}, HTTPstatus int) {
 	resJSON, err := json.Marshal(resTemplate)
 	if err != nil {
-		log.Fatal("unable to parse response JSON")
+		slog.Error("error encoding response JSON", "err", err)
+		respondWithError(w, nil, internalError("unable to encode response JSON"))
+		return
 	}
-	w.Header().Set("Content-Type", "json/plain; charset=utf-8")
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(HTTPstatus)
 	w.Write([]byte(resJSON))
 }
 
-func validateString(reqBody RequestBody, cfg *apiConfig) (int, string, error) {
+func validateString(reqBody RequestBody) error {
 	if reqBody.Value == "" || strings.TrimSpace(reqBody.Value) == "" {
-		errMsg := fmt.Sprintf(`Invalid request body or missing "value" field`)
-		return 400, errMsg, errors.New("no strings passed in value field")
+		return newAPIError(http.StatusBadRequest, codeMissingValue, `Invalid request body or missing "value" field`).
+			withField("value", "must be a non-empty string")
 	}
 
 	_, err := strconv.Atoi(reqBody.Value)
 	if err == nil {
-		errMsg := fmt.Sprintf(`Unprocessable Entity`)
-		return 422, errMsg, errors.New("invalid string format")
-	}
-
-	//check if String in DB
-	text, err := cfg.DB.GetText(context.Background(), reqBody.Value)
-	if err != nil {
-		// If it's a "not found" error(string doesn't exist) Only return an error if it's a real database error
-		if errors.Is(err, sql.ErrNoRows) {
-			fmt.Printf("String Not in DB, err: %v\n", err)
-			text.Value = ""
-
-		} else {
-			fmt.Println("Text NOT created!!")
-			errMsg := fmt.Sprintf("unable to create string. Internal server Error: %v\n", err)
-			return 500, errMsg, err
-		}
-
-	}
-
-	if text.Value != "" {
-		errMsg := fmt.Sprintf("String already exists in the system")
-		return 409, errMsg, errors.New("String in DB")
+		return newAPIError(http.StatusUnprocessableEntity, codeNumericString, "Numeric strings can't be analyzed").
+			withField("value", "must not be a number")
 	}
 
-	fmt.Println("Text created!!")
-	return 0, "", nil
+	return nil
 }
 
 func generateHash(str string) string {
@@ -122,3 +97,66 @@ func getUniqueChars(s string) map[rune]int32 {
 
 	return uniqueCharMap
 }
+
+// storeCharCounts writes the count of every distinct character of a text in a single insert
+func storeCharCounts(ctx context.Context, q *database.Queries, stringID uuid.UUID, value string) error {
+	params := database.CreateCharCountsParams{StringID: stringID}
+	for character, count := range getUniqueChars(value) {
+		params.Characters = append(params.Characters, string(character))
+		params.UniqueCharCounts = append(params.UniqueCharCounts, count)
+	}
+	if len(params.Characters) == 0 {
+		return nil
+	}
+	return q.CreateCharCounts(ctx, params)
+}
+
+// newQueries runs the generated queries through the metrics and tracing wrappers
+func newQueries(db database.DBTX, metrics *serviceMetrics, tracer *tracer) *database.Queries {
+	return database.New(newTracedDB(newInstrumentedDB(db, metrics), tracer))
+}
+
+// inTx runs fn in a transaction, committed if fn succeeds and rolled back otherwise
+func (cfg *apiConfig) inTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := cfg.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(newQueries(tx, cfg.Metrics, cfg.Tracer)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// buildTextResponse combines a stored text and its character counts into the API representation
+func buildTextResponse(text database.Text, charCounts []database.GetCharacterCountsByIDRow) SuccessResponseBody {
+	characterFrequencyMap := make(map[string]int)
+	for _, charCount := range charCounts {
+		characterFrequencyMap[charCount.Character] = int(charCount.UniqueCharCount)
+	}
+
+	response := SuccessResponseBody{
+		ID:    text.ID,
+		Value: text.Value,
+		Properties: TextProperties{
+			Length:                text.Length,
+			IsPalindrome:          fmt.Sprintf("%t", text.IsPalindrome),
+			UniqueCharacters:      fmt.Sprintf("%d", len(characterFrequencyMap)),
+			WordCount:             fmt.Sprintf("%d", text.WordCount),
+			Sha256Hash:            text.Sha256Hash,
+			Language:              text.Language,
+			LanguageConfidence:    text.LanguageConfidence,
+			Script:                text.Script,
+			SentimentScore:        text.SentimentScore,
+			SentimentLabel:        sentimentLabel(text.SentimentScore),
+			HasProfanity:          text.HasProfanity,
+			CharacterFrequencyMap: characterFrequencyMap,
+		},
+		CreatedAt: text.CreatedAt,
+	}
+	if text.CreatedByKey.Valid {
+		response.CreatedByKey = &text.CreatedByKey.UUID
+	}
+	return response
+}